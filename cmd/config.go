@@ -1,15 +1,22 @@
 package cmd
 
 import (
+	"database/sql"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 
 	"github.com/ahmadmuzakkir/dag/store"
 	"github.com/ahmadmuzakkir/dag/store/badgerstore"
 	"github.com/ahmadmuzakkir/dag/store/boltstore"
+	"github.com/ahmadmuzakkir/dag/store/leveldbstore"
+	"github.com/ahmadmuzakkir/dag/store/memorystore"
+	"github.com/ahmadmuzakkir/dag/store/sqlitestore"
 	"github.com/boltdb/bolt"
 	"github.com/dgraph-io/badger"
+	"github.com/syndtr/goleveldb/leveldb"
+	_ "modernc.org/sqlite"
 )
 
 // File path to the bolt db
@@ -18,7 +25,17 @@ const BoltPath = "/tmp/bolt/graph_bolt.db"
 // Folder path to the directory to store badger files
 const BadgerDirPath = "/tmp/badger"
 
-// 1 = Bolt, 2 = Badger
+// File path to the snapshot of the in-memory store
+const MemorySnapshotPath = "/tmp/memory/graph.dagb"
+
+// File path to the SQLite database
+const SQLitePath = "/tmp/sqlite/graph.db"
+
+// Folder path to the directory to store LevelDB files
+const LevelDBDirPath = "/tmp/leveldb"
+
+// 1 = Bolt, 2 = Badger, 3 = in memory, saved to a snapshot, 4 = SQLite,
+// 5 = LevelDB
 const DBType = 2
 
 func GetBoltDataStore(path string) (*boltstore.BoltStore, func(), error) {
@@ -55,11 +72,71 @@ func GetBadgerDataStore(dir string) (*badgerstore.BadgerStore, func(), error) {
 	return ds, func() { db.Close() }, nil
 }
 
+func GetLevelDBDataStore(dir string) (*leveldbstore.LevelDBStore, func(), error) {
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	var ds = leveldbstore.NewLevelDBStore(db)
+	return ds, func() { db.Close() }, nil
+}
+
+// GetMemoryDataStore loads the in-memory store from the snapshot at path. The
+// teardown saves the store to the snapshot.
+func GetMemoryDataStore(path string) (*memorystore.MemoryStore, func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, func() {}, err
+	}
+
+	ds, err := memorystore.OpenMemoryStore(path)
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	return ds, func() {
+		if err := ds.Save(); err != nil {
+			log.Println("save snapshot error:", err)
+		}
+	}, nil
+}
+
+// SQLiteDSN returns the data source name of the SQLite database at path. The
+// database is in WAL mode, so it can be read while it is written, and waits
+// for the locks of the other connections.
+func SQLiteDSN(path string) string {
+	return "file:" + path + "?_pragma=busy_timeout(10000)&_pragma=journal_mode(wal)"
+}
+
+func GetSQLiteDataStore(path string) (*sqlitestore.SQLiteStore, func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, func() {}, err
+	}
+
+	db, err := sql.Open("sqlite", SQLiteDSN(path))
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	ds, err := sqlitestore.NewSQLiteStore(db)
+	if err != nil {
+		return nil, func() { db.Close() }, err
+	}
+
+	return ds, func() { db.Close() }, nil
+}
+
 func GetDataStore() (store.GraphStore, func(), error) {
 	if DBType == 1 {
 		return GetBoltDataStore(BoltPath)
 	} else if DBType == 2 {
 		return GetBadgerDataStore(BadgerDirPath)
+	} else if DBType == 3 {
+		return GetMemoryDataStore(MemorySnapshotPath)
+	} else if DBType == 4 {
+		return GetSQLiteDataStore(SQLitePath)
+	} else if DBType == 5 {
+		return GetLevelDBDataStore(LevelDBDirPath)
 	} else {
 		return nil, func() {}, fmt.Errorf("unknown DBType %v", DBType)
 	}
@@ -0,0 +1,171 @@
+package main
+
+// Used to export the graph from the DB into a file
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/ahmadmuzakkir/dag/cmd"
+	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/ahmadmuzakkir/dag/store"
+)
+
+var (
+	format  = flag.String("format", "json", "format of the output: json, graphml, csv, mermaid, plantuml, svg or html")
+	outPath = flag.String("out", "", "path of the file to write, - for the standard output, the edge file with -format csv")
+
+	verticesPath = flag.String("vertices", "", "path of the vertex file to write, with -format csv, empty to skip")
+	comma        = flag.String("comma", ",", "field separator, with -format csv, e.g. \"\\t\" for TSV")
+	header       = flag.Bool("header", false, "write a header in the edge file, with -format csv")
+
+	root        = flag.String("root", "", "ID of the vertex to draw the ancestors of, with -format mermaid, plantuml or html")
+	descendants = flag.Bool("descendants", false, "draw the descendants of -root instead of its ancestors")
+	depth       = flag.Int("depth", 0, "maximum distance from -root, 0 for no limit")
+	maxVertices = flag.Int("max", 100, "maximum number of vertices of the diagram, 0 for no limit")
+	label       = flag.String("label", "", "property to label the vertices with, empty for the IDs, with -format mermaid, plantuml, svg or html")
+)
+
+func main() {
+	flag.Parse()
+
+	if *outPath == "" {
+		log.Fatal("missing -out")
+	}
+
+	ds, teardown, err := cmd.GetDataStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer teardown()
+
+	out := os.Stdout
+	if *outPath != "-" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			log.Fatal("create file error: ", err)
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	w := bufio.NewWriter(out)
+
+	switch *format {
+	case "json":
+		err = store.ExportNodeLink(ds, w)
+	case "graphml":
+		var graph *model.DAG
+		if graph, err = ds.Get(); err == nil {
+			err = graph.WriteGraphML(w)
+		}
+	case "csv":
+		err = exportCSV(ds, w)
+	case "mermaid", "plantuml":
+		err = exportDiagram(ds, w)
+	case "svg":
+		err = exportSVG(ds, w)
+	case "html":
+		err = exportHTML(ds, w)
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+	if err != nil {
+		log.Fatal("export error: ", err)
+	}
+
+	if err := w.Flush(); err != nil {
+		log.Fatal("flush writer error: ", err)
+	}
+}
+
+func exportCSV(ds store.GraphStore, edges io.Writer) error {
+	opts := store.CSVOptions{Header: *header}
+
+	c, err := strconv.Unquote(`"` + *comma + `"`)
+	if err != nil || utf8.RuneCountInString(c) != 1 {
+		log.Fatalf("invalid -comma %q", *comma)
+	}
+	opts.Comma, _ = utf8.DecodeRuneInString(c)
+
+	if *verticesPath == "" {
+		return store.ExportCSV(ds, nil, edges, opts)
+	}
+
+	f, err := os.Create(*verticesPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	vertices := bufio.NewWriter(f)
+	if err := store.ExportCSV(ds, vertices, edges, opts); err != nil {
+		return err
+	}
+
+	return vertices.Flush()
+}
+
+func exportDiagram(ds store.GraphStore, w io.Writer) error {
+	graph, err := ds.Get()
+	if err != nil {
+		return err
+	}
+
+	opts := &model.DiagramOptions{
+		Root:        *root,
+		Descendants: *descendants,
+		Depth:       *depth,
+		MaxVertices: *maxVertices,
+		ColorByFlag: true,
+	}
+	if *label != "" {
+		opts.Label = model.PropertyLabel(*label)
+	}
+
+	if *format == "mermaid" {
+		return graph.WriteMermaid(w, opts)
+	}
+
+	return graph.WritePlantUML(w, opts)
+}
+
+func exportSVG(ds store.GraphStore, w io.Writer) error {
+	graph, err := ds.Get()
+	if err != nil {
+		return err
+	}
+
+	opts := &model.SVGOptions{ColorByFlag: true}
+	if *label != "" {
+		opts.Label = model.PropertyLabel(*label)
+	}
+
+	return graph.WriteSVG(w, opts)
+}
+
+func exportHTML(ds store.GraphStore, w io.Writer) error {
+	graph, err := ds.Get()
+	if err != nil {
+		return err
+	}
+
+	opts := &model.HTMLOptions{
+		Root:        *root,
+		Descendants: *descendants,
+		Depth:       *depth,
+		MaxVertices: *maxVertices,
+	}
+	if *label != "" {
+		opts.Label = model.PropertyLabel(*label)
+	}
+
+	return graph.WriteHTML(w, opts)
+}
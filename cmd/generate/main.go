@@ -4,14 +4,24 @@ package main
 
 import (
 	"bufio"
-	"github.com/ahmadmuzakkir/dag/cmd"
+	"flag"
 	"log"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/ahmadmuzakkir/dag/cmd"
 	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/ahmadmuzakkir/dag/store"
+)
+
+var (
+	size        = flag.Int("size", 100, "number of vertices")
+	seed        = flag.Int64("seed", 0, "seed of the generator, 0 for a random seed")
+	stream      = flag.Bool("stream", false, "write the graph into the database in batches, without building it in memory")
+	batchSize   = flag.Int("batch", 1000, "number of vertices and edges per batch, with -stream")
+	dotFilePath = flag.String("dot", "test.dot", "path of the DOT file to write, empty to skip, ignored with -stream")
 )
 
 func init() {
@@ -19,6 +29,8 @@ func init() {
 }
 
 func main() {
+	flag.Parse()
+
 	ds, teardown, err := cmd.GetDataStore()
 	if err != nil {
 		log.Fatal(err)
@@ -26,24 +38,51 @@ func main() {
 
 	defer teardown()
 
-	graph := model.GenerateGraph(100)
+	opts := model.DefaultGeneratorOptions(*size)
+	opts.Seed = *seed
+	if opts.Seed == 0 {
+		opts.Seed = rand.Int63()
+	}
+	log.Printf("seed: %v", opts.Seed)
+
+	if *stream {
+		err = store.Generate(ds, opts, store.StreamOptions{
+			BatchSize: *batchSize,
+			Progress: func(written, total int) {
+				log.Printf("written %d/%d vertices", written, total)
+			},
+		})
+		if err != nil {
+			log.Fatal("generate error: ", err)
+		}
+		return
+	}
+
+	graph, err := model.Generate(opts)
+	if err != nil {
+		log.Fatal("generate error: ", err)
+	}
 
 	err = ds.Insert(graph)
 	if err != nil {
 		log.Fatal("insert error: ", err)
 	}
 
-	dotFilePath := "test.dot"
-	if _, err := os.Stat(dotFilePath); os.IsNotExist(err) {
-		if err2 := os.MkdirAll(filepath.Dir(dotFilePath), os.ModePerm); err2 != nil {
+	if *dotFilePath == "" {
+		return
+	}
+
+	if _, err := os.Stat(*dotFilePath); os.IsNotExist(err) {
+		if err2 := os.MkdirAll(filepath.Dir(*dotFilePath), os.ModePerm); err2 != nil {
 			log.Fatal("create file error: ", err2)
 		}
 	}
 
-	f, err := os.Create(dotFilePath)
+	f, err := os.Create(*dotFilePath)
 	if err != nil {
 		log.Fatal("open file error: ", err)
 	}
+	defer f.Close()
 
 	w := bufio.NewWriter(f)
 
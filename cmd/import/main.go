@@ -0,0 +1,116 @@
+package main
+
+// Used to import a graph from a file into the DB
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/ahmadmuzakkir/dag/cmd"
+	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/ahmadmuzakkir/dag/store"
+)
+
+var (
+	format        = flag.String("format", "dot", "format of the input: dot, json, graphml or csv")
+	inPath        = flag.String("in", "", "path of the file to import, - for the standard input, the edge file with -format csv")
+	verticesPath  = flag.String("vertices", "", "path of the vertex file, with -format csv, empty to create the vertices from the edges")
+	comma         = flag.String("comma", ",", "field separator, with -format csv, e.g. \"\\t\" for TSV or \" \" for edge lists")
+	header        = flag.Bool("header", false, "skip the header of the edge file, with -format csv")
+	childToParent = flag.Bool("child-to-parent", false, "read the edges as going from the children to the parents, with -format dot")
+	batchSize     = flag.Int("batch", 1000, "number of vertices and edges per batch, with -format json or csv")
+)
+
+func main() {
+	flag.Parse()
+
+	if *inPath == "" {
+		log.Fatal("missing -in")
+	}
+
+	in := os.Stdin
+	if *inPath != "-" {
+		f, err := os.Open(*inPath)
+		if err != nil {
+			log.Fatal("open file error: ", err)
+		}
+		defer f.Close()
+
+		in = f
+	}
+
+	ds, teardown, err := cmd.GetDataStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer teardown()
+
+	var graph *model.DAG
+
+	switch *format {
+	case "dot":
+		graph, err = model.ReadDOT(bufio.NewReader(in), &model.DOTReadOptions{ChildToParent: *childToParent})
+	case "graphml":
+		graph, err = model.ReadGraphML(bufio.NewReader(in))
+	case "json":
+		// Streamed into the store, without building the graph in memory.
+		if err := store.ImportNodeLink(ds, bufio.NewReader(in), *batchSize); err != nil {
+			log.Fatalf("%s: %v", *inPath, err)
+		}
+		log.Printf("imported %s", *inPath)
+		return
+	case "csv":
+		importCSV(ds, in)
+		return
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", *inPath, err)
+	}
+
+	err = ds.Insert(graph)
+	if err != nil {
+		log.Fatal("insert error: ", err)
+	}
+
+	log.Printf("imported %d vertices", len(graph.Vertices()))
+}
+
+func importCSV(ds store.GraphStore, edges io.Reader) {
+	opts := store.CSVOptions{Header: *header, BatchSize: *batchSize}
+
+	c, err := strconv.Unquote(`"` + *comma + `"`)
+	if err != nil || utf8.RuneCountInString(c) != 1 {
+		log.Fatalf("invalid -comma %q", *comma)
+	}
+	opts.Comma, _ = utf8.DecodeRuneInString(c)
+
+	var vertices io.Reader
+	if *verticesPath != "" {
+		f, err := os.Open(*verticesPath)
+		if err != nil {
+			log.Fatal("open file error: ", err)
+		}
+		defer f.Close()
+
+		vertices = bufio.NewReader(f)
+	}
+
+	report, err := store.ImportCSV(ds, vertices, bufio.NewReader(edges), opts)
+	if err != nil {
+		log.Fatal("import error: ", err)
+	}
+
+	for _, row := range report.Rejected {
+		log.Printf("rejected %v", row)
+	}
+
+	log.Printf("imported %d vertices and %d edges, rejected %d rows", report.Vertices, report.Edges, len(report.Rejected))
+}
@@ -0,0 +1,48 @@
+package main
+
+// Used to rewrite the records of the DB with a codec, e.g. the JSON records
+// written before the records had a schema version, and to move the edges of
+// the records to edge keys
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ahmadmuzakkir/dag/cmd"
+	"github.com/ahmadmuzakkir/dag/store"
+)
+
+var codecName = flag.String("codec", store.DefaultCodec.Name(), "codec of the records: json, protobuf or varint")
+
+func main() {
+	flag.Parse()
+
+	codec, err := store.CodecByName(*codecName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ds, teardown, err := cmd.GetDataStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer teardown()
+
+	m, ok := ds.(store.Migrator)
+	if !ok {
+		log.Fatal("the database does not support migrations")
+	}
+
+	start := time.Now()
+
+	m.SetCodec(codec)
+	n, err := m.Migrate()
+	if err != nil {
+		log.Fatalf("migrate error after %d records: %s", n, err)
+	}
+
+	fmt.Printf("Migrated %d records to the %s codec in %v\n", n, codec.Name(), time.Since(start))
+}
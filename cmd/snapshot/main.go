@@ -0,0 +1,133 @@
+package main
+
+// Used to write the graph of the DB into a binary snapshot, to load a
+// snapshot into the DB, or to query a snapshot without loading it. A query
+// still reads the offsets, edges and IDs of the snapshot to validate it, and
+// the whole file to verify its checksum unless -verify=false.
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ahmadmuzakkir/dag/cmd"
+	"github.com/ahmadmuzakkir/dag/model"
+)
+
+var (
+	outPath  = flag.String("out", "", "path of the snapshot to write from the database")
+	inPath   = flag.String("in", "", "path of the snapshot to load or query")
+	load     = flag.Bool("load", false, "load the snapshot given by -in into the database")
+	reach    = flag.String("reach", "", "ID of the vertex to count the ancestors of, in the snapshot given by -in")
+	flagCond = flag.String("flag", "", "count only the ancestors with this flag, true or false, with -reach")
+	verify   = flag.Bool("verify", true, "verify the checksum of the snapshot given by -in before querying it, which reads the whole file; disable it with -verify=false")
+)
+
+func main() {
+	flag.Parse()
+
+	switch {
+	case *outPath != "":
+		write()
+	case *inPath != "" && *load:
+		loadSnapshot()
+	case *inPath != "" && *reach != "":
+		query()
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func write() {
+	ds, teardown, err := cmd.GetDataStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer teardown()
+
+	graph, err := ds.Get()
+	if err != nil {
+		log.Fatal("get error: ", err)
+	}
+
+	f, err := os.Create(*outPath)
+	if err != nil {
+		log.Fatal("create file error: ", err)
+	}
+	defer f.Close()
+
+	if err := graph.WriteBinary(f); err != nil {
+		log.Fatal("write error: ", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		log.Fatal("sync error: ", err)
+	}
+
+	log.Printf("written %d vertices to %s", graph.CountVertex(), *outPath)
+}
+
+func loadSnapshot() {
+	f, err := os.Open(*inPath)
+	if err != nil {
+		log.Fatal("open file error: ", err)
+	}
+	defer f.Close()
+
+	graph, err := model.ReadBinary(bufio.NewReader(f))
+	if err != nil {
+		log.Fatalf("%s: %v", *inPath, err)
+	}
+
+	ds, teardown, err := cmd.GetDataStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer teardown()
+
+	if err := ds.Insert(graph); err != nil {
+		log.Fatal("insert error: ", err)
+	}
+
+	log.Printf("loaded %d vertices", graph.CountVertex())
+}
+
+func query() {
+	start := time.Now()
+
+	g, err := model.OpenBinary(*inPath)
+	if err != nil {
+		log.Fatalf("%s: %v", *inPath, err)
+	}
+	defer g.Close()
+
+	if *verify {
+		if err := g.Verify(); err != nil {
+			log.Fatalf("%s: %v", *inPath, err)
+		}
+	}
+
+	var count int
+	if *flagCond == "" {
+		count, err = g.Reach(*reach)
+	} else {
+		var f bool
+		if f, err = strconv.ParseBool(*flagCond); err != nil {
+			log.Fatalf("invalid -flag %q", *flagCond)
+		}
+		count, err = g.ConditionalReach(*reach, f)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(count)
+	log.Printf("answered in %v", time.Since(start))
+}
@@ -0,0 +1,500 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrVertexNotFound is returned, possibly wrapped, when a vertex does not exist.
+var ErrVertexNotFound = errors.New("vertex does not exist")
+
+// OpType identifies the kind of a staged mutation.
+type OpType int
+
+const (
+	OpAddVertex OpType = iota
+	OpDeleteVertex
+	OpAddEdge
+	OpDeleteEdge
+	OpUpdateVertex
+)
+
+func (t OpType) String() string {
+	switch t {
+	case OpAddVertex:
+		return "AddVertex"
+	case OpDeleteVertex:
+		return "DeleteVertex"
+	case OpAddEdge:
+		return "AddEdge"
+	case OpDeleteEdge:
+		return "DeleteEdge"
+	case OpUpdateVertex:
+		return "UpdateVertex"
+	}
+
+	return "Unknown"
+}
+
+// Op is a single staged mutation.
+type Op struct {
+	Type OpType
+
+	// Vertex is set for OpAddVertex and OpUpdateVertex.
+	Vertex *Vertex
+
+	// ID is set for OpDeleteVertex.
+	ID string
+
+	// Parent and Child are set for OpAddEdge and OpDeleteEdge.
+	Parent string
+	Child  string
+}
+
+// Batch is an ordered list of mutations which are validated together and
+// applied atomically, either to a DAG or to a store.
+// The zero value is an empty batch.
+type Batch struct {
+	ops []Op
+}
+
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// AddVertex stages the vertex. It must not have any edges, use AddEdge to
+// connect it.
+func (b *Batch) AddVertex(v *Vertex) {
+	b.ops = append(b.ops, Op{Type: OpAddVertex, Vertex: v})
+}
+
+// UpdateVertex stages the update of the flag, rank, index and properties of
+// the vertex with the ID of v. The edges of the vertex are kept, the edges of
+// v are ignored.
+func (b *Batch) UpdateVertex(v *Vertex) {
+	b.ops = append(b.ops, Op{Type: OpUpdateVertex, Vertex: v})
+}
+
+// DeleteVertex stages the deletion of the vertex and all of its edges.
+func (b *Batch) DeleteVertex(id string) {
+	b.ops = append(b.ops, Op{Type: OpDeleteVertex, ID: id})
+}
+
+func (b *Batch) AddEdge(parent, child string) {
+	b.ops = append(b.ops, Op{Type: OpAddEdge, Parent: parent, Child: child})
+}
+
+func (b *Batch) DeleteEdge(parent, child string) {
+	b.ops = append(b.ops, Op{Type: OpDeleteEdge, Parent: parent, Child: child})
+}
+
+// Append stages the operations, e.g. the operations of another batch.
+func (b *Batch) Append(ops ...Op) {
+	b.ops = append(b.ops, ops...)
+}
+
+func (b *Batch) Ops() []Op {
+	return b.ops
+}
+
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Reset removes all the staged operations.
+func (b *Batch) Reset() {
+	b.ops = b.ops[:0]
+}
+
+// BatchError reports the operation that made a batch invalid.
+type BatchError struct {
+	Index int
+	Op    Op
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch operation %d (%v): %s", e.Index, e.Op.Type, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// VertexGetter looks up a vertex by ID. It must return an error wrapping
+// ErrVertexNotFound if the vertex does not exist.
+type VertexGetter interface {
+	GetVertex(id string) (*Vertex, error)
+}
+
+// VertexGetterFunc adapts a function into a VertexGetter.
+type VertexGetterFunc func(id string) (*Vertex, error)
+
+func (f VertexGetterFunc) GetVertex(id string) (*Vertex, error) {
+	return f(id)
+}
+
+// ParentsGetter is a VertexGetter which also looks up a vertex without its
+// children, e.g. a store which keeps every edge in a key of its own, where
+// the children of a hub are many keys. Stage then reads the children of a
+// vertex only to delete it, so adding or deleting an edge of a hub does not
+// read its children. The vertices of the changes and of the events, but the
+// deleted ones, then lack the children which the batch did not add.
+type ParentsGetter interface {
+	VertexGetter
+
+	// GetVertexParents looks up a vertex by ID, with its parents but without
+	// its children.
+	GetVertexParents(id string) (*Vertex, error)
+}
+
+// ParentsGetterFunc adapts a function into a ParentsGetter. The function
+// returns the children of the vertex if children is set.
+type ParentsGetterFunc func(id string, children bool) (*Vertex, error)
+
+func (f ParentsGetterFunc) GetVertex(id string) (*Vertex, error) {
+	return f(id, true)
+}
+
+func (f ParentsGetterFunc) GetVertexParents(id string) (*Vertex, error) {
+	return f(id, false)
+}
+
+// Changes is the result of staging a batch.
+type Changes struct {
+	// The new state of every vertex touched by the batch, keyed by ID.
+	// A nil value means the vertex has been deleted.
+	Vertices map[string]*Vertex
+
+	// The events to emit once the changes are applied, in order.
+	Events []Event
+}
+
+// Stage validates the batch against src and computes the resulting changes,
+// without modifying src. The vertices returned in the changes are copies.
+//
+// The batch is invalid if it adds an existing vertex, references or updates a
+// missing vertex, adds an existing edge, deletes a missing edge or creates a cycle.
+// The error is a *BatchError.
+func (b *Batch) Stage(src VertexGetter) (*Changes, error) {
+	s := newStage(src)
+
+	for i, op := range b.ops {
+		if err := s.apply(op); err != nil {
+			return nil, &BatchError{Index: i, Op: op, Err: err}
+		}
+	}
+
+	return s.changes, nil
+}
+
+// Stager validates the mutations one at a time against a source, and keeps
+// the valid ones in a batch, so the invalid mutations of a large batch are
+// left out without staging the batch again, e.g. the invalid rows of an
+// import. The batch is valid until the source is modified.
+type Stager struct {
+	stage *stage
+	batch Batch
+}
+
+func NewStager(src VertexGetter) *Stager {
+	return &Stager{stage: newStage(src)}
+}
+
+// Add validates the mutation against the source and the mutations added
+// before. A valid mutation is added to the batch, an invalid one is not, and
+// its error is returned.
+func (s *Stager) Add(op Op) error {
+	if err := s.stage.apply(op); err != nil {
+		return err
+	}
+
+	s.batch.Append(op)
+
+	return nil
+}
+
+// Batch returns the valid mutations.
+func (s *Stager) Batch() *Batch {
+	return &s.batch
+}
+
+func (s *Stager) Len() int {
+	return s.batch.Len()
+}
+
+// stage overlays the staged vertices on top of the source.
+type stage struct {
+	src     VertexGetter
+	changes *Changes
+
+	// parents is the source if it is a ParentsGetter, and partial the staged
+	// vertices read without their children.
+	parents ParentsGetter
+	partial map[string]struct{}
+}
+
+func newStage(src VertexGetter) *stage {
+	parents, _ := src.(ParentsGetter)
+
+	return &stage{
+		src: src,
+		changes: &Changes{
+			Vertices: make(map[string]*Vertex),
+		},
+		parents: parents,
+		partial: make(map[string]struct{}),
+	}
+}
+
+// apply validates and stages the operation. An invalid operation does not
+// change the state of the staged vertices.
+func (s *stage) apply(op Op) error {
+	switch op.Type {
+	case OpAddVertex:
+		return s.addVertex(op.Vertex)
+	case OpDeleteVertex:
+		return s.deleteVertex(op.ID)
+	case OpUpdateVertex:
+		return s.updateVertex(op.Vertex)
+	case OpAddEdge:
+		return s.addEdge(op.Parent, op.Child)
+	case OpDeleteEdge:
+		return s.deleteEdge(op.Parent, op.Child)
+	}
+
+	return fmt.Errorf("unknown operation %v", op.Type)
+}
+
+// get returns the current state of the vertex, without its children if the
+// source is a ParentsGetter. The returned vertex must not be modified.
+func (s *stage) get(id string) (*Vertex, error) {
+	if v, ok := s.changes.Vertices[id]; ok {
+		if v == nil {
+			return nil, fmt.Errorf("%w: %s", ErrVertexNotFound, id)
+		}
+		return v, nil
+	}
+
+	if s.parents != nil {
+		return s.parents.GetVertexParents(id)
+	}
+
+	return s.src.GetVertex(id)
+}
+
+// mutable returns a staged copy of the vertex, which can be modified. It
+// lacks the children which the batch did not add if it is partial.
+func (s *stage) mutable(id string) (*Vertex, error) {
+	if v, ok := s.changes.Vertices[id]; ok && v != nil {
+		return v, nil
+	}
+
+	v, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	v = v.Clone()
+	s.changes.Vertices[id] = v
+	if s.parents != nil {
+		s.partial[id] = struct{}{}
+	}
+
+	return v, nil
+}
+
+// complete returns a staged copy of the vertex with all its children.
+func (s *stage) complete(id string) (*Vertex, error) {
+	v, err := s.mutable(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := s.partial[id]; !ok {
+		return v, nil
+	}
+
+	stored, err := s.src.GetVertex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The children staged by the batch know whether their edge still exists.
+	for c := range stored.Children {
+		if child, ok := s.changes.Vertices[c]; ok {
+			if child == nil {
+				continue
+			}
+			if _, ok := child.Parents[id]; !ok {
+				continue
+			}
+		}
+		v.Children[c] = struct{}{}
+	}
+	delete(s.partial, id)
+
+	return v, nil
+}
+
+func (s *stage) exists(id string) (bool, error) {
+	_, err := s.get(id)
+	if errors.Is(err, ErrVertexNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (s *stage) addVertex(v *Vertex) error {
+	if len(v.Parents) != 0 || len(v.Children) != 0 {
+		return fmt.Errorf("vertex %s must not have edges, use AddEdge", v.ID)
+	}
+
+	found, err := s.exists(v.ID)
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("vertex %s already exists", v.ID)
+	}
+
+	v = v.Clone()
+	s.changes.Vertices[v.ID] = v
+	s.changes.Events = append(s.changes.Events, VertexAdded{Vertex: v})
+
+	return nil
+}
+
+func (s *stage) updateVertex(v *Vertex) error {
+	old, err := s.get(v.ID)
+	if err != nil {
+		return err
+	}
+	old = old.Clone()
+
+	updated, err := s.mutable(v.ID)
+	if err != nil {
+		return err
+	}
+
+	updated.Flag = v.Flag
+	updated.Rank = v.Rank
+	updated.Index = v.Index
+	updated.Properties = v.CloneProperties()
+	s.changes.Events = append(s.changes.Events, VertexUpdated{Old: old, Vertex: updated})
+
+	return nil
+}
+
+func (s *stage) deleteVertex(id string) error {
+	v, err := s.complete(id)
+	if err != nil {
+		return err
+	}
+
+	for p := range v.Parents {
+		if err := s.deleteEdge(p, id); err != nil {
+			return err
+		}
+	}
+
+	for c := range v.Children {
+		if err := s.deleteEdge(id, c); err != nil {
+			return err
+		}
+	}
+
+	s.changes.Vertices[id] = nil
+	s.changes.Events = append(s.changes.Events, VertexDeleted{Vertex: v})
+
+	return nil
+}
+
+func (s *stage) addEdge(parentID, childID string) error {
+	if parentID == childID {
+		return fmt.Errorf("edge (%v,%v) is a cycle", parentID, childID)
+	}
+
+	parent, err := s.mutable(parentID)
+	if err != nil {
+		return err
+	}
+
+	child, err := s.mutable(childID)
+	if err != nil {
+		return err
+	}
+
+	// The parents of a vertex are always read, unlike its children.
+	if _, ok := child.Parents[parentID]; ok {
+		return fmt.Errorf("edge (%v,%v) already exists", parentID, childID)
+	}
+
+	// A vertex without children cannot be an ancestor of the parent.
+	if _, partial := s.partial[childID]; partial || len(child.Children) != 0 {
+		cycle, err := s.isAncestor(childID, parentID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return fmt.Errorf("edge (%v,%v) creates a cycle", parentID, childID)
+		}
+	}
+
+	parent.Children[childID] = struct{}{}
+	child.Parents[parentID] = struct{}{}
+	s.changes.Events = append(s.changes.Events, EdgeAdded{Parent: parent, Child: child})
+
+	return nil
+}
+
+func (s *stage) deleteEdge(parentID, childID string) error {
+	parent, err := s.mutable(parentID)
+	if err != nil {
+		return err
+	}
+
+	child, err := s.mutable(childID)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := child.Parents[parentID]; !ok {
+		return fmt.Errorf("edge (%v,%v) does not exist", parentID, childID)
+	}
+
+	delete(parent.Children, childID)
+	delete(child.Parents, parentID)
+	s.changes.Events = append(s.changes.Events, EdgeDeleted{Parent: parent, Child: child})
+
+	return nil
+}
+
+// isAncestor reports whether ancestor is reachable from id by following the parents.
+func (s *stage) isAncestor(ancestor, id string) (bool, error) {
+	q := []string{id}
+	visited := make(map[string]struct{})
+	visited[id] = struct{}{}
+
+	for len(q) != 0 {
+		u := q[0]
+		q = q[1:len(q):len(q)]
+
+		v, err := s.get(u)
+		if err != nil {
+			return false, err
+		}
+
+		for p := range v.Parents {
+			if p == ancestor {
+				return true, nil
+			}
+			if _, ok := visited[p]; !ok {
+				q = append(q, p)
+				visited[p] = struct{}{}
+			}
+		}
+	}
+
+	return false, nil
+}
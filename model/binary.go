@@ -0,0 +1,581 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+// The binary snapshot format stores the vertices by dense index, in order of
+// ID, so a vertex is found by binary search and the edges are arrays of
+// indexes. All the integers are little-endian uint32, except the ranks and
+// indexes which are int32.
+//
+//	header      magic "DAGB", version uint16, reserved uint16,
+//	            vertices n, edges m, ID bytes, property bytes
+//	ids         n+1 offsets into the ID bytes, then the ID bytes
+//	flags       a bit per vertex, (n+7)/8 bytes
+//	ranks       n int32
+//	indexes     n int32
+//	parents     n+1 offsets into the m parent indexes, then the parent indexes
+//	children    n+1 offsets into the m child indexes, then the child indexes
+//	properties  n+1 offsets into the property bytes, then the property bytes
+//	checksum    CRC-32 (Castagnoli) of all the bytes before it
+//
+// The properties of a vertex are a uvarint count, followed by the key and
+// value of every property in order of key, each as a uvarint length and the
+// bytes.
+
+// BinaryVersion is the version of the binary snapshot format written by WriteBinary.
+const BinaryVersion = 1
+
+const (
+	binaryMagic      = "DAGB"
+	binaryHeaderSize = 24
+)
+
+var (
+	// ErrBinaryFormat is returned, possibly wrapped, if a binary snapshot is malformed.
+	ErrBinaryFormat = errors.New("invalid binary snapshot")
+
+	// ErrBinaryChecksum is returned if the checksum of a binary snapshot does not match.
+	ErrBinaryChecksum = errors.New("binary snapshot checksum mismatch")
+)
+
+var binaryTable = crc32.MakeTable(crc32.Castagnoli)
+
+// WriteBinary writes the graph in the binary snapshot format.
+// The parents and children which are not in the graph are not written.
+func (d *DAG) WriteBinary(w io.Writer) error {
+	ids := sortedIDs(d.vertices)
+	if uint64(len(ids)) >= math.MaxUint32 {
+		return fmt.Errorf("too many vertices: %d", len(ids))
+	}
+
+	index := make(map[string]uint32, len(ids))
+	for i, id := range ids {
+		index[id] = uint32(i)
+	}
+
+	// The parents, and the number of children of every vertex.
+	parentOffsets := make([]uint32, len(ids)+1)
+	var parents []uint32
+	childCounts := make([]uint32, len(ids))
+
+	for i, id := range ids {
+		start := len(parents)
+		for p := range d.vertices[id].Parents {
+			if pi, ok := index[p]; ok {
+				parents = append(parents, pi)
+				childCounts[pi]++
+			}
+		}
+
+		sort.Slice(parents[start:], func(a, b int) bool {
+			return parents[start+a] < parents[start+b]
+		})
+
+		if uint64(len(parents)) >= math.MaxUint32 {
+			return fmt.Errorf("too many edges: %d", len(parents))
+		}
+		parentOffsets[i+1] = uint32(len(parents))
+	}
+
+	// The children, transposed from the parents, so they are in order too.
+	childOffsets := make([]uint32, len(ids)+1)
+	for i, c := range childCounts {
+		childOffsets[i+1] = childOffsets[i] + c
+	}
+	children := make([]uint32, len(parents))
+	next := append([]uint32(nil), childOffsets[:len(ids)]...)
+	for i := range ids {
+		for _, p := range parents[parentOffsets[i]:parentOffsets[i+1]] {
+			children[next[p]] = uint32(i)
+			next[p]++
+		}
+	}
+
+	var idBytes, propBytes bytes.Buffer
+	idOffsets := make([]uint32, len(ids)+1)
+	propOffsets := make([]uint32, len(ids)+1)
+	flags := make([]byte, (len(ids)+7)/8)
+	ranks := make([]uint32, len(ids))
+	indexes := make([]uint32, len(ids))
+
+	for i, id := range ids {
+		v := d.vertices[id]
+
+		idBytes.WriteString(id)
+		idOffsets[i+1] = uint32(idBytes.Len())
+
+		writeBinaryProperties(&propBytes, v.Properties)
+		propOffsets[i+1] = uint32(propBytes.Len())
+
+		if v.Flag {
+			flags[i/8] |= 1 << (i % 8)
+		}
+
+		if v.Rank < math.MinInt32 || v.Rank > math.MaxInt32 || v.Index < math.MinInt32 || v.Index > math.MaxInt32 {
+			return fmt.Errorf("rank or index of vertex %s out of range", id)
+		}
+		ranks[i] = uint32(int32(v.Rank))
+		indexes[i] = uint32(int32(v.Index))
+	}
+
+	if uint64(idBytes.Len()) >= math.MaxUint32 || uint64(propBytes.Len()) >= math.MaxUint32 {
+		return fmt.Errorf("snapshot too large")
+	}
+
+	bw := bufio.NewWriter(w)
+	crc := crc32.New(binaryTable)
+	bin := &binaryWriter{w: io.MultiWriter(bw, crc)}
+
+	bin.write([]byte(binaryMagic))
+	bin.uint16s(BinaryVersion, 0)
+	bin.uint32s(uint32(len(ids)), uint32(len(parents)), uint32(idBytes.Len()), uint32(propBytes.Len()))
+	bin.uint32s(idOffsets...)
+	bin.write(idBytes.Bytes())
+	bin.write(flags)
+	bin.uint32s(ranks...)
+	bin.uint32s(indexes...)
+	bin.uint32s(parentOffsets...)
+	bin.uint32s(parents...)
+	bin.uint32s(childOffsets...)
+	bin.uint32s(children...)
+	bin.uint32s(propOffsets...)
+	bin.write(propBytes.Bytes())
+
+	if bin.err != nil {
+		return bin.err
+	}
+
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], crc.Sum32())
+	if _, err := bw.Write(sum[:]); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func writeBinaryProperties(buf *bytes.Buffer, properties map[string]string) {
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var tmp [binary.MaxVarintLen64]byte
+
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(keys)))])
+	for _, k := range keys {
+		for _, s := range []string{k, properties[k]} {
+			buf.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(s)))])
+			buf.WriteString(s)
+		}
+	}
+}
+
+// binaryWriter keeps the first write error, like errWriter.
+type binaryWriter struct {
+	w   io.Writer
+	buf [4]byte
+	err error
+}
+
+func (bw *binaryWriter) write(p []byte) {
+	if bw.err != nil {
+		return
+	}
+
+	_, bw.err = bw.w.Write(p)
+}
+
+func (bw *binaryWriter) uint16s(values ...uint16) {
+	for _, v := range values {
+		binary.LittleEndian.PutUint16(bw.buf[:2], v)
+		bw.write(bw.buf[:2])
+	}
+}
+
+func (bw *binaryWriter) uint32s(values ...uint32) {
+	for _, v := range values {
+		binary.LittleEndian.PutUint32(bw.buf[:], v)
+		bw.write(bw.buf[:])
+	}
+}
+
+// ReadBinary reads a graph in the binary snapshot format, and verifies its checksum.
+func ReadBinary(r io.Reader) (*DAG, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := newBinaryGraph(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The checksum first, which tells a corrupted snapshot from a malformed one.
+	if err := g.Verify(); err != nil {
+		return nil, err
+	}
+	if err := g.validate(); err != nil {
+		return nil, err
+	}
+
+	return g.DAG()
+}
+
+// BinaryGraph queries a binary snapshot in place, without decoding it, e.g.
+// a memory-mapped file opened with OpenBinary. The vertices are identified by
+// their dense index, from 0 to Len()-1, in order of ID.
+type BinaryGraph struct {
+	data []byte
+	n, m int
+
+	// The positions of the sections in the data, and the sizes of the ID
+	// and property bytes.
+	idOffsets, ids          int
+	flags, ranks, indexes   int
+	parentOffsets, parents  int
+	childOffsets, children  int
+	propOffsets, properties int
+	idBytes, propertyBytes  int
+
+	close func() error
+}
+
+// NewBinaryGraph checks the header, the size, the offset tables and the edge
+// indexes of the snapshot, so the queries cannot read out of it, but not its
+// checksum, see Verify. The data must not be modified while the graph is used.
+//
+// The checks read the offset tables, the edges and the IDs, so they take time
+// in proportion to the size of the graph, though not to its properties;
+// Verify reads the whole snapshot.
+func NewBinaryGraph(data []byte) (*BinaryGraph, error) {
+	g, err := newBinaryGraph(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.validate(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// newBinaryGraph checks the header and the size of the snapshot, and finds
+// its sections.
+func newBinaryGraph(data []byte) (*BinaryGraph, error) {
+	if len(data) < binaryHeaderSize+4 || string(data[:4]) != binaryMagic {
+		return nil, fmt.Errorf("%w: bad header", ErrBinaryFormat)
+	}
+
+	if version := binary.LittleEndian.Uint16(data[4:]); version != BinaryVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrBinaryFormat, version)
+	}
+
+	g := &BinaryGraph{
+		data:          data,
+		n:             int(binary.LittleEndian.Uint32(data[8:])),
+		m:             int(binary.LittleEndian.Uint32(data[12:])),
+		idBytes:       int(binary.LittleEndian.Uint32(data[16:])),
+		propertyBytes: int(binary.LittleEndian.Uint32(data[20:])),
+	}
+
+	offsets := 4 * (g.n + 1)
+	pos := binaryHeaderSize
+	section := func(size int) int {
+		start := pos
+		pos += size
+		return start
+	}
+
+	g.idOffsets = section(offsets)
+	g.ids = section(g.idBytes)
+	g.flags = section((g.n + 7) / 8)
+	g.ranks = section(4 * g.n)
+	g.indexes = section(4 * g.n)
+	g.parentOffsets = section(offsets)
+	g.parents = section(4 * g.m)
+	g.childOffsets = section(offsets)
+	g.children = section(4 * g.m)
+	g.propOffsets = section(offsets)
+	g.properties = section(g.propertyBytes)
+
+	if pos+4 != len(data) {
+		return nil, fmt.Errorf("%w: expected %d bytes, found %d", ErrBinaryFormat, pos+4, len(data))
+	}
+
+	return g, nil
+}
+
+// validate checks that the offset tables start at 0, do not decrease and end
+// at the size of their section, that the parents and children are vertices,
+// and that the IDs are in increasing order, as Lookup needs.
+func (g *BinaryGraph) validate() error {
+	tables := []struct {
+		name          string
+		offsets, size int
+	}{
+		{"ID", g.idOffsets, g.idBytes},
+		{"parent", g.parentOffsets, g.m},
+		{"child", g.childOffsets, g.m},
+		{"property", g.propOffsets, g.propertyBytes},
+	}
+	for _, table := range tables {
+		prev := g.uint32At(table.offsets, 0)
+		if prev != 0 {
+			return fmt.Errorf("%w: %s offsets start at %d", ErrBinaryFormat, table.name, prev)
+		}
+		for i := 1; i <= g.n; i++ {
+			offset := g.uint32At(table.offsets, i)
+			if offset < prev {
+				return fmt.Errorf("%w: %s offset %d of vertex %d is lower than the previous one", ErrBinaryFormat, table.name, offset, i-1)
+			}
+			prev = offset
+		}
+		if int(prev) != table.size {
+			return fmt.Errorf("%w: %s offsets end at %d, expected %d", ErrBinaryFormat, table.name, prev, table.size)
+		}
+	}
+
+	for _, section := range []int{g.parents, g.children} {
+		for j := 0; j < g.m; j++ {
+			if i := g.uint32At(section, j); int(i) >= g.n {
+				return fmt.Errorf("%w: edge to vertex %d, out of %d vertices", ErrBinaryFormat, i, g.n)
+			}
+		}
+	}
+
+	for i := 1; i < g.n; i++ {
+		if bytes.Compare(g.id(i-1), g.id(i)) >= 0 {
+			return fmt.Errorf("%w: ID of vertex %d is not in order", ErrBinaryFormat, i)
+		}
+	}
+
+	return nil
+}
+
+// Verify checks the checksum of the snapshot.
+func (g *BinaryGraph) Verify() error {
+	end := len(g.data) - 4
+	if crc32.Checksum(g.data[:end], binaryTable) != binary.LittleEndian.Uint32(g.data[end:]) {
+		return ErrBinaryChecksum
+	}
+
+	return nil
+}
+
+// Close releases the memory mapping of a graph opened with OpenBinary.
+// The graph must not be used after.
+func (g *BinaryGraph) Close() error {
+	if g.close == nil {
+		return nil
+	}
+
+	err := g.close()
+	g.close = nil
+	g.data = nil
+
+	return err
+}
+
+// Len returns the number of vertices.
+func (g *BinaryGraph) Len() int {
+	return g.n
+}
+
+// CountEdge returns the number of edges.
+func (g *BinaryGraph) CountEdge() int {
+	return g.m
+}
+
+func (g *BinaryGraph) uint32At(section, i int) uint32 {
+	return binary.LittleEndian.Uint32(g.data[section+4*i:])
+}
+
+// span returns the range of the i-th element of an offset table, which is
+// within its section since the tables are validated.
+func (g *BinaryGraph) span(offsets, i int) (int, int) {
+	return int(g.uint32At(offsets, i)), int(g.uint32At(offsets, i+1))
+}
+
+func (g *BinaryGraph) id(i int) []byte {
+	start, end := g.span(g.idOffsets, i)
+	return g.data[g.ids+start : g.ids+end]
+}
+
+// ID returns the ID of the vertex.
+func (g *BinaryGraph) ID(i int) string {
+	return string(g.id(i))
+}
+
+// Lookup returns the index of the vertex with the ID.
+func (g *BinaryGraph) Lookup(id string) (int, bool) {
+	i := sort.Search(g.n, func(i int) bool {
+		return string(g.id(i)) >= id
+	})
+
+	return i, i < g.n && g.ID(i) == id
+}
+
+func (g *BinaryGraph) Flag(i int) bool {
+	return g.data[g.flags+i/8]&(1<<(i%8)) != 0
+}
+
+func (g *BinaryGraph) Rank(i int) int {
+	return int(int32(g.uint32At(g.ranks, i)))
+}
+
+func (g *BinaryGraph) Index(i int) int {
+	return int(int32(g.uint32At(g.indexes, i)))
+}
+
+// Parents calls fn with the index of every parent of the vertex.
+func (g *BinaryGraph) Parents(i int, fn func(p int)) {
+	start, end := g.span(g.parentOffsets, i)
+	for j := start; j < end; j++ {
+		fn(int(g.uint32At(g.parents, j)))
+	}
+}
+
+// Children calls fn with the index of every child of the vertex.
+func (g *BinaryGraph) Children(i int, fn func(c int)) {
+	start, end := g.span(g.childOffsets, i)
+	for j := start; j < end; j++ {
+		fn(int(g.uint32At(g.children, j)))
+	}
+}
+
+// Properties decodes the properties of the vertex, nil if it has none.
+func (g *BinaryGraph) Properties(i int) (map[string]string, error) {
+	start, end := g.span(g.propOffsets, i)
+	buf := g.data[g.properties+start : g.properties+end]
+
+	next := func() (uint64, error) {
+		v, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return 0, fmt.Errorf("%w: bad properties of vertex %s", ErrBinaryFormat, g.ID(i))
+		}
+		buf = buf[n:]
+		return v, nil
+	}
+
+	count, err := next()
+	if err != nil || count == 0 {
+		return nil, err
+	}
+
+	properties := make(map[string]string)
+	for j := uint64(0); j < count; j++ {
+		var kv [2]string
+		for k := range kv {
+			size, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if size > uint64(len(buf)) {
+				return nil, fmt.Errorf("%w: bad properties of vertex %s", ErrBinaryFormat, g.ID(i))
+			}
+			kv[k] = string(buf[:size])
+			buf = buf[size:]
+		}
+		properties[kv[0]] = kv[1]
+	}
+
+	return properties, nil
+}
+
+// Vertex decodes the vertex, with its edges and properties.
+func (g *BinaryGraph) Vertex(i int) (*Vertex, error) {
+	v := NewVertex(g.ID(i), g.Flag(i), g.Rank(i))
+	v.Index = g.Index(i)
+
+	var err error
+	if v.Properties, err = g.Properties(i); err != nil {
+		return nil, err
+	}
+
+	g.Parents(i, func(p int) {
+		v.Parents[g.ID(p)] = struct{}{}
+	})
+	g.Children(i, func(c int) {
+		v.Children[g.ID(c)] = struct{}{}
+	})
+
+	return v, nil
+}
+
+// DAG decodes the whole graph.
+func (g *BinaryGraph) DAG() (*DAG, error) {
+	d := NewDAG()
+	d.vertices = make(map[string]*Vertex, g.n)
+
+	for i := 0; i < g.n; i++ {
+		v, err := g.Vertex(i)
+		if err != nil {
+			return nil, err
+		}
+
+		d.vertices[v.ID] = v
+	}
+
+	return d, nil
+}
+
+// Reach returns the number of ancestors of the vertex, as DAG.Reach does,
+// with a DFS.
+func (g *BinaryGraph) Reach(id string) (int, error) {
+	return g.reach(id, nil)
+}
+
+// ConditionalReach returns the number of ancestors of the vertex with the
+// flag, as DAG.ConditionalReach does, with a DFS.
+func (g *BinaryGraph) ConditionalReach(id string, flag bool) (int, error) {
+	return g.reach(id, &flag)
+}
+
+// reach counts the ancestors with a DFS, which keeps the vertices to visit in
+// a stack and finds the same ancestors as a BFS.
+func (g *BinaryGraph) reach(id string, flag *bool) (int, error) {
+	start, ok := g.Lookup(id)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrVertexNotFound, id)
+	}
+
+	visited := make([]uint64, (g.n+63)/64)
+	visited[start/64] |= 1 << (start % 64)
+
+	count := 0
+	stack := []int{start}
+
+	for len(stack) != 0 {
+		u := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		g.Parents(u, func(p int) {
+			if visited[p/64]&(1<<(p%64)) != 0 {
+				return
+			}
+			visited[p/64] |= 1 << (p % 64)
+
+			if flag == nil || g.Flag(p) == *flag {
+				count++
+			}
+			stack = append(stack, p)
+		})
+	}
+
+	return count, nil
+}
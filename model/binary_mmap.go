@@ -0,0 +1,47 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package model
+
+import (
+	"os"
+	"syscall"
+)
+
+// OpenBinary memory-maps a binary snapshot file, so it can be queried
+// without reading it in memory. The structure of the snapshot is validated,
+// which reads its offset tables, edges and IDs, as NewBinaryGraph does, but
+// not its checksum, see Verify. The graph must be closed.
+func OpenBinary(path string) (*BinaryGraph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.Size() < binaryHeaderSize+4 {
+		return NewBinaryGraph(nil)
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := NewBinaryGraph(data)
+	if err != nil {
+		syscall.Munmap(data)
+		return nil, err
+	}
+
+	g.close = func() error {
+		return syscall.Munmap(data)
+	}
+
+	return g, nil
+}
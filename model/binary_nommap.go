@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package model
+
+import "io/ioutil"
+
+// OpenBinary reads a binary snapshot file, so it can be queried. Memory
+// mapping is not supported on this platform, so the file is read in memory.
+// The structure of the snapshot is validated, as NewBinaryGraph does, but not
+// its checksum, see Verify. The graph must be closed.
+func OpenBinary(path string) (*BinaryGraph, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBinaryGraph(data)
+}
@@ -0,0 +1,216 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBinary(t *testing.T) {
+	graph := GenerateGraph(1000)
+	for _, v := range graph.vertices {
+		if v.Index%3 == 0 {
+			v.Properties = map[string]string{"name": "vertex " + v.ID, "": "empty key"}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := graph.WriteBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	read, err := ReadBinary(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(read.Vertices(), graph.Vertices()) {
+		t.Fatal("expected the read graph to be the written graph")
+	}
+
+	dir, err := ioutil.TempDir("", "binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "graph.dagb")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := OpenBinary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	if err := g.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	if g.Len() != graph.CountVertex() || g.CountEdge() != graph.CountEdge() {
+		t.Fatalf("expected %d vertices and %d edges, found %d and %d", graph.CountVertex(), graph.CountEdge(), g.Len(), g.CountEdge())
+	}
+
+	for id := range graph.vertices {
+		reach, err := g.Reach(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reach != graph.Reach(id) {
+			t.Fatalf("expected the reach of %s to be %d, found %d", id, graph.Reach(id), reach)
+		}
+
+		reach, err = g.ConditionalReach(id, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reach != graph.ConditionalReach(id, true) {
+			t.Fatalf("expected the conditional reach of %s to be %d, found %d", id, graph.ConditionalReach(id, true), reach)
+		}
+	}
+
+	if _, err := g.Reach("unknown"); !errors.Is(err, ErrVertexNotFound) {
+		t.Fatalf("expected ErrVertexNotFound, found %v", err)
+	}
+}
+
+func TestBinaryErrors(t *testing.T) {
+	graph := newTestGraph(t, [2]string{"a", "b"})
+
+	var buf bytes.Buffer
+	if err := graph.WriteBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	corrupt := func(f func(data []byte) []byte) []byte {
+		return f(append([]byte(nil), data...))
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, ErrBinaryFormat},
+		{"magic", corrupt(func(d []byte) []byte { d[0] = 'X'; return d }), ErrBinaryFormat},
+		{"version", corrupt(func(d []byte) []byte { d[4] = 2; return d }), ErrBinaryFormat},
+		{"truncated", data[:len(data)-1], ErrBinaryFormat},
+		{"checksum", corrupt(func(d []byte) []byte { d[binaryHeaderSize+12] ^= 1; return d }), ErrBinaryChecksum},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ReadBinary(bytes.NewReader(test.data)); !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, found %v", test.err, err)
+			}
+		})
+	}
+}
+
+// A malformed snapshot with a valid checksum is rejected, rather than read
+// out of its sections by the queries.
+func TestBinaryStructure(t *testing.T) {
+	graph := newTestGraph(t, [2]string{"a", "b"})
+
+	var buf bytes.Buffer
+	if err := graph.WriteBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	g, err := NewBinaryGraph(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// corrupt changes a copy of the data and updates its checksum.
+	corrupt := func(f func(d []byte)) []byte {
+		d := append([]byte(nil), data...)
+		f(d)
+		end := len(d) - 4
+		binary.LittleEndian.PutUint32(d[end:], crc32.Checksum(d[:end], binaryTable))
+		return d
+	}
+	put := func(d []byte, section, i int, v uint32) {
+		binary.LittleEndian.PutUint32(d[section+4*i:], v)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"parent index", corrupt(func(d []byte) { put(d, g.parents, 0, 2) })},
+		{"child index", corrupt(func(d []byte) { put(d, g.children, 0, 1<<31) })},
+		{"offsets start", corrupt(func(d []byte) { put(d, g.idOffsets, 0, 1) })},
+		{"offsets decrease", corrupt(func(d []byte) { put(d, g.childOffsets, 1, 5) })},
+		{"offsets end", corrupt(func(d []byte) { put(d, g.propOffsets, 2, 100) })},
+		{"ID order", corrupt(func(d []byte) { d[g.ids], d[g.ids+1] = 'b', 'a' })},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewBinaryGraph(test.data); !errors.Is(err, ErrBinaryFormat) {
+				t.Fatalf("expected %v, found %v", ErrBinaryFormat, err)
+			}
+			if _, err := ReadBinary(bytes.NewReader(test.data)); !errors.Is(err, ErrBinaryFormat) {
+				t.Fatalf("expected %v, found %v", ErrBinaryFormat, err)
+			}
+		})
+	}
+}
+
+func BenchmarkWriteBinary(b *testing.B) {
+	graph := GenerateGraph(testSize)
+
+	for n := 0; n < b.N; n++ {
+		if err := graph.WriteBinary(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadBinary(b *testing.B) {
+	var buf bytes.Buffer
+	if err := GenerateGraph(testSize).WriteBinary(&buf); err != nil {
+		b.Fatal(err)
+	}
+
+	for n := 0; n < b.N; n++ {
+		if _, err := ReadBinary(bytes.NewReader(buf.Bytes())); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBinaryReach(b *testing.B) {
+	graph := GenerateGraph(testSize)
+
+	var buf bytes.Buffer
+	if err := graph.WriteBinary(&buf); err != nil {
+		b.Fatal(err)
+	}
+
+	g, err := NewBinaryGraph(buf.Bytes())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	v := getVertex(graph, b)
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if _, err := g.Reach(v.ID); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package model
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// CountMode selects how ReachCounts counts the ancestors and descendants.
+type CountMode int
+
+const (
+	// CountExact counts exactly, using chunked bitsets.
+	CountExact CountMode = iota
+
+	// CountApprox estimates the counts using one HyperLogLog sketch per vertex.
+	CountApprox
+)
+
+const (
+	defaultChunkSize = 4096
+	defaultPrecision = 10
+)
+
+// CountOptions configures ReachCounts.
+type CountOptions struct {
+	Mode CountMode
+
+	// ChunkSize is the number of vertices tracked per pass in exact mode.
+	// Memory is ChunkSize/8 bytes per vertex, and the edges are visited once
+	// per chunk. It is rounded up to a multiple of 64. Defaults to 4096.
+	ChunkSize int
+
+	// Precision of the sketches in approximate mode, between 4 and 16.
+	// Memory is 2^Precision bytes per sketch in use, and the standard error
+	// is about 1.04/sqrt(2^Precision). Defaults to 10 (about 3%). The sketch
+	// of a vertex is released once its successors in the topological order
+	// have merged it, so the number of sketches in use is the width of the
+	// order rather than the number of vertices, e.g. 2 for a chain.
+	Precision uint8
+}
+
+// ReachCounts holds the number of ancestors and descendants of every vertex, keyed by ID.
+type ReachCounts struct {
+	Ancestors   map[string]int
+	Descendants map[string]int
+}
+
+// ReachCounts computes the number of ancestors and descendants of every vertex
+// in one pass over the topological order of the graph, instead of one
+// traversal per vertex. The options may be nil.
+func (d *DAG) ReachCounts(opts *CountOptions) (*ReachCounts, error) {
+	if opts == nil {
+		opts = &CountOptions{}
+	}
+
+	order, err := d.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(order)
+	index := make(map[string]int32, n)
+	for i, v := range order {
+		index[v.ID] = int32(i)
+	}
+
+	// Both adjacency lists are built from the parents, and only point to
+	// vertices which come earlier in their own order.
+	parents := make([][]int32, n)
+	children := make([][]int32, n)
+	for i, v := range order {
+		for p := range v.Parents {
+			j, ok := index[p]
+			if !ok {
+				continue
+			}
+			parents[i] = append(parents[i], j)
+
+			// Children are indexed in the reversed order.
+			children[n-1-int(j)] = append(children[n-1-int(j)], int32(n-1-i))
+		}
+	}
+
+	var ancestors, descendants []int
+
+	switch opts.Mode {
+	case CountExact:
+		chunk := opts.ChunkSize
+		if chunk <= 0 {
+			chunk = defaultChunkSize
+		}
+		chunk = (chunk + 63) / 64 * 64
+
+		ancestors = countExact(parents, chunk)
+		descendants = countExact(children, chunk)
+	case CountApprox:
+		precision := opts.Precision
+		if precision == 0 {
+			precision = defaultPrecision
+		}
+		if precision < 4 || precision > 16 {
+			return nil, fmt.Errorf("precision %d is not between 4 and 16", precision)
+		}
+
+		hashes := make([]uint64, n)
+		for i, v := range order {
+			hashes[i] = hashID(v.ID)
+		}
+
+		reversed := make([]uint64, n)
+		for i := range hashes {
+			reversed[n-1-i] = hashes[i]
+		}
+
+		ancestors = countApprox(parents, hashes, precision)
+		descendants = countApprox(children, reversed, precision)
+	default:
+		return nil, fmt.Errorf("unknown count mode %v", opts.Mode)
+	}
+
+	counts := &ReachCounts{
+		Ancestors:   make(map[string]int, n),
+		Descendants: make(map[string]int, n),
+	}
+	for i, v := range order {
+		counts.Ancestors[v.ID] = ancestors[i]
+		counts.Descendants[v.ID] = descendants[n-1-i]
+	}
+
+	return counts, nil
+}
+
+// countExact counts the vertices reachable from every vertex through preds,
+// given preds only point to lower indexes.
+//
+// The candidate vertices are processed in chunks. For each chunk, a bitset of
+// the reachable vertices of the chunk is propagated in order. A vertex below
+// the chunk cannot reach any vertex of the chunk, so it is skipped.
+func countExact(preds [][]int32, chunk int) []int {
+	n := len(preds)
+	words := chunk / 64
+	counts := make([]int, n)
+	sets := make([]uint64, n*words)
+
+	for lo := 0; lo < n; lo += chunk {
+		hi := lo + chunk
+
+		for v := lo; v < n; v++ {
+			set := sets[v*words : (v+1)*words]
+			for i := range set {
+				set[i] = 0
+			}
+
+			for _, p := range preds[v] {
+				if int(p) < lo {
+					continue
+				}
+
+				copyOr(set, sets[int(p)*words:(int(p)+1)*words])
+				if int(p) < hi {
+					bit := int(p) - lo
+					set[bit/64] |= 1 << uint(bit%64)
+				}
+			}
+
+			for _, w := range set {
+				counts[v] += bits.OnesCount64(w)
+			}
+		}
+	}
+
+	return counts
+}
+
+func copyOr(dst, src []uint64) {
+	for i := range dst {
+		dst[i] |= src[i]
+	}
+}
+
+// countApprox estimates the vertices reachable from every vertex through
+// preds, given preds only point to lower indexes.
+//
+// The sketch of a vertex is kept until every vertex with it among its preds
+// has merged it, then its registers are reused.
+func countApprox(preds [][]int32, hashes []uint64, precision uint8) []int {
+	n := len(preds)
+	m := 1 << precision
+	counts := make([]int, n)
+
+	// The number of vertices which have not merged the sketch yet.
+	pending := make([]int32, n)
+	for _, ps := range preds {
+		for _, p := range ps {
+			pending[p]++
+		}
+	}
+
+	sketches := make([][]uint8, n)
+	var free [][]uint8
+	release := func(v int) {
+		free = append(free, sketches[v])
+		sketches[v] = nil
+	}
+
+	for v := 0; v < n; v++ {
+		var registers []uint8
+		if k := len(free); k != 0 {
+			registers = free[k-1]
+			free = free[:k-1]
+			for i := range registers {
+				registers[i] = 0
+			}
+		} else {
+			registers = make([]uint8, m)
+		}
+		sketches[v] = registers
+		sketch := hll{precision: precision, registers: registers}
+
+		for _, p := range preds[v] {
+			sketch.merge(sketches[p])
+			sketch.add(hashes[p])
+
+			if pending[p]--; pending[p] == 0 {
+				release(int(p))
+			}
+		}
+
+		counts[v] = sketch.estimate()
+		if pending[v] == 0 {
+			release(v)
+		}
+	}
+
+	return counts
+}
+
+// hll is a HyperLogLog sketch.
+type hll struct {
+	precision uint8
+	registers []uint8
+}
+
+func (h hll) add(hash uint64) {
+	i := hash >> (64 - h.precision)
+	w := hash<<h.precision | 1<<(h.precision-1)
+	rank := uint8(bits.LeadingZeros64(w)) + 1
+
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+func (h hll) merge(registers []uint8) {
+	for i, r := range registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+}
+
+func (h hll) estimate() int {
+	m := float64(len(h.registers))
+
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	switch len(h.registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	}
+
+	e := alpha * m * m / sum
+
+	// Use linear counting for small cardinalities.
+	if e <= 2.5*m && zeros != 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+
+	return int(e + 0.5)
+}
+
+// hashID hashes the ID with FNV-1a, followed by the finalizer of SplitMix64
+// to spread the bits.
+func hashID(id string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	x := h.Sum64()
+
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
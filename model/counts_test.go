@@ -0,0 +1,136 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestReachCountsExact(t *testing.T) {
+	graph := newTestGraph(t,
+		[2]string{"r1", "a"},
+		[2]string{"r2", "a"},
+		[2]string{"a", "b"},
+		[2]string{"a", "c"},
+		[2]string{"b", "d"},
+		[2]string{"c", "d"},
+		[2]string{"d", "e"},
+	)
+
+	// Use a small chunk, so the vertices are processed in several passes.
+	for _, g := range []*DAG{graph, GenerateGraph(3000)} {
+		counts, err := g.ReachCounts(&CountOptions{ChunkSize: 64})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for id, v := range g.Vertices() {
+			if expected := g.Reach(id); counts.Ancestors[id] != expected {
+				t.Fatalf("expected %d ancestors of %s, found %d", expected, id, counts.Ancestors[id])
+			}
+			if expected := countDescendants(g, v); counts.Descendants[id] != expected {
+				t.Fatalf("expected %d descendants of %s, found %d", expected, id, counts.Descendants[id])
+			}
+		}
+	}
+}
+
+func TestReachCountsApprox(t *testing.T) {
+	// A chain, so the vertex i has i ancestors.
+	size := 5000
+	graph := NewDAG()
+	var prev *Vertex
+	for i := 0; i < size; i++ {
+		v := NewVertex(fmt.Sprintf("v%d", i), false, i)
+		graph.AddVertex(v)
+		if prev != nil {
+			if err := graph.AddEdge(prev, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		prev = v
+	}
+
+	counts, err := graph.ReachCounts(&CountOptions{Mode: CountApprox, Precision: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range []int{10, 100, 1000, size - 1} {
+		id := fmt.Sprintf("v%d", i)
+		if found := counts.Ancestors[id]; math.Abs(float64(found-i)) > 0.1*float64(i) {
+			t.Fatalf("expected about %d ancestors of %s, found %d", i, id, found)
+		}
+		if expected, found := size-1-i, counts.Descendants[id]; math.Abs(float64(found-expected)) > 0.1*float64(expected) {
+			t.Fatalf("expected about %d descendants of %s, found %d", expected, id, found)
+		}
+	}
+}
+
+// The sketches of a chain are released once merged, so only a few are in use.
+func TestCountApproxMemory(t *testing.T) {
+	size := 10000
+	preds := make([][]int32, size)
+	hashes := make([]uint64, size)
+	for i := range preds {
+		if i != 0 {
+			preds[i] = []int32{int32(i - 1)}
+		}
+		hashes[i] = hashID(fmt.Sprint(i))
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	countApprox(preds, hashes, 10)
+	runtime.ReadMemStats(&after)
+
+	// One sketch per vertex would allocate size << 10 bytes.
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > uint64(size<<10)/10 {
+		t.Fatalf("expected the sketches to be reused, allocated %d bytes", allocated)
+	}
+}
+
+func BenchmarkReachCounts(b *testing.B) {
+	graph := GenerateGraph(testSize)
+
+	b.ResetTimer()
+
+	modes := []struct {
+		name string
+		mode CountMode
+	}{
+		{name: "Exact", mode: CountExact},
+		{name: "Approx", mode: CountApprox},
+	}
+
+	for _, m := range modes {
+		b.Run(m.name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				if _, err := graph.ReachCounts(&CountOptions{Mode: m.mode}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func countDescendants(graph *DAG, v *Vertex) int {
+	q := []*Vertex{v}
+	visited := make(map[string]struct{})
+
+	for len(q) != 0 {
+		u := q[0]
+		q = q[1:]
+
+		for c := range u.Children {
+			if _, ok := visited[c]; !ok {
+				visited[c] = struct{}{}
+				cv, _ := graph.GetVertex(c)
+				q = append(q, cv)
+			}
+		}
+	}
+
+	return len(visited)
+}
@@ -8,6 +8,18 @@ import (
 // DAG type implements a Directed Acyclic Graph data structure.
 type DAG struct {
 	vertices map[string]*Vertex
+
+	// Incremented on every mutation.
+	version uint64
+
+	// Copy-on-write state, see Snapshot.
+	// shared is set when the vertices map is shared with a snapshot.
+	// owned is nil until the first snapshot, after which it holds the
+	// vertices that have been copied and can be modified in place.
+	shared bool
+	owned  map[string]struct{}
+
+	observers Observers
 }
 
 func NewDAG() *DAG {
@@ -18,20 +30,74 @@ func NewDAG() *DAG {
 	return d
 }
 
+// Subscribe registers an observer for the mutations of the graph.
+// The returned function unsubscribes it.
+func (d *DAG) Subscribe(o Observer) func() {
+	return d.observers.Subscribe(o)
+}
+
 func (d *DAG) AddVertex(v *Vertex) {
+	d.writable()
 	d.vertices[v.ID] = v
+	if d.owned != nil {
+		d.owned[v.ID] = struct{}{}
+	}
+
+	d.observers.Emit(VertexAdded{Vertex: v})
 }
 
+// UpdateVertex updates the flag, rank, index and properties of the vertex of
+// the graph with the ID of v. The edges of the vertex are kept, the edges of v
+// are ignored.
+func (d *DAG) UpdateVertex(v *Vertex) error {
+	old, ok := d.vertices[v.ID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrVertexNotFound, v.ID)
+	}
+	old = old.Clone()
+
+	d.writable()
+	updated := d.mutableVertex(v.ID)
+	updated.Flag = v.Flag
+	updated.Rank = v.Rank
+	updated.Index = v.Index
+	updated.Properties = v.CloneProperties()
+
+	d.observers.Emit(VertexUpdated{Old: old, Vertex: updated})
+
+	return nil
+}
+
+// DeleteVertex deletes the vertex and all of its edges.
 func (d *DAG) DeleteVertex(vertex *Vertex) error {
-	if _, ok := d.vertices[vertex.ID]; !ok {
+	v, ok := d.vertices[vertex.ID]
+	if !ok {
 		return fmt.Errorf("vertex with ID %v does not exist", vertex.ID)
 	}
+	vertex = v
+
+	for p := range vertex.Parents {
+		if parent, ok := d.vertices[p]; ok {
+			d.DeleteEdge(parent, vertex)
+		}
+	}
 
+	for c := range vertex.Children {
+		if child, ok := d.vertices[c]; ok {
+			d.DeleteEdge(vertex, child)
+		}
+	}
+
+	d.writable()
 	delete(d.vertices, vertex.ID)
+	delete(d.owned, vertex.ID)
+
+	d.observers.Emit(VertexDeleted{Vertex: vertex})
 
 	return nil
 }
 
+// AddEdge adds the edge between the vertices of the graph with the IDs of parent and child.
 func (d *DAG) AddEdge(parent *Vertex, child *Vertex) error {
 	if _, ok := d.vertices[parent.ID]; !ok {
 		return fmt.Errorf("vertex %v does not exist", parent.ID)
@@ -41,32 +107,58 @@ func (d *DAG) AddEdge(parent *Vertex, child *Vertex) error {
 		return fmt.Errorf("vertex ID %v does not exist", child.ID)
 	}
 
-	if _, ok := parent.Children[child.ID]; ok {
+	if _, ok := d.vertices[parent.ID].Children[child.ID]; ok {
 		return fmt.Errorf("edge (%v,%v) already exists", parent.ID, child.ID)
 	}
 
+	d.writable()
+	parent = d.mutableVertex(parent.ID)
+	child = d.mutableVertex(child.ID)
+
 	// Add edge.
 	parent.Children[child.ID] = struct{}{}
 	child.Parents[parent.ID] = struct{}{}
 
+	d.observers.Emit(EdgeAdded{Parent: parent, Child: child})
+
 	return nil
 }
 
+// DeleteEdge deletes the edge between the vertices of the graph with the IDs
+// of parent and child, if it exists.
 func (d *DAG) DeleteEdge(parent *Vertex, child *Vertex) {
-	if _, ok := parent.Children[child.ID]; ok {
-		delete(parent.Children, child.ID)
+	p, ok := d.vertices[parent.ID]
+	if !ok {
+		return
+	}
+
+	if _, ok := p.Children[child.ID]; !ok {
+		return
 	}
+
+	d.writable()
+	parent = d.mutableVertex(parent.ID)
+	delete(parent.Children, child.ID)
+
+	if _, ok := d.vertices[child.ID]; ok {
+		child = d.mutableVertex(child.ID)
+		delete(child.Parents, parent.ID)
+	}
+
+	d.observers.Emit(EdgeDeleted{Parent: parent, Child: child})
 }
 
 func (d *DAG) GetVertex(id string) (*Vertex, error) {
 	vertex, found := d.vertices[id]
 	if !found {
-		return vertex, fmt.Errorf("vertex %s does not exist", id)
+		return vertex, fmt.Errorf("%w: %s", ErrVertexNotFound, id)
 	}
 
 	return vertex, nil
 }
 
+// Vertices returns the vertices of the graph, keyed by ID.
+// The map and the vertices must not be modified, they may be shared with snapshots.
 func (d *DAG) Vertices() map[string]*Vertex {
 	return d.vertices
 }
@@ -192,14 +284,7 @@ func (d *DAG) GetVertexByPosition(pos int) (*Vertex, error) {
 	return nil, fmt.Errorf("vertex at position %v does not exist", pos)
 }
 
+// DOT writes the graph in the DOT language, with the default options of WriteDOT.
 func (d *DAG) DOT(w io.Writer) error {
-	var err error
-
-	_, err = fmt.Fprintln(w, "digraph DAG {")
-	for _, v := range d.vertices {
-		err = v.DOT(w, d)
-	}
-	_, err = fmt.Fprintln(w, "}")
-
-	return err
+	return d.WriteDOT(w, nil)
 }
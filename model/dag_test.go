@@ -1,7 +1,10 @@
 package model
 
 import (
+	"fmt"
 	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -41,6 +44,114 @@ func TestGenerateDAG(t *testing.T) {
 	}
 }
 
+func TestGenerateReproducible(t *testing.T) {
+	opts := DefaultGeneratorOptions(1000)
+	opts.Seed = 42
+	opts.Parents = UniformParents(1, 3)
+
+	a, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(a.Vertices(), b.Vertices()) {
+		t.Fatal("expected the same graph for the same seed")
+	}
+
+	opts.Seed = 43
+	c, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.DeepEqual(a.Vertices(), c.Vertices()) {
+		t.Fatal("expected a different graph for a different seed")
+	}
+}
+
+func TestGenerateOptions(t *testing.T) {
+	opts := GeneratorOptions{
+		Seed:            1,
+		Size:            500,
+		MinRankWidth:    2,
+		MaxRankWidth:    5,
+		Parents:         UniformParents(2, 4),
+		FlagProbability: 1,
+		ID: func(seed int64, index int) string {
+			return fmt.Sprintf("v%d", index)
+		},
+	}
+
+	graph, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if graph.CountVertex() != opts.Size {
+		t.Fatalf("expected vertices count %d, found %d", opts.Size, graph.CountVertex())
+	}
+
+	widths := make(map[int]int)
+	for id, v := range graph.Vertices() {
+		if id != fmt.Sprintf("v%d", v.Index) {
+			t.Fatalf("unexpected ID %s for index %d", id, v.Index)
+		}
+		if !v.Flag {
+			t.Fatal("expected every flag to be true")
+		}
+
+		widths[v.Rank]++
+
+		if v.Rank > 1 && (len(v.Parents) < 2 || len(v.Parents) > 4) {
+			t.Fatalf("expected 2 to 4 parents, found %d", len(v.Parents))
+		}
+		for p := range v.Parents {
+			pv, err := graph.GetVertex(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pv.Rank >= v.Rank {
+				t.Fatal("parent must have a lower rank")
+			}
+			if _, ok := pv.Children[id]; !ok {
+				t.Fatal("parent must have the vertex as a child")
+			}
+		}
+	}
+
+	for rank, width := range widths {
+		if width > opts.MaxRankWidth {
+			t.Fatalf("rank %d has %d vertices", rank, width)
+		}
+	}
+
+	if _, err := Generate(GeneratorOptions{Size: 10}); err == nil {
+		t.Fatal("expected error for invalid rank widths")
+	}
+
+	// An ID function which does not return the same ID for an index.
+	calls := 0
+	opts.ID = func(seed int64, index int) string {
+		calls++
+		return fmt.Sprintf("v%d-%d", index, calls)
+	}
+	if _, err := Generate(opts); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected a missing parent error, found %v", err)
+	}
+
+	opts.ID = func(seed int64, index int) string {
+		return fmt.Sprintf("v%d", index%100)
+	}
+	if _, err := Generate(opts); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected a duplicate vertex error, found %v", err)
+	}
+}
+
 func BenchmarkGenerateDAG(t *testing.B) {
 	size := testSize
 
@@ -0,0 +1,308 @@
+package model
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DiagramOptions configures WriteMermaid and WritePlantUML. The zero value
+// writes the whole graph, labelled with the IDs, from top to bottom.
+type DiagramOptions struct {
+	// Root, if set, selects the cone of the vertex: the vertex and its
+	// ancestors, or its descendants with Descendants. The root is highlighted.
+	Root        string
+	Descendants bool
+
+	// Depth limits the cone to the vertices at most this many edges away
+	// from the root. Zero means no limit.
+	Depth int
+
+	// MaxVertices truncates the diagram to this number of vertices, the
+	// nearest to the root first, or in order of rank and ID without a root.
+	// Zero means no limit.
+	MaxVertices int
+
+	// Label returns the label of a vertex. Nil means the ID. See PropertyLabel.
+	Label func(v *Vertex) string
+
+	// ColorByFlag fills the vertices with a colour depending on their flag.
+	ColorByFlag bool
+
+	// LeftToRight lays the diagram out from left to right.
+	LeftToRight bool
+}
+
+// diagram is the selection of the vertices of a diagram. The vertices left
+// out by the depth or the truncation are summarized by a single node.
+type diagram struct {
+	vertices []*Vertex
+
+	// The node name of every selected vertex.
+	nodes map[string]string
+
+	// The number of vertices left out, and the selected vertices which have
+	// parents or children left out.
+	omitted     int
+	fromOmitted []string
+	toOmitted   []string
+}
+
+func (d *DAG) diagram(opts *DiagramOptions) (*diagram, error) {
+	var candidates []*Vertex
+	inSelection := make(map[string]bool)
+
+	if opts.Root != "" {
+		root, err := d.GetVertex(opts.Root)
+		if err != nil {
+			return nil, err
+		}
+
+		// The cone, level by level, in order of ID in a level.
+		level := []string{root.ID}
+		inSelection[root.ID] = false
+
+		for depth := 0; len(level) != 0; depth++ {
+			sort.Strings(level)
+			if opts.Depth <= 0 || depth <= opts.Depth {
+				for _, id := range level {
+					candidates = append(candidates, d.vertices[id])
+				}
+			}
+
+			var next []string
+			for _, id := range level {
+				neighbours := d.vertices[id].Parents
+				if opts.Descendants {
+					neighbours = d.vertices[id].Children
+				}
+
+				for n := range neighbours {
+					if _, ok := d.vertices[n]; !ok {
+						continue
+					}
+					if _, ok := inSelection[n]; !ok {
+						inSelection[n] = false
+						next = append(next, n)
+					}
+				}
+			}
+
+			level = next
+		}
+	} else {
+		for _, v := range d.vertices {
+			candidates = append(candidates, v)
+			inSelection[v.ID] = false
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].Rank != candidates[j].Rank {
+				return candidates[i].Rank < candidates[j].Rank
+			}
+			return candidates[i].ID < candidates[j].ID
+		})
+	}
+
+	if opts.MaxVertices > 0 && len(candidates) > opts.MaxVertices {
+		candidates = candidates[:opts.MaxVertices]
+	}
+
+	dg := &diagram{
+		vertices: candidates,
+		nodes:    make(map[string]string, len(candidates)),
+		omitted:  len(inSelection) - len(candidates),
+	}
+
+	for i, v := range candidates {
+		inSelection[v.ID] = true
+		dg.nodes[v.ID] = "v" + strconv.Itoa(i)
+	}
+
+	// A parent or child which is in the cone but not selected is left out.
+	leftOut := func(ids map[string]struct{}) bool {
+		for id := range ids {
+			if selected, ok := inSelection[id]; ok && !selected {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, v := range candidates {
+		if (opts.Root == "" || !opts.Descendants) && leftOut(v.Parents) {
+			dg.fromOmitted = append(dg.fromOmitted, v.ID)
+		}
+		if (opts.Root == "" || opts.Descendants) && leftOut(v.Children) {
+			dg.toOmitted = append(dg.toOmitted, v.ID)
+		}
+	}
+
+	return dg, nil
+}
+
+// edges calls fn with every edge between two selected vertices.
+func (dg *diagram) edges(fn func(parent, child string)) {
+	for _, v := range dg.vertices {
+		for _, c := range sortedSet(v.Children) {
+			if _, ok := dg.nodes[c]; ok {
+				fn(dg.nodes[v.ID], dg.nodes[c])
+			}
+		}
+	}
+}
+
+func diagramLabel(opts *DiagramOptions) func(v *Vertex) string {
+	if opts.Label != nil {
+		return opts.Label
+	}
+
+	return func(v *Vertex) string {
+		return v.ID
+	}
+}
+
+const diagramOmittedNode = "more"
+
+func diagramOmittedLabel(n int) string {
+	if n == 1 {
+		return "1 more vertex"
+	}
+
+	return strconv.Itoa(n) + " more vertices"
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart, e.g. to embed in
+// Markdown. The selected vertices are written in order of selection.
+func (d *DAG) WriteMermaid(w io.Writer, opts *DiagramOptions) error {
+	if opts == nil {
+		opts = &DiagramOptions{}
+	}
+
+	dg, err := d.diagram(opts)
+	if err != nil {
+		return err
+	}
+
+	label := diagramLabel(opts)
+	ew := &errWriter{w: w}
+
+	direction := "TD"
+	if opts.LeftToRight {
+		direction = "LR"
+	}
+	ew.printf("flowchart %s\n", direction)
+
+	for _, v := range dg.vertices {
+		ew.printf("    %s[\"%s\"]\n", dg.nodes[v.ID], mermaidEscape(label(v)))
+	}
+	if dg.omitted > 0 {
+		ew.printf("    %s([\"%s\"])\n", diagramOmittedNode, diagramOmittedLabel(dg.omitted))
+	}
+
+	dg.edges(func(parent, child string) {
+		ew.printf("    %s --> %s\n", parent, child)
+	})
+	for _, id := range dg.fromOmitted {
+		ew.printf("    %s -.-> %s\n", diagramOmittedNode, dg.nodes[id])
+	}
+	for _, id := range dg.toOmitted {
+		ew.printf("    %s -.-> %s\n", dg.nodes[id], diagramOmittedNode)
+	}
+
+	if opts.ColorByFlag {
+		var flagged, unflagged []string
+		for _, v := range dg.vertices {
+			if v.Flag {
+				flagged = append(flagged, dg.nodes[v.ID])
+			} else {
+				unflagged = append(unflagged, dg.nodes[v.ID])
+			}
+		}
+
+		ew.printf("    classDef flagged fill:%s\n", dotFlagColor)
+		ew.printf("    classDef unflagged fill:%s\n", dotNoFlagColor)
+		if len(flagged) != 0 {
+			ew.printf("    class %s flagged\n", strings.Join(flagged, ","))
+		}
+		if len(unflagged) != 0 {
+			ew.printf("    class %s unflagged\n", strings.Join(unflagged, ","))
+		}
+	}
+
+	if opts.Root != "" {
+		ew.printf("    classDef root stroke:%s,stroke-width:2px\n", dotHighlightColor)
+		ew.printf("    class %s root\n", dg.nodes[opts.Root])
+	}
+
+	return ew.err
+}
+
+// mermaidEscape escapes a label between double quotes.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "\n", "<br/>").Replace(s)
+}
+
+// WritePlantUML writes the graph as a PlantUML diagram of rectangles.
+// The selected vertices are written in order of selection.
+func (d *DAG) WritePlantUML(w io.Writer, opts *DiagramOptions) error {
+	if opts == nil {
+		opts = &DiagramOptions{}
+	}
+
+	dg, err := d.diagram(opts)
+	if err != nil {
+		return err
+	}
+
+	label := diagramLabel(opts)
+	ew := &errWriter{w: w}
+
+	ew.printf("@startuml\n")
+	if opts.LeftToRight {
+		ew.printf("left to right direction\n")
+	}
+
+	for _, v := range dg.vertices {
+		var style []string
+		if opts.ColorByFlag {
+			color := dotNoFlagColor
+			if v.Flag {
+				color = dotFlagColor
+			}
+			style = append(style, color)
+		}
+		if v.ID == opts.Root {
+			style = append(style, "line:"+dotHighlightColor, "line.bold")
+		}
+
+		ew.printf("rectangle \"%s\" as %s", plantUMLEscape(label(v)), dg.nodes[v.ID])
+		if len(style) != 0 {
+			ew.printf(" #%s", strings.Join(style, ";"))
+		}
+		ew.printf("\n")
+	}
+	if dg.omitted > 0 {
+		ew.printf("rectangle \"%s\" as %s #line.dashed\n", diagramOmittedLabel(dg.omitted), diagramOmittedNode)
+	}
+
+	dg.edges(func(parent, child string) {
+		ew.printf("%s --> %s\n", parent, child)
+	})
+	for _, id := range dg.fromOmitted {
+		ew.printf("%s ..> %s\n", diagramOmittedNode, dg.nodes[id])
+	}
+	for _, id := range dg.toOmitted {
+		ew.printf("%s ..> %s\n", dg.nodes[id], diagramOmittedNode)
+	}
+
+	ew.printf("@enduml\n")
+
+	return ew.err
+}
+
+// plantUMLEscape escapes a label between double quotes, which cannot contain
+// double quotes.
+func plantUMLEscape(s string) string {
+	return strings.NewReplacer(`"`, "''", "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,202 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newDiagramTestGraph(t *testing.T) *DAG {
+	graph := newTestGraph(t,
+		[2]string{"r1", "a"},
+		[2]string{"r2", "a"},
+		[2]string{"a", "x"},
+		[2]string{"b", "x"},
+		[2]string{"x", "y"},
+	)
+	graph.vertices["a"].Flag = true
+	graph.vertices["b"].Properties = map[string]string{"name": `say "hi"`}
+
+	return graph
+}
+
+func TestWriteMermaid(t *testing.T) {
+	graph := newDiagramTestGraph(t)
+
+	var buf bytes.Buffer
+	err := graph.WriteMermaid(&buf, &DiagramOptions{
+		Root:        "y",
+		Depth:       2,
+		MaxVertices: 3,
+		ColorByFlag: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `flowchart TD
+    v0["y"]
+    v1["x"]
+    v2["a"]
+    more(["3 more vertices"])
+    v1 --> v0
+    v2 --> v1
+    more -.-> v1
+    more -.-> v2
+    classDef flagged fill:lightblue
+    classDef unflagged fill:white
+    class v2 flagged
+    class v0,v1 unflagged
+    classDef root stroke:red,stroke-width:2px
+    class v0 root
+`
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nfound:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	err = graph.WriteMermaid(&buf, &DiagramOptions{
+		Root:        "b",
+		Descendants: true,
+		Label:       PropertyLabel("name"),
+		LeftToRight: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = `flowchart LR
+    v0["say #quot;hi#quot;"]
+    v1["x"]
+    v2["y"]
+    v0 --> v1
+    v1 --> v2
+    classDef root stroke:red,stroke-width:2px
+    class v0 root
+`
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nfound:\n%s", expected, buf.String())
+	}
+
+	if err := graph.WriteMermaid(&buf, &DiagramOptions{Root: "unknown"}); !errors.Is(err, ErrVertexNotFound) {
+		t.Fatalf("expected ErrVertexNotFound, found %v", err)
+	}
+}
+
+func TestWritePlantUML(t *testing.T) {
+	graph := newDiagramTestGraph(t)
+
+	var buf bytes.Buffer
+	err := graph.WritePlantUML(&buf, &DiagramOptions{
+		MaxVertices: 4,
+		Label:       PropertyLabel("name"),
+		ColorByFlag: true,
+		LeftToRight: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `@startuml
+left to right direction
+rectangle "a" as v0 #lightblue
+rectangle "say ''hi''" as v1 #white
+rectangle "r1" as v2 #white
+rectangle "r2" as v3 #white
+rectangle "2 more vertices" as more #line.dashed
+v2 --> v0
+v3 --> v0
+v0 ..> more
+v1 ..> more
+@enduml
+`
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nfound:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := graph.WritePlantUML(&buf, &DiagramOptions{Root: "x", Depth: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = `@startuml
+rectangle "x" as v0 #line:red;line.bold
+rectangle "a" as v1
+rectangle "b" as v2
+rectangle "2 more vertices" as more #line.dashed
+v1 --> v0
+v2 --> v0
+more ..> v1
+@enduml
+`
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nfound:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	graph := newDiagramTestGraph(t)
+	graph.vertices["x"].Properties = map[string]string{"name": "</script><b>"}
+
+	var buf bytes.Buffer
+	err := graph.WriteHTML(&buf, &HTMLOptions{
+		Root:  "x",
+		Depth: 1,
+		Label: PropertyLabel("name"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := buf.String()
+	if strings.Contains(page, "</script><b>") {
+		t.Fatal("label is not escaped")
+	}
+	if !strings.Contains(page, "<title>x</title>") {
+		t.Fatal("title is missing")
+	}
+
+	// The data is embedded as JSON.
+	start := strings.Index(page, "var graph = ")
+	end := strings.Index(page, ";\nvar svgNS")
+	if start < 0 || end < start {
+		t.Fatal("data is missing")
+	}
+
+	var data htmlGraph
+	if err := json.Unmarshal([]byte(page[start+len("var graph = "):end]), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, n := range data.Nodes {
+		ids = append(ids, n.ID)
+	}
+	if strings.Join(ids, ",") != "a,b,x" {
+		t.Fatalf("expected vertices a,b,x, found %v", ids)
+	}
+	if data.Nodes[2].Label != "</script><b>" || !data.Nodes[0].Flag {
+		t.Fatalf("unexpected vertices %+v", data.Nodes)
+	}
+	if data.Root != "x" || data.Omitted != 2 || len(data.Edges) != 2 {
+		t.Fatalf("unexpected data %+v", data)
+	}
+	if data.Colors.Flag != dotFlagColor {
+		t.Fatalf("unexpected colours %+v", data.Colors)
+	}
+
+	buf.Reset()
+	if err := graph.WriteHTML(&buf, &HTMLOptions{Root: "missing"}); !errors.Is(err, ErrVertexNotFound) {
+		t.Fatalf("expected ErrVertexNotFound, found %v", err)
+	}
+
+	buf.Reset()
+	if err := graph.WriteHTML(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<title>Graph</title>") {
+		t.Fatal("title is missing")
+	}
+}
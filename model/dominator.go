@@ -0,0 +1,223 @@
+package model
+
+import "fmt"
+
+// VirtualRoot is the ID of the virtual root of a dominator tree. It is
+// connected to every vertex without parents (or children, for post-dominators).
+const VirtualRoot = ""
+
+// DominatorTree holds the immediate dominator of every vertex reachable from its root.
+// A vertex a dominates a vertex b if every path from the root to b passes through a.
+type DominatorTree struct {
+	root  string
+	idom  map[string]string
+	depth map[string]int
+}
+
+// Dominators computes the dominator tree of the vertices reachable from the
+// root by following the children. Use VirtualRoot to start from every vertex
+// without parents.
+//
+// It uses the algorithm of Cooper, Harvey and Kennedy. In a DAG every vertex
+// can be processed once, in topological order.
+func (d *DAG) Dominators(root string) (*DominatorTree, error) {
+	order, err := d.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
+	return dominators(d, order, root, func(v *Vertex) map[string]struct{} {
+		return v.Parents
+	}, func(v *Vertex) map[string]struct{} {
+		return v.Children
+	})
+}
+
+// PostDominators computes the post-dominator tree of the vertices reachable
+// from the sink by following the parents. A vertex a post-dominates a vertex
+// b if every path from b to the sink passes through a. Use VirtualRoot to
+// end at every vertex without children.
+func (d *DAG) PostDominators(sink string) (*DominatorTree, error) {
+	order, err := d.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+
+	return dominators(d, order, sink, func(v *Vertex) map[string]struct{} {
+		return v.Children
+	}, func(v *Vertex) map[string]struct{} {
+		return v.Parents
+	})
+}
+
+// dominators computes the dominator tree, given the vertices in an order
+// where every vertex comes after its predecessors.
+func dominators(d *DAG, order []*Vertex, root string, preds, succs func(*Vertex) map[string]struct{}) (*DominatorTree, error) {
+	t := &DominatorTree{
+		root:  root,
+		idom:  make(map[string]string),
+		depth: make(map[string]int),
+	}
+
+	// Find the reachable vertices.
+	reachable := make(map[string]struct{})
+	if root == VirtualRoot {
+		for id := range d.vertices {
+			reachable[id] = struct{}{}
+		}
+	} else {
+		if _, err := d.GetVertex(root); err != nil {
+			return nil, err
+		}
+
+		q := []string{root}
+		reachable[root] = struct{}{}
+		for len(q) != 0 {
+			u := q[0]
+			q = q[1:len(q):len(q)]
+
+			for s := range succs(d.vertices[u]) {
+				if _, ok := d.vertices[s]; !ok {
+					continue
+				}
+				if _, ok := reachable[s]; !ok {
+					reachable[s] = struct{}{}
+					q = append(q, s)
+				}
+			}
+		}
+	}
+
+	t.depth[root] = 0
+
+	for _, v := range order {
+		if _, ok := reachable[v.ID]; !ok || v.ID == root {
+			continue
+		}
+
+		idom := root
+		first := true
+		for p := range preds(v) {
+			if _, ok := reachable[p]; !ok {
+				continue
+			}
+
+			if first {
+				idom = p
+				first = false
+				continue
+			}
+			idom = t.intersect(idom, p)
+		}
+
+		t.idom[v.ID] = idom
+		t.depth[v.ID] = t.depth[idom] + 1
+	}
+
+	return t, nil
+}
+
+// intersect returns the nearest common dominator of a and b.
+func (t *DominatorTree) intersect(a, b string) string {
+	for a != b {
+		for t.depth[a] > t.depth[b] {
+			a = t.idom[a]
+		}
+		for t.depth[b] > t.depth[a] {
+			b = t.idom[b]
+		}
+		if a != b {
+			a = t.idom[a]
+			b = t.idom[b]
+		}
+	}
+
+	return a
+}
+
+// Root returns the root of the tree, or VirtualRoot.
+func (t *DominatorTree) Root() string {
+	return t.root
+}
+
+// IDom returns the immediate dominator of the vertex. It returns false if the
+// vertex is the root, is not reachable from the root, or is only dominated by
+// the virtual root.
+func (t *DominatorTree) IDom(id string) (string, bool) {
+	idom, ok := t.idom[id]
+	if !ok || (idom == VirtualRoot && t.root == VirtualRoot) {
+		return "", false
+	}
+
+	return idom, true
+}
+
+// Dominators returns the strict dominators of the vertex, from the nearest
+// one to the root.
+func (t *DominatorTree) Dominators(id string) []string {
+	var list []string
+
+	for {
+		idom, ok := t.IDom(id)
+		if !ok {
+			return list
+		}
+
+		list = append(list, idom)
+		id = idom
+	}
+}
+
+// Dominates reports whether a dominates b. A vertex dominates itself.
+func (t *DominatorTree) Dominates(a, b string) bool {
+	if _, ok := t.depth[b]; !ok {
+		return false
+	}
+
+	for t.depth[b] > t.depth[a] {
+		b = t.idom[b]
+	}
+
+	return a == b
+}
+
+// MustPassAncestors returns the ancestors of the vertex which every path from
+// a vertex without parents to the vertex passes through, from the nearest one.
+func (d *DAG) MustPassAncestors(id string) ([]*Vertex, error) {
+	v, err := d.GetVertex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return MustPassAncestors(v, d.AncestorsBFS(id, nil))
+}
+
+// MustPassAncestors computes the must-pass ancestors of the vertex, given all
+// of its ancestors. See DAG.MustPassAncestors.
+func MustPassAncestors(v *Vertex, ancestors []*Vertex) ([]*Vertex, error) {
+	cone := NewDAG()
+	cone.vertices[v.ID] = v
+	for _, a := range ancestors {
+		cone.vertices[a.ID] = a
+	}
+
+	tree, err := cone.Dominators(VirtualRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*Vertex
+	for _, id := range tree.Dominators(v.ID) {
+		a, ok := cone.vertices[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrVertexNotFound, id)
+		}
+		list = append(list, a)
+	}
+
+	return list, nil
+}
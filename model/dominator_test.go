@@ -0,0 +1,112 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestGraph builds a graph from the edges, given as parent, child pairs.
+func newTestGraph(t *testing.T, edges ...[2]string) *DAG {
+	graph := NewDAG()
+
+	for _, e := range edges {
+		for _, id := range e {
+			if _, err := graph.GetVertex(id); err != nil {
+				graph.AddVertex(NewVertex(id, false, 0))
+			}
+		}
+
+		parent, _ := graph.GetVertex(e[0])
+		child, _ := graph.GetVertex(e[1])
+		if err := graph.AddEdge(parent, child); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return graph
+}
+
+func TestDominators(t *testing.T) {
+	graph := newTestGraph(t,
+		[2]string{"r1", "a"},
+		[2]string{"r2", "a"},
+		[2]string{"a", "b"},
+		[2]string{"a", "c"},
+		[2]string{"b", "d"},
+		[2]string{"c", "d"},
+		[2]string{"d", "e"},
+	)
+
+	tree, err := graph.Dominators(VirtualRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if idom, ok := tree.IDom("d"); !ok || idom != "a" {
+		t.Fatalf("expected idom of d to be a, found %q", idom)
+	}
+	if _, ok := tree.IDom("a"); ok {
+		t.Fatal("a is only dominated by the virtual root")
+	}
+	if !tree.Dominates("a", "e") || tree.Dominates("b", "d") {
+		t.Fatal("unexpected dominance")
+	}
+
+	tree, err = graph.Dominators("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tree.Dominators("e"); !reflect.DeepEqual(got, []string{"d", "b"}) {
+		t.Fatalf("expected dominators [d b], found %v", got)
+	}
+
+	post, err := graph.PostDominators(VirtualRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := post.Dominators("r1"); !reflect.DeepEqual(got, []string{"a", "d", "e"}) {
+		t.Fatalf("expected post-dominators [a d e], found %v", got)
+	}
+
+	list, err := graph.MustPassAncestors("e")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, v := range list {
+		ids = append(ids, v.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"d", "a"}) {
+		t.Fatalf("expected must-pass ancestors [d a], found %v", ids)
+	}
+}
+
+func TestTopologicalSort(t *testing.T) {
+	graph := GenerateGraph(1000)
+
+	order, err := graph.TopologicalSort()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	position := make(map[string]int)
+	for i, v := range order {
+		position[v.ID] = i
+	}
+
+	for _, v := range order {
+		for p := range v.Parents {
+			if position[p] > position[v.ID] {
+				t.Fatalf("parent %s after child %s", p, v.ID)
+			}
+		}
+	}
+
+	// Create a cycle, bypassing the checks of AddEdge.
+	a, b := order[0], order[len(order)-1]
+	a.Parents[b.ID] = struct{}{}
+	if _, err := graph.TopologicalSort(); err == nil {
+		t.Fatal("expected cycle error")
+	}
+}
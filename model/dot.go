@@ -0,0 +1,188 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DOTOptions configures WriteDOT. The zero value writes every vertex
+// labelled with its ID, and the edges from the parents to the children.
+type DOTOptions struct {
+	// Label returns the label of a vertex. Nil means the ID. See PropertyLabel.
+	Label func(v *Vertex) string
+
+	// ColorByFlag fills the vertices with a colour depending on their flag.
+	ColorByFlag bool
+
+	// GroupRanks puts the vertices of the same rank on the same row.
+	GroupRanks bool
+
+	// ChildToParent draws the edges from the children to the parents.
+	ChildToParent bool
+
+	// Highlight is a set of vertex IDs to highlight, e.g. the result of a
+	// query. The edges between two highlighted vertices are highlighted too.
+	// See HighlightSet.
+	Highlight map[string]struct{}
+}
+
+// PropertyLabel returns a label function which uses the property of the
+// vertex, or its ID if the vertex does not have the property.
+func PropertyLabel(key string) func(v *Vertex) string {
+	return func(v *Vertex) string {
+		if p, ok := v.Properties[key]; ok {
+			return p
+		}
+
+		return v.ID
+	}
+}
+
+// HighlightSet returns the set of the IDs of the vertices, for DOTOptions.Highlight.
+//
+//	highlight := HighlightSet(graph.List(id))
+//	highlight[id] = struct{}{}
+func HighlightSet(vertices []*Vertex) map[string]struct{} {
+	set := make(map[string]struct{}, len(vertices))
+	for _, v := range vertices {
+		set[v.ID] = struct{}{}
+	}
+
+	return set
+}
+
+const (
+	dotFlagColor      = "lightblue"
+	dotNoFlagColor    = "white"
+	dotHighlightColor = "red"
+)
+
+// WriteDOT writes the graph in the DOT language of Graphviz. The vertices are
+// written in order of rank and ID. The options may be nil.
+func (d *DAG) WriteDOT(w io.Writer, opts *DOTOptions) error {
+	if opts == nil {
+		opts = &DOTOptions{}
+	}
+
+	label := opts.Label
+	if label == nil {
+		label = func(v *Vertex) string {
+			return v.ID
+		}
+	}
+
+	vertices := make([]*Vertex, 0, len(d.vertices))
+	for _, v := range d.vertices {
+		vertices = append(vertices, v)
+	}
+	sort.Slice(vertices, func(i, j int) bool {
+		if vertices[i].Rank != vertices[j].Rank {
+			return vertices[i].Rank < vertices[j].Rank
+		}
+		return vertices[i].ID < vertices[j].ID
+	})
+
+	ew := &errWriter{w: w}
+
+	ew.printf("digraph DAG {\n")
+
+	for _, v := range vertices {
+		attrs := []string{"label=" + dotID(label(v))}
+
+		if opts.ColorByFlag {
+			color := dotNoFlagColor
+			if v.Flag {
+				color = dotFlagColor
+			}
+			attrs = append(attrs, "style=filled", "fillcolor="+dotID(color))
+		}
+
+		if _, ok := opts.Highlight[v.ID]; ok {
+			attrs = append(attrs, "color="+dotID(dotHighlightColor), "penwidth=2")
+		}
+
+		ew.printf("\t%s [%s];\n", dotID(v.ID), strings.Join(attrs, ", "))
+	}
+
+	if opts.GroupRanks {
+		for i := 0; i < len(vertices); {
+			j := i
+			for j < len(vertices) && vertices[j].Rank == vertices[i].Rank {
+				j++
+			}
+
+			ew.printf("\t{ rank=same;")
+			for _, v := range vertices[i:j] {
+				ew.printf(" %s;", dotID(v.ID))
+			}
+			ew.printf(" }\n")
+
+			i = j
+		}
+	}
+
+	for _, v := range vertices {
+		for _, p := range sortedSet(v.Parents) {
+			if _, ok := d.vertices[p]; !ok {
+				continue
+			}
+
+			from, to := p, v.ID
+			if opts.ChildToParent {
+				from, to = to, from
+			}
+
+			_, highlightParent := opts.Highlight[p]
+			_, highlightChild := opts.Highlight[v.ID]
+			if highlightParent && highlightChild {
+				ew.printf("\t%s -> %s [color=%s, penwidth=2];\n", dotID(from), dotID(to), dotID(dotHighlightColor))
+				continue
+			}
+
+			ew.printf("\t%s -> %s;\n", dotID(from), dotID(to))
+		}
+	}
+
+	ew.printf("}\n")
+
+	return ew.err
+}
+
+// dotID quotes the string as a DOT ID.
+func dotID(s string) string {
+	var b strings.Builder
+
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// errWriter keeps the first write error, so the writes do not have to be
+// checked one by one.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	graph := newTestGraph(t,
+		[2]string{"a", "b"},
+		[2]string{"a", "c"},
+	)
+
+	b, _ := graph.GetVertex("b")
+	b.Rank = 1
+	b.Flag = true
+	b.Properties = map[string]string{"name": `say "hi"`}
+	c, _ := graph.GetVertex("c")
+	c.Rank = 1
+
+	var buf bytes.Buffer
+	err := graph.WriteDOT(&buf, &DOTOptions{
+		Label:         PropertyLabel("name"),
+		ColorByFlag:   true,
+		GroupRanks:    true,
+		ChildToParent: true,
+		Highlight:     HighlightSet([]*Vertex{graph.vertices["a"], b}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `digraph DAG {
+	"a" [label="a", style=filled, fillcolor="white", color="red", penwidth=2];
+	"b" [label="say \"hi\"", style=filled, fillcolor="lightblue", color="red", penwidth=2];
+	"c" [label="c", style=filled, fillcolor="white"];
+	{ rank=same; "a"; }
+	{ rank=same; "b"; "c"; }
+	"b" -> "a" [color="red", penwidth=2];
+	"c" -> "a";
+}
+`
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nfound:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := graph.DOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = `digraph DAG {
+	"a" [label="a"];
+	"b" [label="b"];
+	"c" [label="c"];
+	"a" -> "b";
+	"a" -> "c";
+}
+`
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nfound:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteDOTError(t *testing.T) {
+	graph := GenerateGraph(10)
+
+	if err := graph.DOT(failingWriter{}); err == nil {
+		t.Fatal("expected write error")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
@@ -0,0 +1,768 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DOTReadOptions configures ReadDOT.
+type DOTReadOptions struct {
+	// ChildToParent reads the edges as going from the children to the parents.
+	ChildToParent bool
+}
+
+// DOTError is a syntax or graph error of a DOT input, at a line.
+type DOTError struct {
+	Line int
+	Msg  string
+}
+
+func (e *DOTError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// ReadDOT reads a directed graph in the DOT language of Graphviz. The options may be nil.
+//
+// The node attributes flag, rank and index are mapped to the fields of the
+// vertices, the other node attributes are stored as properties. The graph,
+// edge and subgraph attributes are ignored. An edge to or from a subgraph
+// connects every node of the subgraph.
+//
+// The error is a *DOTError if the input is malformed or has a cycle.
+func ReadDOT(r io.Reader, opts *DOTReadOptions) (*DAG, error) {
+	if opts == nil {
+		opts = &DOTReadOptions{}
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &dotParser{
+		lexer:     dotLexer{data: data, line: 1},
+		opts:      opts,
+		graph:     NewDAG(),
+		edgeLines: make(map[[2]string]int),
+	}
+
+	if err := p.parseGraph(); err != nil {
+		return nil, err
+	}
+
+	if err := p.checkCycle(); err != nil {
+		return nil, err
+	}
+
+	return p.graph, nil
+}
+
+type dotTokenType int
+
+const (
+	dotEOF dotTokenType = iota
+	dotIdent
+	dotPunct
+)
+
+type dotToken struct {
+	typ  dotTokenType
+	text string
+	// quoted is set for quoted and HTML strings, which are never keywords.
+	quoted bool
+	line   int
+}
+
+func (t dotToken) String() string {
+	if t.typ == dotEOF {
+		return "end of input"
+	}
+
+	return strconv.Quote(t.text)
+}
+
+// keyword reports whether the token is the keyword, which is case-insensitive.
+func (t dotToken) keyword(k string) bool {
+	return t.typ == dotIdent && !t.quoted && strings.EqualFold(t.text, k)
+}
+
+func (t dotToken) punct(p string) bool {
+	return t.typ == dotPunct && t.text == p
+}
+
+type dotLexer struct {
+	data []byte
+	pos  int
+	line int
+
+	peeked *dotToken
+}
+
+func (l *dotLexer) errorf(format string, args ...interface{}) error {
+	return &DOTError{Line: l.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *dotLexer) peek() (dotToken, error) {
+	if l.peeked == nil {
+		t, err := l.scan()
+		if err != nil {
+			return t, err
+		}
+		l.peeked = &t
+	}
+
+	return *l.peeked, nil
+}
+
+func (l *dotLexer) next() (dotToken, error) {
+	t, err := l.peek()
+	l.peeked = nil
+
+	return t, err
+}
+
+// skip skips the spaces and the comments.
+func (l *dotLexer) skip() error {
+	lineStart := l.pos == 0 || l.data[l.pos-1] == '\n'
+
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+			lineStart = true
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '#' && lineStart:
+			// Preprocessor output line.
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' {
+				l.pos++
+			}
+		case c == '/' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '/':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' {
+				l.pos++
+			}
+		case c == '/' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '*':
+			line := l.line
+			end := bytes.Index(l.data[l.pos+2:], []byte("*/"))
+			if end < 0 {
+				l.line = line
+				return l.errorf("unterminated comment")
+			}
+			comment := l.data[l.pos : l.pos+2+end+2]
+			l.line += bytes.Count(comment, []byte("\n"))
+			l.pos += len(comment)
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (l *dotLexer) scan() (dotToken, error) {
+	if err := l.skip(); err != nil {
+		return dotToken{}, err
+	}
+
+	if l.pos >= len(l.data) {
+		return dotToken{typ: dotEOF, line: l.line}, nil
+	}
+
+	line := l.line
+	c := l.data[l.pos]
+
+	switch {
+	case c == '-' && l.pos+1 < len(l.data) && (l.data[l.pos+1] == '>' || l.data[l.pos+1] == '-'):
+		l.pos += 2
+		return dotToken{typ: dotPunct, text: string(l.data[l.pos-2 : l.pos]), line: line}, nil
+	case strings.IndexByte("{}[]=;,:", c) >= 0:
+		l.pos++
+		return dotToken{typ: dotPunct, text: string(c), line: line}, nil
+	case c == '"':
+		s, err := l.scanQuoted()
+		if err != nil {
+			return dotToken{}, err
+		}
+		return dotToken{typ: dotIdent, text: s, quoted: true, line: line}, nil
+	case c == '<':
+		s, err := l.scanHTML()
+		if err != nil {
+			return dotToken{}, err
+		}
+		return dotToken{typ: dotIdent, text: s, quoted: true, line: line}, nil
+	case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		start := l.pos
+		l.pos++
+		for l.pos < len(l.data) && (l.data[l.pos] == '.' || (l.data[l.pos] >= '0' && l.data[l.pos] <= '9')) {
+			l.pos++
+		}
+		return dotToken{typ: dotIdent, text: string(l.data[start:l.pos]), line: line}, nil
+	case isDOTLetter(rune(c)):
+		start := l.pos
+		for l.pos < len(l.data) && (isDOTLetter(rune(l.data[l.pos])) || (l.data[l.pos] >= '0' && l.data[l.pos] <= '9')) {
+			l.pos++
+		}
+		return dotToken{typ: dotIdent, text: string(l.data[start:l.pos]), line: line}, nil
+	}
+
+	return dotToken{}, l.errorf("unexpected character %q", c)
+}
+
+func isDOTLetter(r rune) bool {
+	return r == '_' || r >= 0x80 || unicode.IsLetter(r)
+}
+
+// scanQuoted scans a double-quoted string, and the strings concatenated to it with '+'.
+func (l *dotLexer) scanQuoted() (string, error) {
+	var b strings.Builder
+
+	for {
+		line := l.line
+		l.pos++
+
+		for {
+			if l.pos >= len(l.data) {
+				l.line = line
+				return "", l.errorf("unterminated string")
+			}
+
+			c := l.data[l.pos]
+			if c == '"' {
+				l.pos++
+				break
+			}
+
+			if c == '\\' && l.pos+1 < len(l.data) {
+				switch l.data[l.pos+1] {
+				case '"':
+					b.WriteByte('"')
+					l.pos += 2
+					continue
+				case '\\':
+					b.WriteByte('\\')
+					l.pos += 2
+					continue
+				case 'n':
+					b.WriteByte('\n')
+					l.pos += 2
+					continue
+				case '\n':
+					// Line continuation.
+					l.line++
+					l.pos += 2
+					continue
+				}
+			}
+
+			if c == '\n' {
+				l.line++
+			}
+			b.WriteByte(c)
+			l.pos++
+		}
+
+		// Concatenation: "a" + "b".
+		save, saveLine := l.pos, l.line
+		if err := l.skip(); err != nil {
+			return "", err
+		}
+		if l.pos < len(l.data) && l.data[l.pos] == '+' {
+			l.pos++
+			if err := l.skip(); err != nil {
+				return "", err
+			}
+			if l.pos < len(l.data) && l.data[l.pos] == '"' {
+				continue
+			}
+			return "", l.errorf("expected a string after '+'")
+		}
+		l.pos, l.line = save, saveLine
+
+		return b.String(), nil
+	}
+}
+
+// scanHTML scans an HTML string, delimited by balanced angle brackets.
+func (l *dotLexer) scanHTML() (string, error) {
+	line := l.line
+	start := l.pos
+	depth := 0
+
+	for l.pos < len(l.data) {
+		switch l.data[l.pos] {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case '\n':
+			l.line++
+		}
+		l.pos++
+
+		if depth == 0 {
+			return string(l.data[start+1 : l.pos-1]), nil
+		}
+	}
+
+	l.line = line
+	return "", l.errorf("unterminated HTML string")
+}
+
+type dotParser struct {
+	lexer dotLexer
+	opts  *DOTReadOptions
+	graph *DAG
+
+	// The line of each edge, keyed by parent and child, to report cycles.
+	edgeLines map[[2]string]int
+
+	// The node created by the last operand, which is removed if the operand
+	// turns out to be the name of a graph attribute.
+	created string
+}
+
+func (p *dotParser) expect(punct string) (dotToken, error) {
+	t, err := p.lexer.next()
+	if err != nil {
+		return t, err
+	}
+
+	if !t.punct(punct) {
+		return t, &DOTError{Line: t.line, Msg: fmt.Sprintf("expected %q, found %v", punct, t)}
+	}
+
+	return t, nil
+}
+
+func (p *dotParser) parseGraph() error {
+	t, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+
+	if t.keyword("strict") {
+		if t, err = p.lexer.next(); err != nil {
+			return err
+		}
+	}
+
+	if t.keyword("graph") {
+		return &DOTError{Line: t.line, Msg: "undirected graphs are not supported"}
+	}
+	if !t.keyword("digraph") {
+		return &DOTError{Line: t.line, Msg: fmt.Sprintf("expected \"digraph\", found %v", t)}
+	}
+
+	// Optional graph ID.
+	if t, err = p.lexer.peek(); err != nil {
+		return err
+	}
+	if t.typ == dotIdent {
+		p.lexer.next()
+	}
+
+	if _, err := p.expect("{"); err != nil {
+		return err
+	}
+
+	if _, err := p.parseStatements(map[string]string{}); err != nil {
+		return err
+	}
+
+	if t, err = p.lexer.next(); err != nil {
+		return err
+	}
+	if t.typ != dotEOF {
+		return &DOTError{Line: t.line, Msg: fmt.Sprintf("unexpected %v after the graph", t)}
+	}
+
+	return nil
+}
+
+// parseStatements parses the statements until the closing brace, and returns
+// the nodes they reference. The defaults are the node attributes of the scope.
+func (p *dotParser) parseStatements(defaults map[string]string) ([]string, error) {
+	var nodes []string
+	seen := make(map[string]struct{})
+
+	add := func(ids []string) {
+		for _, id := range ids {
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				nodes = append(nodes, id)
+			}
+		}
+	}
+
+	for {
+		t, err := p.lexer.peek()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case t.punct("}"):
+			p.lexer.next()
+			return nodes, nil
+		case t.typ == dotEOF:
+			return nil, &DOTError{Line: t.line, Msg: "expected \"}\", found end of input"}
+		case t.punct(";"):
+			p.lexer.next()
+			continue
+		case t.keyword("graph") || t.keyword("edge"):
+			p.lexer.next()
+			if _, err := p.parseAttributes(); err != nil {
+				return nil, err
+			}
+			continue
+		case t.keyword("node"):
+			p.lexer.next()
+			attrs, err := p.parseAttributes()
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range attrs {
+				defaults[k] = v
+			}
+			continue
+		}
+
+		p.created = ""
+		ids, err := p.parseOperand(defaults)
+		if err != nil {
+			return nil, err
+		}
+
+		next, err := p.lexer.peek()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case next.punct("="):
+			// A graph attribute, e.g. rank=same.
+			if t.typ != dotIdent || len(ids) != 1 {
+				return nil, &DOTError{Line: next.line, Msg: "unexpected \"=\""}
+			}
+			p.lexer.next()
+			if _, err := p.expectID(); err != nil {
+				return nil, err
+			}
+			// The name of the attribute is not a node.
+			if p.created == ids[0] {
+				delete(p.graph.vertices, ids[0])
+			}
+			continue
+		case next.punct("->") || next.punct("--"):
+			add(ids)
+			edgeNodes, err := p.parseEdges(ids, defaults)
+			if err != nil {
+				return nil, err
+			}
+			add(edgeNodes)
+		case next.punct("["):
+			add(ids)
+			attrs, err := p.parseAttributes()
+			if err != nil {
+				return nil, err
+			}
+			if t.typ == dotIdent {
+				if err := p.setAttributes(ids[0], attrs, next.line); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			add(ids)
+		}
+	}
+}
+
+// parseOperand parses a node ID or a subgraph, and returns the node IDs.
+func (p *dotParser) parseOperand(defaults map[string]string) ([]string, error) {
+	t, err := p.lexer.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if t.keyword("subgraph") || t.punct("{") {
+		if t.keyword("subgraph") {
+			next, err := p.lexer.peek()
+			if err != nil {
+				return nil, err
+			}
+			if next.typ == dotIdent {
+				p.lexer.next()
+			}
+			if _, err := p.expect("{"); err != nil {
+				return nil, err
+			}
+		}
+
+		// A subgraph inherits the node attributes of its scope.
+		scoped := make(map[string]string, len(defaults))
+		for k, v := range defaults {
+			scoped[k] = v
+		}
+
+		return p.parseStatements(scoped)
+	}
+
+	if t.typ != dotIdent {
+		return nil, &DOTError{Line: t.line, Msg: fmt.Sprintf("unexpected %v", t)}
+	}
+
+	// Ignore the port, e.g. a:n or a:port:n.
+	for i := 0; i < 2; i++ {
+		next, err := p.lexer.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !next.punct(":") {
+			break
+		}
+		p.lexer.next()
+		if _, err := p.expectID(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.node(t.text, defaults, t.line); err != nil {
+		return nil, err
+	}
+
+	return []string{t.text}, nil
+}
+
+func (p *dotParser) expectID() (dotToken, error) {
+	t, err := p.lexer.next()
+	if err != nil {
+		return t, err
+	}
+
+	if t.typ != dotIdent {
+		return t, &DOTError{Line: t.line, Msg: fmt.Sprintf("expected an ID, found %v", t)}
+	}
+
+	return t, nil
+}
+
+// parseEdges parses the right-hand side of an edge statement, starting from
+// the nodes of the left-hand side, and returns all the nodes it references.
+func (p *dotParser) parseEdges(from []string, defaults map[string]string) ([]string, error) {
+	var nodes []string
+
+	for {
+		t, err := p.lexer.peek()
+		if err != nil {
+			return nil, err
+		}
+
+		if t.punct("--") {
+			return nil, &DOTError{Line: t.line, Msg: "undirected edges are not supported"}
+		}
+		if !t.punct("->") {
+			break
+		}
+		p.lexer.next()
+
+		to, err := p.parseOperand(defaults)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, to...)
+
+		for _, a := range from {
+			for _, b := range to {
+				if err := p.edge(a, b, t.line); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		from = to
+	}
+
+	// The attributes of the edges are ignored.
+	t, err := p.lexer.peek()
+	if err != nil {
+		return nil, err
+	}
+	if t.punct("[") {
+		if _, err := p.parseAttributes(); err != nil {
+			return nil, err
+		}
+	}
+
+	return nodes, nil
+}
+
+// parseAttributes parses one or more attribute lists, if any.
+func (p *dotParser) parseAttributes() (map[string]string, error) {
+	attrs := make(map[string]string)
+
+	for {
+		t, err := p.lexer.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !t.punct("[") {
+			return attrs, nil
+		}
+		p.lexer.next()
+
+		for {
+			t, err := p.lexer.next()
+			if err != nil {
+				return nil, err
+			}
+			if t.punct("]") {
+				break
+			}
+			if t.punct(",") || t.punct(";") {
+				continue
+			}
+			if t.typ != dotIdent {
+				return nil, &DOTError{Line: t.line, Msg: fmt.Sprintf("expected an attribute, found %v", t)}
+			}
+
+			if _, err := p.expect("="); err != nil {
+				return nil, err
+			}
+
+			value, err := p.expectID()
+			if err != nil {
+				return nil, err
+			}
+
+			attrs[t.text] = value.text
+		}
+	}
+}
+
+// node creates the node if it does not exist yet, with the default attributes.
+func (p *dotParser) node(id string, defaults map[string]string, line int) error {
+	if _, ok := p.graph.vertices[id]; ok {
+		return nil
+	}
+
+	p.graph.vertices[id] = NewVertex(id, false, 0)
+	p.created = id
+
+	return p.setAttributes(id, defaults, line)
+}
+
+func (p *dotParser) setAttributes(id string, attrs map[string]string, line int) error {
+	v := p.graph.vertices[id]
+
+	for k, value := range attrs {
+		var err error
+
+		switch k {
+		case "flag":
+			v.Flag, err = strconv.ParseBool(value)
+		case "rank":
+			v.Rank, err = strconv.Atoi(value)
+		case "index":
+			v.Index, err = strconv.Atoi(value)
+		default:
+			if v.Properties == nil {
+				v.Properties = make(map[string]string)
+			}
+			v.Properties[k] = value
+		}
+
+		if err != nil {
+			return &DOTError{Line: line, Msg: fmt.Sprintf("invalid %s of node %q: %q", k, id, value)}
+		}
+	}
+
+	return nil
+}
+
+func (p *dotParser) edge(a, b string, line int) error {
+	parent, child := a, b
+	if p.opts.ChildToParent {
+		parent, child = b, a
+	}
+
+	if parent == child {
+		return &DOTError{Line: line, Msg: fmt.Sprintf("edge %s -> %s is a cycle", a, b)}
+	}
+
+	pv, cv := p.graph.vertices[parent], p.graph.vertices[child]
+	pv.Children[child] = struct{}{}
+	cv.Parents[parent] = struct{}{}
+
+	if _, ok := p.edgeLines[[2]string{parent, child}]; !ok {
+		p.edgeLines[[2]string{parent, child}] = line
+	}
+
+	return nil
+}
+
+// checkCycle returns an error with the line of the edge closing a cycle, if there is one.
+func (p *dotParser) checkCycle() error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+
+	type frame struct {
+		id       string
+		children []string
+	}
+
+	state := make(map[string]int, len(p.graph.vertices))
+
+	for _, id := range sortedIDs(p.graph.vertices) {
+		if state[id] != 0 {
+			continue
+		}
+
+		state[id] = visiting
+		s := []frame{{id: id, children: sortedSet(p.graph.vertices[id].Children)}}
+
+		for len(s) != 0 {
+			f := &s[len(s)-1]
+
+			if len(f.children) == 0 {
+				state[f.id] = done
+				s = s[:len(s)-1]
+				continue
+			}
+
+			c := f.children[0]
+			f.children = f.children[1:]
+
+			switch state[c] {
+			case visiting:
+				// The cycle is the part of the stack from c, back to c.
+				var path []string
+				for i := range s {
+					if s[i].id == c || len(path) != 0 {
+						path = append(path, s[i].id)
+					}
+				}
+				path = append(path, c)
+
+				return &DOTError{
+					Line: p.edgeLines[[2]string{f.id, c}],
+					Msg:  fmt.Sprintf("edge (%s,%s) creates a cycle %s", f.id, c, strings.Join(path, " -> ")),
+				}
+			case 0:
+				state[c] = visiting
+				s = append(s, frame{id: c, children: sortedSet(p.graph.vertices[c].Children)})
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadDOT(t *testing.T) {
+	input := `/* A comment */
+strict digraph "test" {
+	graph [rankdir=LR];
+	node [shape=box];
+	rankdir = TB
+	a [flag=true, rank=0, index=1, label="A \"quoted\" label"];
+	// Edges to a subgraph.
+	a -> { b; c } [color=red];
+	b -> d -> e:n;
+	c -> d
+	subgraph cluster {
+		node [color="gre" + "en"]
+		e; f
+	}
+	e -> f
+}
+`
+
+	graph, err := ReadDOT(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := newTestGraph(t,
+		[2]string{"a", "b"},
+		[2]string{"a", "c"},
+		[2]string{"b", "d"},
+		[2]string{"c", "d"},
+		[2]string{"d", "e"},
+		[2]string{"e", "f"},
+	)
+	for _, v := range expected.vertices {
+		v.Properties = map[string]string{"shape": "box"}
+	}
+	expected.vertices["a"].Flag = true
+	expected.vertices["a"].Index = 1
+	expected.vertices["a"].Properties["label"] = `A "quoted" label`
+	expected.vertices["f"].Properties["color"] = "green"
+
+	if !reflect.DeepEqual(graph.Vertices(), expected.Vertices()) {
+		t.Fatalf("expected %v, found %v", expected.Vertices(), graph.Vertices())
+	}
+
+	graph, err = ReadDOT(strings.NewReader(`digraph { b -> a }`), &DOTReadOptions{ChildToParent: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := graph.vertices["a"].Children["b"]; !ok {
+		t.Fatal("expected the edge a -> b")
+	}
+}
+
+func TestReadDOTRoundTrip(t *testing.T) {
+	graph := GenerateGraph(50)
+
+	var buf bytes.Buffer
+	if err := graph.DOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadDOT(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(read.vertices) != len(graph.vertices) {
+		t.Fatalf("expected %d vertices, found %d", len(graph.vertices), len(read.vertices))
+	}
+
+	for id, v := range graph.vertices {
+		if !reflect.DeepEqual(read.vertices[id].Parents, v.Parents) {
+			t.Fatalf("expected the parents %v of %s, found %v", v.Parents, id, read.vertices[id].Parents)
+		}
+	}
+}
+
+func TestReadDOTErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  int
+		msg   string
+	}{
+		{"undirected", "graph {\n a -- b }", 1, "undirected graphs are not supported"},
+		{"undirected edge", "digraph {\n a -- b }", 2, "undirected edges are not supported"},
+		{"missing brace", "digraph {\n a -> b\n", 3, `expected "}", found end of input`},
+		{"bad attribute", "digraph {\n a [label]\n}", 2, `expected "=", found "]"`},
+		{"bad rank", "digraph {\n\n a [rank=x]\n}", 3, `invalid rank of node "a": "x"`},
+		{"unterminated string", "digraph {\n a [label=\"x\n\n}", 2, "unterminated string"},
+		{"unterminated comment", "digraph {\n /* a\n}", 2, "unterminated comment"},
+		{"trailing", "digraph { }\n}", 2, `unexpected "}" after the graph`},
+		{"self loop", "digraph {\n a -> a\n}", 2, "edge a -> a is a cycle"},
+		{"cycle", "digraph {\n a -> b\n b -> c\n c -> a\n}", 4, "edge (c,a) creates a cycle a -> b -> c -> a"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadDOT(strings.NewReader(test.input), nil)
+
+			var dotErr *DOTError
+			if !errors.As(err, &dotErr) {
+				t.Fatalf("expected a DOTError, found %v", err)
+			}
+
+			if dotErr.Line != test.line || dotErr.Msg != test.msg {
+				t.Fatalf("expected line %d: %s, found %v", test.line, test.msg, err)
+			}
+		})
+	}
+}
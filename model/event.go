@@ -0,0 +1,162 @@
+package model
+
+import "sync"
+
+// EventType identifies the kind of mutation an Event describes.
+type EventType int
+
+const (
+	EventVertexAdded EventType = iota
+	EventVertexDeleted
+	EventEdgeAdded
+	EventEdgeDeleted
+	EventGraphReplaced
+	EventVertexUpdated
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventVertexAdded:
+		return "VertexAdded"
+	case EventVertexDeleted:
+		return "VertexDeleted"
+	case EventEdgeAdded:
+		return "EdgeAdded"
+	case EventEdgeDeleted:
+		return "EdgeDeleted"
+	case EventGraphReplaced:
+		return "GraphReplaced"
+	case EventVertexUpdated:
+		return "VertexUpdated"
+	}
+
+	return "Unknown"
+}
+
+// Event is a notification about a mutation of a graph.
+// Use a type switch on the concrete type to get the payload.
+type Event interface {
+	Type() EventType
+}
+
+// VertexAdded is emitted after a vertex has been added.
+type VertexAdded struct {
+	Vertex *Vertex
+}
+
+func (VertexAdded) Type() EventType { return EventVertexAdded }
+
+// VertexDeleted is emitted after a vertex has been deleted.
+type VertexDeleted struct {
+	Vertex *Vertex
+}
+
+func (VertexDeleted) Type() EventType { return EventVertexDeleted }
+
+// VertexUpdated is emitted after the flag, rank, index or properties of a
+// vertex have been updated. Old is a copy of the vertex before the update.
+type VertexUpdated struct {
+	Old    *Vertex
+	Vertex *Vertex
+}
+
+func (VertexUpdated) Type() EventType { return EventVertexUpdated }
+
+// EdgeAdded is emitted after the edge (Parent, Child) has been added.
+type EdgeAdded struct {
+	Parent *Vertex
+	Child  *Vertex
+}
+
+func (EdgeAdded) Type() EventType { return EventEdgeAdded }
+
+// EdgeDeleted is emitted after the edge (Parent, Child) has been deleted.
+type EdgeDeleted struct {
+	Parent *Vertex
+	Child  *Vertex
+}
+
+func (EdgeDeleted) Type() EventType { return EventEdgeDeleted }
+
+// GraphReplaced is emitted after the whole graph has been replaced,
+// e.g. by GraphStore.Insert.
+type GraphReplaced struct {
+	Graph *DAG
+}
+
+func (GraphReplaced) Type() EventType { return EventGraphReplaced }
+
+// Observer receives events. Notify is called synchronously by the goroutine
+// that made the mutation, after the mutation has been applied.
+type Observer interface {
+	Notify(e Event)
+}
+
+// ObserverFunc adapts a function into an Observer.
+type ObserverFunc func(e Event)
+
+func (f ObserverFunc) Notify(e Event) {
+	f(e)
+}
+
+// ChanObserver delivers events through a buffered channel, so they can be
+// consumed by another goroutine. Notify blocks when the buffer is full.
+type ChanObserver struct {
+	C chan Event
+}
+
+func NewChanObserver(size int) *ChanObserver {
+	return &ChanObserver{
+		C: make(chan Event, size),
+	}
+}
+
+func (c *ChanObserver) Notify(e Event) {
+	c.C <- e
+}
+
+// Observers is a list of subscribed observers. The zero value is ready to use
+// and it is safe for concurrent use.
+type Observers struct {
+	mu   sync.RWMutex
+	next int
+	list []subscription
+}
+
+type subscription struct {
+	id       int
+	observer Observer
+}
+
+// Subscribe adds the observer to the list. The returned function removes it.
+func (o *Observers) Subscribe(observer Observer) func() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	id := o.next
+	o.next++
+	o.list = append(o.list, subscription{id: id, observer: observer})
+
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		for i := range o.list {
+			if o.list[i].id == id {
+				o.list = append(o.list[:i:i], o.list[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Emit delivers the event to every observer, in subscription order.
+func (o *Observers) Emit(e Event) {
+	o.mu.RLock()
+	list := o.list
+	o.mu.RUnlock()
+
+	for _, s := range list {
+		s.observer.Notify(e)
+	}
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestObserver(t *testing.T) {
+	graph := NewDAG()
+
+	var events []Event
+	unsubscribe := graph.Subscribe(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	}))
+
+	a := NewVertex("a", false, 0)
+	b := NewVertex("b", true, 1)
+	graph.AddVertex(a)
+	graph.AddVertex(b)
+
+	if err := graph.AddEdge(a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	graph.DeleteEdge(a, b)
+
+	if err := graph.DeleteVertex(b); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []EventType{EventVertexAdded, EventVertexAdded, EventEdgeAdded, EventEdgeDeleted, EventVertexDeleted}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, found %d", len(expected), len(events))
+	}
+	for i := range expected {
+		if events[i].Type() != expected[i] {
+			t.Fatalf("expected event %d to be %v, found %v", i, expected[i], events[i].Type())
+		}
+	}
+
+	e, ok := events[2].(EdgeAdded)
+	if !ok || e.Parent != a || e.Child != b {
+		t.Fatalf("unexpected payload %+v", events[2])
+	}
+
+	if len(b.Parents) != 0 {
+		t.Fatal("deleted edge must be removed from the child's parents")
+	}
+
+	unsubscribe()
+	graph.AddVertex(b)
+	if len(events) != len(expected) {
+		t.Fatal("unsubscribed observer must not receive events")
+	}
+}
+
+func TestChanObserver(t *testing.T) {
+	graph := NewDAG()
+
+	o := NewChanObserver(1)
+	defer graph.Subscribe(o)()
+
+	v := NewVertex("a", false, 0)
+	graph.AddVertex(v)
+
+	e := <-o.C
+	if added, ok := e.(VertexAdded); !ok || added.Vertex != v {
+		t.Fatalf("unexpected event %+v", e)
+	}
+}
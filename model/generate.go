@@ -1,97 +1,214 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
 	"math/rand"
 	"time"
 )
 
+// GeneratorOptions configures Generate. The same options always generate the same graph.
+type GeneratorOptions struct {
+	// Seed of the random source.
+	Seed int64
+
+	// Rand, if set, is used instead of a source seeded with Seed. The IDs are
+	// still derived from Seed.
+	Rand *rand.Rand
+
+	// Number of vertices.
+	Size int
+
+	// Number of vertices per rank, except the first and the last ranks which
+	// only have one vertex. Once a rank has MinRankWidth vertices, it is
+	// closed with a probability of 1/2 after each vertex, and it is always
+	// closed at MaxRankWidth vertices.
+	MinRankWidth int
+	MaxRankWidth int
+
+	// Parents returns the number of parents of a vertex. Nil means one parent,
+	// which generates a tree. It is capped to the number of vertices of the
+	// lower ranks.
+	Parents func(r *rand.Rand) int
+
+	// Probability of the flag of a vertex to be true.
+	FlagProbability float64
+
+	// ID returns the ID of the vertex with the index, and is called again
+	// with the index of a vertex for the ID of a parent, so it must always
+	// return the same ID for an index, and a different ID for every index.
+	// Nil means HexID.
+	ID func(seed int64, index int) string
+}
+
+// DefaultGeneratorOptions returns the options used by GenerateGraph, without a seed.
+func DefaultGeneratorOptions(size int) GeneratorOptions {
+	return GeneratorOptions{
+		Size:            size,
+		MinRankWidth:    10,
+		MaxRankWidth:    30,
+		FlagProbability: 0.5,
+	}
+}
+
+// UniformParents returns a parent count distribution, uniform between min and max inclusive.
+func UniformParents(min, max int) func(r *rand.Rand) int {
+	return func(r *rand.Rand) int {
+		return min + r.Intn(max-min+1)
+	}
+}
+
+// HexID derives an ID of 64 hexadecimal characters from the seed and the index.
+func HexID(seed int64, index int) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(seed))
+	binary.BigEndian.PutUint64(b[8:], uint64(index))
+
+	return fmt.Sprintf("%X", sha256.Sum256(b[:]))
+}
+
 // Generate a DAG. It will have size vertices and size - 1 edges
 func GenerateGraph(size int) *DAG {
-	rand.Seed(time.Now().UnixNano())
+	opts := DefaultGeneratorOptions(size)
+	opts.Seed = time.Now().UnixNano()
 
-	graph := NewDAG()
+	graph, err := Generate(opts)
+	if err != nil {
+		// The default options are valid.
+		panic(err)
+	}
 
-	// To keep track vertices for each rank.
-	rankVertices := make(map[int][]*Vertex)
+	return graph
+}
 
-	rank := 0
-	// Keep track of the vertices count for a rank
-	rankVertexCount := 0
+// Generate a DAG with the options.
+// The parents of a vertex always have a lower rank than the vertex's rank.
+func Generate(opts GeneratorOptions) (*DAG, error) {
+	graph := NewDAG()
 
-	for vertexCount := 1; vertexCount <= size; {
-		// Generate random ID
-		id := randID()
+	err := opts.generate(func(v *Vertex) error {
+		if _, ok := graph.vertices[v.ID]; ok {
+			return fmt.Errorf("vertex %s with index %d already exists", v.ID, v.Index)
+		}
 
-		// Check if ID already exist.
-		_, ok := graph.vertices[id]
-		if ok {
-			continue
+		// The parents have been generated before the vertex, unless the ID
+		// function is not consistent.
+		for p := range v.Parents {
+			pv, ok := graph.vertices[p]
+			if !ok {
+				return fmt.Errorf("parent %s of vertex %s with index %d does not exist", p, v.ID, v.Index)
+			}
+			pv.Children[v.ID] = struct{}{}
 		}
 
-		// Create the vertex
-		v := NewVertex(id, randBool(), rank)
-		v.Index = vertexCount
 		graph.vertices[v.ID] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		rankVertices[rank] = append(rankVertices[rank], v)
+	return graph, nil
+}
 
-		vertexCount++
-		rankVertexCount++
+// GenerateStream generates the same graph as Generate, but emits the
+// vertices one by one instead of building the graph, so the graph does not
+// have to fit in memory. The vertices are emitted rank by rank, in order of
+// index, with their parents set. The parents of a vertex are always emitted
+// before the vertex. The children are not set.
+func GenerateStream(opts GeneratorOptions, emit func(v *Vertex) error) error {
+	return opts.generate(emit)
+}
 
-		// Increase the rank. The rules:
-		// - First rank should only have one vertex
-		// - Last rank should only have one vertex
-		// - Min vertices per rank is 10
-		// - Max vertices per rank is 30
-		if rank == 0 || vertexCount == size || (rankVertexCount >= 10 && randBool()) || rankVertexCount > 30 {
-			rank++
-			rankVertexCount = 0
-		}
+func (opts GeneratorOptions) validate() error {
+	if opts.Size < 0 {
+		return fmt.Errorf("size %d is negative", opts.Size)
 	}
 
-	// Add a parent to each vertex.
-	// The parent of a vertex must have lower rank than the vertex's rank.
-	for _, v := range graph.vertices {
-		// The first vertex cannot have parents
-		if v.Rank == 0 {
-			continue
-		}
-		// Choose a random rank, lower than the vertex' rank
-		randomRank := rand.Intn(v.Rank)
+	if opts.MinRankWidth < 1 || opts.MaxRankWidth < opts.MinRankWidth {
+		return fmt.Errorf("invalid rank width range [%d, %d]", opts.MinRankWidth, opts.MaxRankWidth)
+	}
 
-		// Get the list of vertices on that rank.
-		vertices := rankVertices[randomRank]
+	if opts.FlagProbability < 0 || opts.FlagProbability > 1 {
+		return fmt.Errorf("flag probability %v is not between 0 and 1", opts.FlagProbability)
+	}
 
-		// Choose a random vertice from the list
-		randomVertice := randVertex(vertices)
+	return nil
+}
 
-		// Add the random vertex as parent
-		// v.Parents = append(v.Parents, randomVertice.ID)
-		v.Parents[randomVertice.ID] = struct{}{}
+// generate emits the vertices in order of index, starting from 1. The parents
+// of a vertex are set, and are always emitted before the vertex. Only the
+// first index of each rank is kept, rather than the vertices, so the memory
+// grows with the number of ranks, at most Size / MinRankWidth.
+func (opts GeneratorOptions) generate(emit func(v *Vertex) error) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
 
-		// Update the children of the random vertex
-		randomVertice.Children[v.ID] = struct{}{}
+	r := opts.Rand
+	if r == nil {
+		r = rand.New(rand.NewSource(opts.Seed))
 	}
 
-	return graph
-}
+	id := opts.ID
+	if id == nil {
+		id = HexID
+	}
 
-const letters = "0123456789ABCDEF"
+	// The first index of each rank. The current rank ends at the last emitted vertex.
+	rankStarts := []int{1}
 
-func randID() string {
-	b := make([]byte, 64)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(b)
-}
+	rank := 0
+	// Keep track of the vertices count for a rank
+	rankVertexCount := 0
 
-func randBool() bool {
-	return rand.Intn(2) == 1
-}
+	for index := 1; index <= opts.Size; index++ {
+		// Create the vertex
+		v := NewVertex(id(opts.Seed, index), r.Float64() < opts.FlagProbability, rank)
+		v.Index = index
+
+		// The first vertex cannot have parents
+		if rank != 0 {
+			count := 1
+			if opts.Parents != nil {
+				count = opts.Parents(r)
+			}
+
+			// Only the vertices of the lower ranks can be parents.
+			lower := rankStarts[rank] - 1
+			if count > lower {
+				count = lower
+			}
+
+			for len(v.Parents) < count {
+				// Choose a random rank, lower than the vertex' rank, then a
+				// random vertex of that rank.
+				randomRank := r.Intn(rank)
+				start, end := rankStarts[randomRank], rankStarts[randomRank+1]
+				parent := start + r.Intn(end-start)
+
+				v.Parents[id(opts.Seed, parent)] = struct{}{}
+			}
+		}
+
+		if err := emit(v); err != nil {
+			return err
+		}
+
+		rankVertexCount++
+
+		// Increase the rank. The rules:
+		// - First rank should only have one vertex
+		// - Last rank should only have one vertex
+		// - Min and max vertices per rank are from the options
+		if rank == 0 || index+1 == opts.Size ||
+			(rankVertexCount >= opts.MinRankWidth && r.Intn(2) == 1) || rankVertexCount >= opts.MaxRankWidth {
+			rank++
+			rankVertexCount = 0
+			rankStarts = append(rankStarts, index+1)
+		}
+	}
 
-// Choose a random vertex from the list of vertices
-func randVertex(list []*Vertex) *Vertex {
-	n := rand.Intn(len(list))
-	return list[n]
+	return nil
 }
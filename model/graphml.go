@@ -0,0 +1,342 @@
+package model
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const graphMLNamespace = "http://graphml.graphdrawing.org/xmlns"
+
+// The GraphML keys of the fields of the vertices. The properties use the
+// keys d_p0, d_p1, ... named after the property, so a property may be named
+// like a field.
+const (
+	graphMLFlagKey     = "d_flag"
+	graphMLRankKey     = "d_rank"
+	graphMLIndexKey    = "d_index"
+	graphMLPropertyKey = "d_p"
+)
+
+type graphMLDocument struct {
+	XMLName xml.Name       `xml:"graphml"`
+	XMLNS   string         `xml:"xmlns,attr,omitempty"`
+	Keys    []graphMLKey   `xml:"key"`
+	Graphs  []graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID      string `xml:"id,attr"`
+	For     string `xml:"for,attr,omitempty"`
+	Name    string `xml:"attr.name,attr,omitempty"`
+	Type    string `xml:"attr.type,attr,omitempty"`
+	Default string `xml:"default,omitempty"`
+
+	// Set by yEd for its graphics, which are not attributes.
+	YFilesType string `xml:"yfiles.type,attr,omitempty"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr,omitempty"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+
+	// A nested graph, e.g. a group of yEd.
+	Graph *graphMLGraph `xml:"graph,omitempty"`
+}
+
+type graphMLEdge struct {
+	ID       string `xml:"id,attr,omitempty"`
+	Source   string `xml:"source,attr"`
+	Target   string `xml:"target,attr"`
+	Directed string `xml:"directed,attr,omitempty"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph in GraphML, e.g. for Gephi or yEd. The flag,
+// rank and index of the vertices, and their properties, are written as node
+// attributes. The vertices are written in order of ID.
+func (d *DAG) WriteGraphML(w io.Writer) error {
+	ids := sortedIDs(d.vertices)
+
+	// The keys of the properties, in order of name.
+	propertyKeys := make(map[string]string)
+	var names []string
+	for _, v := range d.vertices {
+		for name := range v.Properties {
+			if _, ok := propertyKeys[name]; !ok {
+				propertyKeys[name] = ""
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	doc := graphMLDocument{
+		XMLNS: graphMLNamespace,
+		Keys: []graphMLKey{
+			{ID: graphMLFlagKey, For: "node", Name: "flag", Type: "boolean"},
+			{ID: graphMLRankKey, For: "node", Name: "rank", Type: "int"},
+			{ID: graphMLIndexKey, For: "node", Name: "index", Type: "int"},
+		},
+	}
+	for i, name := range names {
+		propertyKeys[name] = fmt.Sprintf("%s%d", graphMLPropertyKey, i)
+		doc.Keys = append(doc.Keys, graphMLKey{ID: propertyKeys[name], For: "node", Name: name, Type: "string"})
+	}
+
+	graph := graphMLGraph{ID: "G", EdgeDefault: "directed"}
+
+	for _, id := range ids {
+		v := d.vertices[id]
+
+		node := graphMLNode{
+			ID: id,
+			Data: []graphMLData{
+				{Key: graphMLFlagKey, Value: strconv.FormatBool(v.Flag)},
+				{Key: graphMLRankKey, Value: strconv.Itoa(v.Rank)},
+				{Key: graphMLIndexKey, Value: strconv.Itoa(v.Index)},
+			},
+		}
+
+		props := make([]string, 0, len(v.Properties))
+		for name := range v.Properties {
+			props = append(props, name)
+		}
+		sort.Strings(props)
+
+		for _, name := range props {
+			node.Data = append(node.Data, graphMLData{Key: propertyKeys[name], Value: v.Properties[name]})
+		}
+
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	for _, id := range ids {
+		for _, c := range sortedSet(d.vertices[id].Children) {
+			if _, ok := d.vertices[c]; !ok {
+				continue
+			}
+
+			graph.Edges = append(graph.Edges, graphMLEdge{
+				ID:     fmt.Sprintf("e%d", len(graph.Edges)),
+				Source: id,
+				Target: c,
+			})
+		}
+	}
+
+	doc.Graphs = []graphMLGraph{graph}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// ReadGraphML reads a directed graph in GraphML. The node attributes of the
+// keys of the fields written by WriteGraphML, or else named flag, rank and
+// index, are mapped to the fields of the vertices. The other node attributes,
+// and those of the keys of the properties written by WriteGraphML, are stored
+// as properties, with their default values. The
+// nodes of nested graphs are added to the graph, the edge attributes, the
+// hyperedges and the graphics of yEd are ignored. A repeated edge is added
+// once.
+//
+// It returns an error if an edge is undirected or the graph has a cycle.
+func ReadGraphML(r io.Reader) (*DAG, error) {
+	var doc graphMLDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("graphml: %w", err)
+	}
+
+	if len(doc.Graphs) == 0 {
+		return nil, fmt.Errorf("graphml: missing graph")
+	}
+
+	// The node attributes, by key ID.
+	keys := make(map[string]graphMLKey)
+	for _, k := range doc.Keys {
+		if (k.For == "node" || k.For == "all" || k.For == "") && k.YFilesType == "" {
+			keys[k.ID] = k
+		}
+	}
+
+	d := NewDAG()
+
+	for i := range doc.Graphs {
+		if err := readGraphMLNodes(d, &doc.Graphs[i], keys); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range doc.Graphs {
+		if err := readGraphMLEdges(d, &doc.Graphs[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := d.TopologicalSort(); err != nil {
+		return nil, fmt.Errorf("graphml: %w", err)
+	}
+
+	return d, nil
+}
+
+// readGraphMLNodes adds the nodes of the graph and of its nested graphs.
+func readGraphMLNodes(d *DAG, g *graphMLGraph, keys map[string]graphMLKey) error {
+	for _, node := range g.Nodes {
+		if node.ID == "" {
+			return fmt.Errorf("graphml: node without id")
+		}
+		if _, ok := d.vertices[node.ID]; ok {
+			return fmt.Errorf("graphml: duplicate node %q", node.ID)
+		}
+
+		v, err := graphMLVertex(node, keys)
+		if err != nil {
+			return err
+		}
+
+		d.AddVertex(v)
+
+		if node.Graph != nil {
+			if err := readGraphMLNodes(d, node.Graph, keys); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readGraphMLEdges adds the edges of the graph and of its nested graphs,
+// once all the nodes are added.
+func readGraphMLEdges(d *DAG, g *graphMLGraph) error {
+	for _, e := range g.Edges {
+		directed := g.EdgeDefault == "directed"
+		if e.Directed != "" {
+			directed = e.Directed == "true"
+		}
+		if !directed {
+			return fmt.Errorf("graphml: edge (%s,%s) is undirected", e.Source, e.Target)
+		}
+
+		parent, ok := d.vertices[e.Source]
+		if !ok {
+			return fmt.Errorf("graphml: edge (%s,%s): %w: %s", e.Source, e.Target, ErrVertexNotFound, e.Source)
+		}
+
+		child, ok := d.vertices[e.Target]
+		if !ok {
+			return fmt.Errorf("graphml: edge (%s,%s): %w: %s", e.Source, e.Target, ErrVertexNotFound, e.Target)
+		}
+
+		if _, ok := parent.Children[child.ID]; ok {
+			continue
+		}
+
+		if err := d.AddEdge(parent, child); err != nil {
+			return fmt.Errorf("graphml: %w", err)
+		}
+	}
+
+	for _, node := range g.Nodes {
+		if node.Graph != nil {
+			if err := readGraphMLEdges(d, node.Graph); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func graphMLVertex(node graphMLNode, keys map[string]graphMLKey) (*Vertex, error) {
+	v := NewVertex(node.ID, false, 0)
+
+	values := make(map[string]string)
+	for id, k := range keys {
+		if k.Default != "" {
+			values[id] = k.Default
+		}
+	}
+	for _, data := range node.Data {
+		if _, ok := keys[data.Key]; ok {
+			values[data.Key] = data.Value
+		}
+	}
+
+	for id, value := range values {
+		name := keys[id].Name
+		if name == "" {
+			name = id
+		}
+
+		var err error
+
+		switch graphMLField(id, name) {
+		case "flag":
+			v.Flag, err = strconv.ParseBool(strings.TrimSpace(value))
+		case "rank":
+			v.Rank, err = strconv.Atoi(strings.TrimSpace(value))
+		case "index":
+			v.Index, err = strconv.Atoi(strings.TrimSpace(value))
+		default:
+			if v.Properties == nil {
+				v.Properties = make(map[string]string)
+			}
+			v.Properties[name] = value
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("graphml: node %q: invalid %s %q", node.ID, name, value)
+		}
+	}
+
+	return v, nil
+}
+
+// graphMLField returns the field of the vertices of the key, or "" for a
+// property. The keys of WriteGraphML are resolved by ID, the others by name.
+func graphMLField(id, name string) string {
+	switch {
+	case id == graphMLFlagKey:
+		return "flag"
+	case id == graphMLRankKey:
+		return "rank"
+	case id == graphMLIndexKey:
+		return "index"
+	case strings.HasPrefix(id, graphMLPropertyKey):
+		return ""
+	}
+
+	switch name {
+	case "flag", "rank", "index":
+		return name
+	}
+
+	return ""
+}
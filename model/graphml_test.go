@@ -0,0 +1,154 @@
+package model
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGraphML(t *testing.T) {
+	graph := newTestGraph(t,
+		[2]string{"a", "b"},
+		[2]string{"a", "c"},
+	)
+	graph.vertices["a"].Flag = true
+	graph.vertices["a"].Index = 1
+	graph.vertices["b"].Rank = 1
+	graph.vertices["b"].Properties = map[string]string{"name": "<B & b>"}
+
+	var buf bytes.Buffer
+	if err := graph.WriteGraphML(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="d_flag" for="node" attr.name="flag" attr.type="boolean"></key>
+  <key id="d_rank" for="node" attr.name="rank" attr.type="int"></key>
+  <key id="d_index" for="node" attr.name="index" attr.type="int"></key>
+  <key id="d_p0" for="node" attr.name="name" attr.type="string"></key>
+  <graph id="G" edgedefault="directed">
+    <node id="a">
+      <data key="d_flag">true</data>
+      <data key="d_rank">0</data>
+      <data key="d_index">1</data>
+    </node>
+    <node id="b">
+      <data key="d_flag">false</data>
+      <data key="d_rank">1</data>
+      <data key="d_index">0</data>
+      <data key="d_p0">&lt;B &amp; b&gt;</data>
+    </node>
+    <node id="c">
+      <data key="d_flag">false</data>
+      <data key="d_rank">0</data>
+      <data key="d_index">0</data>
+    </node>
+    <edge id="e0" source="a" target="b"></edge>
+    <edge id="e1" source="a" target="c"></edge>
+  </graph>
+</graphml>
+`
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nfound:\n%s", expected, buf.String())
+	}
+
+	read, err := ReadGraphML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(read.Vertices(), graph.Vertices()) {
+		t.Fatalf("expected %v, found %v", graph.Vertices(), read.Vertices())
+	}
+}
+
+func TestGraphMLFieldProperties(t *testing.T) {
+	// The properties named like the fields are kept apart from the fields.
+	graph := newTestGraph(t, [2]string{"a", "b"})
+	graph.vertices["a"].Rank = 2
+	graph.vertices["a"].Properties = map[string]string{"flag": "x", "rank": "first", "index": "", "name": "a"}
+	graph.vertices["b"].Flag = true
+	graph.vertices["b"].Properties = map[string]string{"index": "7"}
+
+	var buf bytes.Buffer
+	if err := graph.WriteGraphML(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadGraphML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(read.Vertices(), graph.Vertices()) {
+		t.Fatalf("expected %v, found %v", graph.Vertices(), read.Vertices())
+	}
+}
+
+func TestReadGraphML(t *testing.T) {
+	// As written by yEd, with a group, graphics and a default value.
+	input := `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns" xmlns:y="http://www.yworks.com/xml/graphml">
+  <key for="node" id="d0" attr.name="color" attr.type="string"><default>white</default></key>
+  <key for="node" id="d1" yfiles.type="nodegraphics"/>
+  <key for="edge" id="d2" attr.name="weight" attr.type="double"/>
+  <graph edgedefault="directed" id="G">
+    <node id="a">
+      <data key="d0">red</data>
+      <data key="d1"><y:ShapeNode><y:NodeLabel>A</y:NodeLabel></y:ShapeNode></data>
+    </node>
+    <node id="group">
+      <graph edgedefault="directed" id="group:">
+        <node id="b"/>
+      </graph>
+    </node>
+    <edge source="a" target="b"><data key="d2">1.5</data></edge>
+    <edge source="a" target="b"/>
+  </graph>
+</graphml>`
+
+	graph, err := ReadGraphML(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewVertex("a", false, 0)
+	a.Properties = map[string]string{"color": "red"}
+	a.Children["b"] = struct{}{}
+	b := NewVertex("b", false, 0)
+	b.Properties = map[string]string{"color": "white"}
+	b.Parents["a"] = struct{}{}
+	group := NewVertex("group", false, 0)
+	group.Properties = map[string]string{"color": "white"}
+
+	expected := map[string]*Vertex{"a": a, "b": b, "group": group}
+	if !reflect.DeepEqual(graph.Vertices(), expected) {
+		t.Fatalf("expected %v, found %v", expected, graph.Vertices())
+	}
+}
+
+func TestReadGraphMLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"malformed", `<graphml><graph>`, "graphml: XML syntax error"},
+		{"no graph", `<graphml></graphml>`, "missing graph"},
+		{"undirected", `<graphml><graph edgedefault="undirected"><node id="a"/><node id="b"/><edge source="a" target="b"/></graph></graphml>`, "edge (a,b) is undirected"},
+		{"missing node", `<graphml><graph edgedefault="directed"><node id="a"/><edge source="a" target="b"/></graph></graphml>`, "vertex does not exist: b"},
+		{"bad flag", `<graphml><key id="f" attr.name="flag"/><graph edgedefault="directed"><node id="a"><data key="f">x</data></node></graph></graphml>`, `node "a": invalid flag "x"`},
+		{"cycle", `<graphml><graph edgedefault="directed"><node id="a"/><node id="b"/><edge source="a" target="b"/><edge source="b" target="a"/></graph></graphml>`, "cycle"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadGraphML(strings.NewReader(test.input))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected an error with %q, found %v", test.err, err)
+			}
+		})
+	}
+}
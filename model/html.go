@@ -0,0 +1,174 @@
+package model
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"sort"
+)
+
+// HTMLOptions configures WriteHTML. The zero value writes the whole graph,
+// labelled with the IDs.
+type HTMLOptions struct {
+	// Title is the title of the page. Empty means "Graph", or the root.
+	Title string
+
+	// The selection of the vertices, like in DiagramOptions.
+	Root        string
+	Descendants bool
+	Depth       int
+	MaxVertices int
+
+	// Label returns the label of a vertex. Nil means the ID. See PropertyLabel.
+	Label func(v *Vertex) string
+
+	Layout LayoutOptions
+}
+
+//go:embed html.tmpl
+var htmlSource string
+
+var htmlTemplate = template.Must(template.New("viewer").Parse(htmlSource))
+
+// The data of the viewer, embedded in the page as JSON.
+type htmlGraph struct {
+	Title      string      `json:"title"`
+	Root       string      `json:"root,omitempty"`
+	Omitted    int         `json:"omitted"`
+	Width      float64     `json:"width"`
+	Height     float64     `json:"height"`
+	NodeWidth  float64     `json:"nodeWidth"`
+	NodeHeight float64     `json:"nodeHeight"`
+	Nodes      []htmlNode  `json:"nodes"`
+	Edges      []htmlEdge  `json:"edges"`
+	Colors     *htmlColors `json:"colors"`
+}
+
+type htmlNode struct {
+	ID         string            `json:"id"`
+	Label      string            `json:"label"`
+	Flag       bool              `json:"flag"`
+	Rank       int               `json:"rank"`
+	Index      int               `json:"index"`
+	Properties map[string]string `json:"properties,omitempty"`
+	X          float64           `json:"x"`
+	Y          float64           `json:"y"`
+}
+
+type htmlEdge struct {
+	Parent string       `json:"parent"`
+	Child  string       `json:"child"`
+	Points [][2]float64 `json:"points"`
+}
+
+type htmlColors struct {
+	Flag      string `json:"flag"`
+	NoFlag    string `json:"noFlag"`
+	Highlight string `json:"highlight"`
+}
+
+// WriteHTML writes the graph, or the cone of a vertex, as a single HTML page
+// which embeds the data and the scripts, to explore the graph offline in a
+// browser: it pans and zooms, searches the vertices by ID, and highlights the
+// ancestors and descendants of the vertex clicked on. The vertices are
+// coloured by flag. The options may be nil.
+func (d *DAG) WriteHTML(w io.Writer, opts *HTMLOptions) error {
+	if opts == nil {
+		opts = &HTMLOptions{}
+	}
+
+	dg, err := d.diagram(&DiagramOptions{
+		Root:        opts.Root,
+		Descendants: opts.Descendants,
+		Depth:       opts.Depth,
+		MaxVertices: opts.MaxVertices,
+	})
+	if err != nil {
+		return err
+	}
+
+	l, err := dg.subgraph().Layout(&opts.Layout)
+	if err != nil {
+		return err
+	}
+
+	label := opts.Label
+	if label == nil {
+		label = func(v *Vertex) string {
+			return v.ID
+		}
+	}
+
+	data := &htmlGraph{
+		Title:      opts.Title,
+		Root:       opts.Root,
+		Omitted:    dg.omitted,
+		Width:      l.Width,
+		Height:     l.Height,
+		NodeWidth:  l.NodeWidth,
+		NodeHeight: l.NodeHeight,
+		Nodes:      make([]htmlNode, 0, len(l.Nodes)),
+		Edges:      make([]htmlEdge, 0, len(l.Edges)),
+		Colors: &htmlColors{
+			Flag:      dotFlagColor,
+			NoFlag:    dotNoFlagColor,
+			Highlight: dotHighlightColor,
+		},
+	}
+	if data.Title == "" {
+		data.Title = "Graph"
+		if opts.Root != "" {
+			data.Title = opts.Root
+		}
+	}
+
+	for _, n := range l.Nodes {
+		data.Nodes = append(data.Nodes, htmlNode{
+			ID:         n.Vertex.ID,
+			Label:      label(n.Vertex),
+			Flag:       n.Vertex.Flag,
+			Rank:       n.Vertex.Rank,
+			Index:      n.Vertex.Index,
+			Properties: n.Vertex.Properties,
+			X:          n.X,
+			Y:          n.Y,
+		})
+	}
+	sort.Slice(data.Nodes, func(i, j int) bool {
+		return data.Nodes[i].ID < data.Nodes[j].ID
+	})
+
+	for _, e := range l.Edges {
+		he := htmlEdge{Parent: e.Parent, Child: e.Child}
+		for _, p := range e.Points {
+			he.Points = append(he.Points, [2]float64{p.X, p.Y})
+		}
+		data.Edges = append(data.Edges, he)
+	}
+
+	return htmlTemplate.Execute(w, data)
+}
+
+// subgraph returns a graph of copies of the selected vertices, with the edges
+// between them.
+func (dg *diagram) subgraph() *DAG {
+	sub := NewDAG()
+
+	for _, v := range dg.vertices {
+		c := NewVertex(v.ID, v.Flag, v.Rank)
+		c.Index = v.Index
+		c.Properties = v.Properties
+		sub.vertices[c.ID] = c
+	}
+
+	for _, v := range dg.vertices {
+		for c := range v.Children {
+			if child, ok := sub.vertices[c]; ok {
+				sub.vertices[v.ID].Children[c] = struct{}{}
+				child.Parents[v.ID] = struct{}{}
+			}
+		}
+	}
+
+	return sub
+}
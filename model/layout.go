@@ -0,0 +1,466 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Layering selects how the vertices are assigned to the layers of a layout.
+type Layering int
+
+const (
+	// LayerByLongestPath puts every vertex one layer below its lowest parent.
+	LayerByLongestPath Layering = iota
+
+	// LayerByRank puts the vertices of the same rank on the same layer.
+	// Every parent must have a lower rank than its children.
+	LayerByRank
+)
+
+// LayoutOptions configures Layout. The zero value uses the defaults.
+type LayoutOptions struct {
+	Layering Layering
+
+	// The size of the vertices, 80x30 by default.
+	NodeWidth, NodeHeight float64
+
+	// The space between two vertices of a layer, 20 by default, and between
+	// two layers, 50 by default.
+	HorizontalGap, VerticalGap float64
+
+	// The space around the layout, 20 by default.
+	Margin float64
+
+	// The number of sweeps of the crossing minimization, 12 by default.
+	Iterations int
+}
+
+func (o *LayoutOptions) withDefaults() LayoutOptions {
+	opts := LayoutOptions{}
+	if o != nil {
+		opts = *o
+	}
+
+	if opts.NodeWidth <= 0 {
+		opts.NodeWidth = 80
+	}
+	if opts.NodeHeight <= 0 {
+		opts.NodeHeight = 30
+	}
+	if opts.HorizontalGap <= 0 {
+		opts.HorizontalGap = 20
+	}
+	if opts.VerticalGap <= 0 {
+		opts.VerticalGap = 50
+	}
+	if opts.Margin <= 0 {
+		opts.Margin = 20
+	}
+	if opts.Iterations <= 0 {
+		opts.Iterations = 12
+	}
+
+	return opts
+}
+
+// Point is a position in a layout. Y grows downwards.
+type Point struct {
+	X, Y float64
+}
+
+// LayoutNode is the position of a vertex in a layout.
+type LayoutNode struct {
+	Vertex *Vertex
+
+	// The layer, from the top, and the position in the layer, from the left.
+	Layer, Position int
+
+	// The centre of the vertex.
+	X, Y float64
+}
+
+// LayoutEdge is the route of an edge, from the bottom of the parent to the
+// top of the child, through a point in every layer in between.
+type LayoutEdge struct {
+	Parent, Child string
+	Points        []Point
+}
+
+// Layout is the position of the vertices and the route of the edges of a
+// graph, laid out in layers from the top.
+type Layout struct {
+	Width, Height         float64
+	NodeWidth, NodeHeight float64
+
+	// The vertices, in order of layer and position.
+	Nodes []LayoutNode
+	Edges []LayoutEdge
+}
+
+// layoutNode is a vertex, or a dummy node where an edge crosses a layer.
+type layoutNode struct {
+	vertex *Vertex
+
+	// The ID of the vertex, or of the edge of a dummy, to break the ties.
+	key string
+
+	layer    int
+	position int
+	up, down []int
+
+	x, width float64
+}
+
+// Layout lays the graph out in layers, in the manner of Sugiyama: the
+// vertices are assigned to layers, the edges which cross layers are split by
+// dummy nodes, the vertices of every layer are ordered to reduce the
+// crossings with the barycenter heuristic, and they are placed near the
+// average of their neighbours. The options may be nil.
+//
+// It returns an error if the graph has a cycle, or if a parent does not have
+// a lower rank than its child with LayerByRank.
+func (d *DAG) Layout(opts *LayoutOptions) (*Layout, error) {
+	o := opts.withDefaults()
+
+	order, err := d.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
+	// Assign the layers.
+	index := make(map[string]int, len(order))
+	nodes := make([]*layoutNode, 0, len(order))
+
+	minRank := 0
+	for i, v := range order {
+		if i == 0 || v.Rank < minRank {
+			minRank = v.Rank
+		}
+	}
+
+	for i, v := range order {
+		index[v.ID] = i
+		n := &layoutNode{vertex: v, key: v.ID, width: o.NodeWidth}
+
+		for _, p := range sortedSet(v.Parents) {
+			pi, ok := index[p]
+			if !ok {
+				continue
+			}
+
+			if o.Layering == LayerByLongestPath && nodes[pi].layer+1 > n.layer {
+				n.layer = nodes[pi].layer + 1
+			}
+			if o.Layering == LayerByRank && nodes[pi].vertex.Rank >= v.Rank {
+				return nil, fmt.Errorf("rank of parent %s is not lower than rank of child %s", p, v.ID)
+			}
+		}
+
+		if o.Layering == LayerByRank {
+			n.layer = v.Rank - minRank
+		}
+
+		nodes = append(nodes, n)
+	}
+
+	// Split the edges which cross layers with dummy nodes.
+	type edge struct {
+		parent, child string
+		path          []int
+	}
+	var edges []edge
+
+	for ci := range order {
+		child := nodes[ci]
+
+		for _, p := range sortedSet(child.vertex.Parents) {
+			pi, ok := index[p]
+			if !ok {
+				continue
+			}
+
+			path := []int{pi}
+			for layer := nodes[pi].layer + 1; layer < child.layer; layer++ {
+				nodes = append(nodes, &layoutNode{
+					key:   fmt.Sprintf("%s->%s#%d", p, child.vertex.ID, layer),
+					layer: layer,
+				})
+				path = append(path, len(nodes)-1)
+			}
+			path = append(path, ci)
+
+			for i := 0; i+1 < len(path); i++ {
+				nodes[path[i]].down = append(nodes[path[i]].down, path[i+1])
+				nodes[path[i+1]].up = append(nodes[path[i+1]].up, path[i])
+			}
+
+			edges = append(edges, edge{parent: p, child: child.vertex.ID, path: path})
+		}
+	}
+
+	layers := orderLayers(nodes, o.Iterations)
+	placeLayers(nodes, layers, o)
+
+	// Build the layout.
+	l := &Layout{NodeWidth: o.NodeWidth, NodeHeight: o.NodeHeight}
+	y := func(layer int) float64 {
+		return o.Margin + o.NodeHeight/2 + float64(layer)*(o.NodeHeight+o.VerticalGap)
+	}
+
+	for layer, ids := range layers {
+		for _, id := range ids {
+			n := nodes[id]
+			if n.x+n.width/2+o.Margin > l.Width {
+				l.Width = n.x + n.width/2 + o.Margin
+			}
+			if n.vertex == nil {
+				continue
+			}
+
+			l.Nodes = append(l.Nodes, LayoutNode{
+				Vertex:   n.vertex,
+				Layer:    layer,
+				Position: n.position,
+				X:        n.x,
+				Y:        y(layer),
+			})
+		}
+	}
+
+	if len(layers) != 0 {
+		l.Height = y(len(layers)-1) + o.NodeHeight/2 + o.Margin
+	}
+
+	for _, e := range edges {
+		le := LayoutEdge{Parent: e.parent, Child: e.child}
+
+		for i, id := range e.path {
+			p := Point{X: nodes[id].x, Y: y(nodes[id].layer)}
+			switch i {
+			case 0:
+				p.Y += o.NodeHeight / 2
+			case len(e.path) - 1:
+				p.Y -= o.NodeHeight / 2
+			}
+			le.Points = append(le.Points, p)
+		}
+
+		l.Edges = append(l.Edges, le)
+	}
+
+	return l, nil
+}
+
+// orderLayers orders the nodes of every layer to reduce the crossings of the
+// edges, and returns the layers. The order with the fewest crossings of all
+// the sweeps is kept.
+func orderLayers(nodes []*layoutNode, iterations int) [][]int {
+	var layers [][]int
+	for id, n := range nodes {
+		for n.layer >= len(layers) {
+			layers = append(layers, nil)
+		}
+		layers[n.layer] = append(layers[n.layer], id)
+	}
+
+	// The initial order is by key, then by barycenter from the top.
+	for _, layer := range layers {
+		sort.Slice(layer, func(i, j int) bool {
+			return nodes[layer[i]].key < nodes[layer[j]].key
+		})
+	}
+	setPositions(nodes, layers)
+	for i := 1; i < len(layers); i++ {
+		sortByBarycenter(nodes, layers[i], true)
+	}
+
+	best := copyLayers(layers)
+	bestCrossings := countCrossings(nodes, layers)
+
+	for i := 0; i < iterations && bestCrossings > 0; i++ {
+		if i%2 == 0 {
+			for l := 1; l < len(layers); l++ {
+				sortByBarycenter(nodes, layers[l], true)
+			}
+		} else {
+			for l := len(layers) - 2; l >= 0; l-- {
+				sortByBarycenter(nodes, layers[l], false)
+			}
+		}
+
+		if c := countCrossings(nodes, layers); c < bestCrossings {
+			best, bestCrossings = copyLayers(layers), c
+		}
+	}
+
+	setPositions(nodes, best)
+
+	return best
+}
+
+func setPositions(nodes []*layoutNode, layers [][]int) {
+	for _, layer := range layers {
+		for pos, id := range layer {
+			nodes[id].position = pos
+		}
+	}
+}
+
+func copyLayers(layers [][]int) [][]int {
+	c := make([][]int, len(layers))
+	for i, layer := range layers {
+		c[i] = append([]int(nil), layer...)
+	}
+
+	return c
+}
+
+// sortByBarycenter orders the layer by the average position of the
+// neighbours of the nodes in the layer above, or below. A node without
+// neighbours keeps its position.
+func sortByBarycenter(nodes []*layoutNode, layer []int, fromAbove bool) {
+	barycenter := make(map[int]float64, len(layer))
+
+	for _, id := range layer {
+		neighbours := nodes[id].down
+		if fromAbove {
+			neighbours = nodes[id].up
+		}
+
+		if len(neighbours) == 0 {
+			barycenter[id] = float64(nodes[id].position)
+			continue
+		}
+
+		sum := 0
+		for _, n := range neighbours {
+			sum += nodes[n].position
+		}
+		barycenter[id] = float64(sum) / float64(len(neighbours))
+	}
+
+	sort.SliceStable(layer, func(i, j int) bool {
+		return barycenter[layer[i]] < barycenter[layer[j]]
+	})
+
+	for pos, id := range layer {
+		nodes[id].position = pos
+	}
+}
+
+// countCrossings counts the crossings of the edges between all the adjacent
+// layers, as the inversions of the positions of the lower ends of the edges
+// sorted by their upper ends.
+func countCrossings(nodes []*layoutNode, layers [][]int) int {
+	crossings := 0
+
+	for l := 0; l+1 < len(layers); l++ {
+		var ends [][2]int
+		for _, id := range layers[l] {
+			for _, c := range nodes[id].down {
+				ends = append(ends, [2]int{nodes[id].position, nodes[c].position})
+			}
+		}
+
+		sort.Slice(ends, func(i, j int) bool {
+			if ends[i][0] != ends[j][0] {
+				return ends[i][0] < ends[j][0]
+			}
+			return ends[i][1] < ends[j][1]
+		})
+
+		// Count with a Fenwick tree the lower ends already seen which are
+		// to the right of the current one.
+		tree := make([]int, len(layers[l+1])+1)
+		for seen, e := range ends {
+			notGreater := 0
+			for i := e[1] + 1; i > 0; i -= i & -i {
+				notGreater += tree[i]
+			}
+			crossings += seen - notGreater
+
+			for i := e[1] + 1; i < len(tree); i += i & -i {
+				tree[i]++
+			}
+		}
+	}
+
+	return crossings
+}
+
+// placeLayers sets the horizontal position of the nodes, keeping the order of
+// the layers. The nodes are moved towards the average of their neighbours,
+// alternately from above and from below, then the layout is shifted to the
+// margin.
+func placeLayers(nodes []*layoutNode, layers [][]int, o LayoutOptions) {
+	separation := func(a, b int) float64 {
+		return (nodes[a].width+nodes[b].width)/2 + o.HorizontalGap
+	}
+
+	for _, layer := range layers {
+		for i, id := range layer {
+			if i == 0 {
+				nodes[id].x = nodes[id].width / 2
+				continue
+			}
+			nodes[id].x = nodes[layer[i-1]].x + separation(layer[i-1], id)
+		}
+	}
+
+	for i := 0; i < 8; i++ {
+		fromAbove := i%2 == 0
+
+		for k := range layers {
+			layer := layers[k]
+			if !fromAbove {
+				layer = layers[len(layers)-1-k]
+			}
+
+			desired := make([]float64, len(layer))
+			for j, id := range layer {
+				neighbours := nodes[id].down
+				if fromAbove {
+					neighbours = nodes[id].up
+				}
+
+				desired[j] = nodes[id].x
+				if len(neighbours) != 0 {
+					sum := 0.0
+					for _, n := range neighbours {
+						sum += nodes[n].x
+					}
+					desired[j] = sum / float64(len(neighbours))
+				}
+			}
+
+			// Pack from the left, then shift the layer so that it is on
+			// average where its nodes want to be.
+			shift := 0.0
+			for j, id := range layer {
+				x := desired[j]
+				if j > 0 && x < nodes[layer[j-1]].x+separation(layer[j-1], id) {
+					x = nodes[layer[j-1]].x + separation(layer[j-1], id)
+				}
+				nodes[id].x = x
+				shift += desired[j] - x
+			}
+
+			if len(layer) != 0 {
+				shift /= float64(len(layer))
+				for _, id := range layer {
+					nodes[id].x += shift
+				}
+			}
+		}
+	}
+
+	minX := 0.0
+	for i, n := range nodes {
+		if i == 0 || n.x-n.width/2 < minX {
+			minX = n.x - n.width/2
+		}
+	}
+	for _, n := range nodes {
+		n.x += o.Margin - minX
+	}
+}
@@ -0,0 +1,181 @@
+package model
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLayout(t *testing.T) {
+	graph := newTestGraph(t,
+		[2]string{"a", "b"},
+		[2]string{"a", "c"},
+		[2]string{"b", "d"},
+		[2]string{"c", "d"},
+		[2]string{"a", "d"},
+	)
+
+	l, err := graph.Layout(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layers := map[string]int{}
+	for _, n := range l.Nodes {
+		layers[n.Vertex.ID] = n.Layer
+	}
+	expected := map[string]int{"a": 0, "b": 1, "c": 1, "d": 2}
+	for id, layer := range expected {
+		if layers[id] != layer {
+			t.Fatalf("expected %s in layer %d, found %d", id, layer, layers[id])
+		}
+	}
+
+	if len(l.Edges) != 5 {
+		t.Fatalf("expected 5 edges, found %d", len(l.Edges))
+	}
+	for _, e := range l.Edges {
+		expected := 2
+		if e.Parent == "a" && e.Child == "d" {
+			expected = 3
+		}
+		if len(e.Points) != expected {
+			t.Fatalf("expected %d points for the edge (%s,%s), found %v", expected, e.Parent, e.Child, e.Points)
+		}
+	}
+
+	checkLayout(t, l, &LayoutOptions{})
+}
+
+func TestLayoutCrossings(t *testing.T) {
+	// In order of ID, the edges a->y and b->x cross.
+	graph := newTestGraph(t,
+		[2]string{"a", "y"},
+		[2]string{"b", "x"},
+	)
+
+	l, err := graph.Layout(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	positions := map[string]int{}
+	for _, n := range l.Nodes {
+		positions[n.Vertex.ID] = n.Position
+	}
+
+	if (positions["a"] < positions["b"]) != (positions["y"] < positions["x"]) {
+		t.Fatalf("expected no crossing, found the positions %v", positions)
+	}
+}
+
+func TestLayoutByRank(t *testing.T) {
+	graph := newTestGraph(t,
+		[2]string{"a", "b"},
+		[2]string{"b", "c"},
+	)
+	graph.vertices["a"].Rank = 1
+	graph.vertices["b"].Rank = 3
+	graph.vertices["c"].Rank = 4
+
+	l, err := graph.Layout(&LayoutOptions{Layering: LayerByRank})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range l.Nodes {
+		if n.Layer != n.Vertex.Rank-1 {
+			t.Fatalf("expected %s in layer %d, found %d", n.Vertex.ID, n.Vertex.Rank-1, n.Layer)
+		}
+	}
+
+	graph.vertices["c"].Rank = 3
+	if _, err := graph.Layout(&LayoutOptions{Layering: LayerByRank}); err == nil {
+		t.Fatal("expected an error for a child with the rank of its parent")
+	}
+}
+
+func TestLayoutGenerated(t *testing.T) {
+	graph := GenerateGraph(300)
+
+	opts := &LayoutOptions{NodeWidth: 40, HorizontalGap: 5}
+	l, err := graph.Layout(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(l.Nodes) != graph.CountVertex() || len(l.Edges) != graph.CountEdge() {
+		t.Fatalf("expected %d nodes and %d edges, found %d and %d", graph.CountVertex(), graph.CountEdge(), len(l.Nodes), len(l.Edges))
+	}
+
+	checkLayout(t, l, opts)
+}
+
+// checkLayout checks that the vertices of a layer do not overlap and that
+// the layout is in its bounds.
+func checkLayout(t *testing.T, l *Layout, opts *LayoutOptions) {
+	o := opts.withDefaults()
+
+	for i, n := range l.Nodes {
+		if n.X-o.NodeWidth/2 < o.Margin-1e-6 || n.X+o.NodeWidth/2 > l.Width-o.Margin+1e-6 {
+			t.Fatalf("vertex %s at %v is out of the width %v", n.Vertex.ID, n.X, l.Width)
+		}
+		if n.Y+o.NodeHeight/2 > l.Height-o.Margin+1e-6 {
+			t.Fatalf("vertex %s at %v is out of the height %v", n.Vertex.ID, n.Y, l.Height)
+		}
+
+		if i > 0 && l.Nodes[i-1].Layer == n.Layer {
+			prev := l.Nodes[i-1]
+			if n.X-prev.X < o.NodeWidth+o.HorizontalGap-1e-6 {
+				t.Fatalf("vertices %s and %s overlap", prev.Vertex.ID, n.Vertex.ID)
+			}
+		}
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	graph := newTestGraph(t,
+		[2]string{"a", "b"},
+		[2]string{"a", "c"},
+	)
+	graph.vertices["b"].Flag = true
+	graph.vertices["c"].Properties = map[string]string{"name": "<c> & a very long label"}
+
+	var buf bytes.Buffer
+	err := graph.WriteSVG(&buf, &SVGOptions{
+		Label:       PropertyLabel("name"),
+		ColorByFlag: true,
+		Highlight:   map[string]struct{}{"a": {}, "b": {}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+
+	counts := map[string]int{
+		"<rect ":                        3,
+		"<polyline ":                    2,
+		`fill="lightblue"`:              1,
+		`stroke="red" stroke-width="2"`: 3,
+		"&lt;c&gt; &amp; a v…":          1,
+	}
+	for s, count := range counts {
+		if strings.Count(svg, s) != count {
+			t.Fatalf("expected %d times %q, found:\n%s", count, s, svg)
+		}
+	}
+
+	// The output is well-formed XML.
+	dec := xml.NewDecoder(&buf)
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// The node-link JSON format stores the vertices and the edges in two arrays:
+//
+//	{"vertices":[
+//	{"id":"a","flag":true,"rank":0,"index":1,"properties":{"name":"A"}},
+//	{"id":"b","flag":false,"rank":1,"index":2}
+//	],"edges":[
+//	{"parent":"a","child":"b"}
+//	]}
+//
+// The vertices come before the edges, so both can be read and written one
+// element at a time with NodeLinkEncoder and DecodeNodeLink.
+
+// NodeLinkVertex is a vertex of the node-link format.
+type NodeLinkVertex struct {
+	ID         string            `json:"id"`
+	Flag       bool              `json:"flag"`
+	Rank       int               `json:"rank"`
+	Index      int               `json:"index"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// NodeLinkEdge is an edge of the node-link format.
+type NodeLinkEdge struct {
+	Parent string `json:"parent"`
+	Child  string `json:"child"`
+}
+
+// NodeLinkEncoder writes a graph in the node-link format, one element at a
+// time. All the vertices must be written before the edges, and Close must be
+// called at the end.
+type NodeLinkEncoder struct {
+	ew    errWriter
+	state int
+	first bool
+}
+
+const (
+	nodeLinkStart = iota
+	nodeLinkVertices
+	nodeLinkEdges
+	nodeLinkClosed
+)
+
+func NewNodeLinkEncoder(w io.Writer) *NodeLinkEncoder {
+	return &NodeLinkEncoder{ew: errWriter{w: w}}
+}
+
+// WriteVertex writes the vertex, without its edges.
+func (e *NodeLinkEncoder) WriteVertex(v *Vertex) error {
+	if e.state > nodeLinkVertices {
+		return fmt.Errorf("vertex %s written after the edges", v.ID)
+	}
+
+	if e.state == nodeLinkStart {
+		e.ew.printf("{\"vertices\":[")
+		e.state = nodeLinkVertices
+		e.first = true
+	}
+
+	return e.write(NodeLinkVertex{
+		ID:         v.ID,
+		Flag:       v.Flag,
+		Rank:       v.Rank,
+		Index:      v.Index,
+		Properties: v.Properties,
+	})
+}
+
+func (e *NodeLinkEncoder) WriteEdge(parent, child string) error {
+	if e.state == nodeLinkClosed {
+		return fmt.Errorf("edge (%s,%s) written after close", parent, child)
+	}
+
+	e.startEdges()
+
+	return e.write(NodeLinkEdge{Parent: parent, Child: child})
+}
+
+// Close ends the document. It does not close the underlying writer.
+func (e *NodeLinkEncoder) Close() error {
+	if e.state == nodeLinkClosed {
+		return e.ew.err
+	}
+
+	e.startEdges()
+	e.ew.printf("\n]}\n")
+	e.state = nodeLinkClosed
+
+	return e.ew.err
+}
+
+func (e *NodeLinkEncoder) startEdges() {
+	if e.state == nodeLinkEdges {
+		return
+	}
+
+	if e.state == nodeLinkStart {
+		e.ew.printf("{\"vertices\":[")
+	}
+
+	e.ew.printf("\n],\"edges\":[")
+	e.state = nodeLinkEdges
+	e.first = true
+}
+
+func (e *NodeLinkEncoder) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if e.first {
+		e.ew.printf("\n%s", data)
+		e.first = false
+	} else {
+		e.ew.printf(",\n%s", data)
+	}
+
+	return e.ew.err
+}
+
+// WriteNodeLink writes the graph in the node-link format, in order of ID.
+func (d *DAG) WriteNodeLink(w io.Writer) error {
+	e := NewNodeLinkEncoder(w)
+	ids := sortedIDs(d.vertices)
+
+	for _, id := range ids {
+		if err := e.WriteVertex(d.vertices[id]); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range ids {
+		for _, c := range sortedSet(d.vertices[id].Children) {
+			if _, ok := d.vertices[c]; !ok {
+				continue
+			}
+
+			if err := e.WriteEdge(id, c); err != nil {
+				return err
+			}
+		}
+	}
+
+	return e.Close()
+}
+
+// NodeLinkHandler receives the elements decoded by DecodeNodeLink, in order.
+type NodeLinkHandler interface {
+	// Vertex receives a vertex, without its edges.
+	Vertex(v *Vertex) error
+
+	Edge(parent, child string) error
+}
+
+// DecodeNodeLink reads a graph in the node-link format and passes the
+// elements to the handler as they are decoded, so the document is never held
+// in memory. The unknown fields are ignored.
+func DecodeNodeLink(r io.Reader, h NodeLinkHandler) error {
+	dec := json.NewDecoder(r)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t {
+		case "vertices":
+			err = decodeArray(dec, "vertices", func() error {
+				var v NodeLinkVertex
+				if err := dec.Decode(&v); err != nil {
+					return err
+				}
+				if v.ID == "" {
+					return fmt.Errorf("missing id")
+				}
+
+				vertex := NewVertex(v.ID, v.Flag, v.Rank)
+				vertex.Index = v.Index
+				if len(v.Properties) != 0 {
+					vertex.Properties = v.Properties
+				}
+
+				return h.Vertex(vertex)
+			})
+		case "edges":
+			err = decodeArray(dec, "edges", func() error {
+				var e NodeLinkEdge
+				if err := dec.Decode(&e); err != nil {
+					return err
+				}
+				if e.Parent == "" || e.Child == "" {
+					return fmt.Errorf("missing parent or child")
+				}
+
+				return h.Edge(e.Parent, e.Child)
+			})
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(dec, '}')
+}
+
+func decodeArray(dec *json.Decoder, name string, decode func() error) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	for i := 0; dec.More(); i++ {
+		if err := decode(); err != nil {
+			return fmt.Errorf("%s[%d]: %w", name, i, err)
+		}
+	}
+
+	if err := expectDelim(dec, ']'); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if t != delim {
+		return fmt.Errorf("expected %v, found %v", delim, t)
+	}
+
+	return nil
+}
+
+// ReadNodeLink reads a graph in the node-link format.
+// It returns an error if the graph has a cycle.
+func ReadNodeLink(r io.Reader) (*DAG, error) {
+	d := NewDAG()
+
+	if err := DecodeNodeLink(r, dagNodeLinkHandler{d}); err != nil {
+		return nil, err
+	}
+
+	if _, err := d.TopologicalSort(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+type dagNodeLinkHandler struct {
+	d *DAG
+}
+
+func (h dagNodeLinkHandler) Vertex(v *Vertex) error {
+	if _, ok := h.d.vertices[v.ID]; ok {
+		return fmt.Errorf("vertex %s already exists", v.ID)
+	}
+
+	h.d.AddVertex(v)
+
+	return nil
+}
+
+func (h dagNodeLinkHandler) Edge(parent, child string) error {
+	p, err := h.d.GetVertex(parent)
+	if err != nil {
+		return err
+	}
+
+	c, err := h.d.GetVertex(child)
+	if err != nil {
+		return err
+	}
+
+	return h.d.AddEdge(p, c)
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNodeLink(t *testing.T) {
+	graph := newTestGraph(t,
+		[2]string{"a", "b"},
+		[2]string{"a", "c"},
+	)
+	graph.vertices["a"].Flag = true
+	graph.vertices["a"].Index = 1
+	graph.vertices["b"].Rank = 1
+	graph.vertices["b"].Properties = map[string]string{"name": "B"}
+
+	var buf bytes.Buffer
+	if err := graph.WriteNodeLink(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"vertices":[
+{"id":"a","flag":true,"rank":0,"index":1},
+{"id":"b","flag":false,"rank":1,"index":0,"properties":{"name":"B"}},
+{"id":"c","flag":false,"rank":0,"index":0}
+],"edges":[
+{"parent":"a","child":"b"},
+{"parent":"a","child":"c"}
+]}
+`
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nfound:\n%s", expected, buf.String())
+	}
+
+	read, err := ReadNodeLink(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(read.Vertices(), graph.Vertices()) {
+		t.Fatalf("expected %v, found %v", graph.Vertices(), read.Vertices())
+	}
+}
+
+func TestNodeLinkEncoderOrder(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewNodeLinkEncoder(&buf)
+
+	if err := e.WriteEdge("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WriteVertex(NewVertex("a", false, 0)); err == nil {
+		t.Fatal("expected an error for a vertex after the edges")
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "{\"vertices\":[\n],\"edges\":[\n{\"parent\":\"a\",\"child\":\"b\"}\n]}\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+func TestReadNodeLinkErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"not an object", `[]`, "expected {, found ["},
+		{"missing id", `{"vertices":[{"flag":true}]}`, "vertices[0]: missing id"},
+		{"duplicate", `{"vertices":[{"id":"a"},{"id":"a"}]}`, "vertices[1]: vertex a already exists"},
+		{"missing vertex", `{"vertices":[{"id":"a"}],"edges":[{"parent":"a","child":"b"}]}`, "edges[0]: vertex does not exist: b"},
+		{"cycle", `{"vertices":[{"id":"a"},{"id":"b"}],"edges":[{"parent":"a","child":"b"},{"parent":"b","child":"a"}]}`, "cycle"},
+		{"truncated", `{"vertices":[{"id":"a"}`, "unexpected end of JSON input"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadNodeLink(strings.NewReader(test.input))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected an error with %q, found %v", test.err, err)
+			}
+		})
+	}
+
+	// The unknown fields are ignored.
+	graph, err := ReadNodeLink(strings.NewReader(`{"directed":true,"vertices":[{"id":"a","extra":1}],"edges":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.vertices) != 1 {
+		t.Fatalf("expected 1 vertex, found %d", len(graph.vertices))
+	}
+}
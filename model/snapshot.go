@@ -0,0 +1,208 @@
+package model
+
+import "sort"
+
+// Snapshot returns a point-in-time copy of the graph.
+//
+// Taking a snapshot is cheap, the snapshot shares the vertices with the graph.
+// They are copied on write: the first mutation after a snapshot copies the
+// vertices map, and every mutated vertex is copied the first time it is
+// modified. The snapshot and the graph can then be modified independently,
+// and the snapshot can be read by another goroutine while the graph is
+// being modified. Observers are not copied to the snapshot.
+//
+// Because vertices are copied on write, a *Vertex obtained before the
+// snapshot is not updated by later mutations of the graph. Look it up again
+// with GetVertex.
+func (d *DAG) Snapshot() *DAG {
+	d.shared = true
+	d.owned = make(map[string]struct{})
+
+	return &DAG{
+		vertices: d.vertices,
+		version:  d.version,
+		shared:   true,
+		owned:    make(map[string]struct{}),
+	}
+}
+
+// Version returns the number of mutations applied to the graph.
+// A snapshot has the version of the graph at the time it was taken.
+func (d *DAG) Version() uint64 {
+	return d.version
+}
+
+// writable prepares the graph for a mutation.
+func (d *DAG) writable() {
+	d.version++
+
+	if d.shared {
+		vertices := make(map[string]*Vertex, len(d.vertices))
+		for id, v := range d.vertices {
+			vertices[id] = v
+		}
+
+		d.vertices = vertices
+		d.shared = false
+	}
+}
+
+// mutableVertex returns the vertex with the ID, copying it first if it may be
+// shared with a snapshot. The vertex must exist.
+func (d *DAG) mutableVertex(id string) *Vertex {
+	v := d.vertices[id]
+	if d.owned == nil {
+		return v
+	}
+
+	if _, ok := d.owned[id]; !ok {
+		v = v.Clone()
+		d.vertices[id] = v
+		d.owned[id] = struct{}{}
+	}
+
+	return v
+}
+
+// History keeps snapshots of a graph, so different versions can be compared.
+type History struct {
+	max       int
+	snapshots []*DAG
+}
+
+// NewHistory creates a history which keeps at most max snapshots.
+// The oldest snapshot is evicted first.
+func NewHistory(max int) *History {
+	return &History{
+		max: max,
+	}
+}
+
+// Record takes a snapshot of the graph and keeps it.
+func (h *History) Record(d *DAG) *DAG {
+	s := d.Snapshot()
+
+	h.snapshots = append(h.snapshots, s)
+	if len(h.snapshots) > h.max {
+		h.snapshots = h.snapshots[len(h.snapshots)-h.max:]
+	}
+
+	return s
+}
+
+// Get returns the snapshot with the version.
+func (h *History) Get(version uint64) (*DAG, bool) {
+	for _, s := range h.snapshots {
+		if s.version == version {
+			return s, true
+		}
+	}
+
+	return nil, false
+}
+
+// Versions returns the versions of the kept snapshots, from the oldest.
+func (h *History) Versions() []uint64 {
+	var versions []uint64
+	for _, s := range h.snapshots {
+		versions = append(versions, s.version)
+	}
+
+	return versions
+}
+
+// Diff returns the events which turn the graph from into the graph to:
+// the deleted edges, deleted vertices, added vertices, updated vertices and
+// added edges, in that order. A vertex is updated if its flag, rank, index or
+// properties differ. Vertices shared between two snapshots are skipped
+// without comparing them.
+func Diff(from, to *DAG) []Event {
+	var deletedVertices, addedVertices, updatedVertices, deletedEdges, addedEdges []Event
+
+	for _, id := range sortedIDs(from.vertices) {
+		v := from.vertices[id]
+		u, ok := to.vertices[id]
+		if !ok {
+			deletedVertices = append(deletedVertices, VertexDeleted{Vertex: v})
+		}
+		if u == v {
+			continue
+		}
+
+		for _, p := range sortedSet(v.Parents) {
+			if u != nil {
+				if _, ok := u.Parents[p]; ok {
+					continue
+				}
+			}
+			deletedEdges = append(deletedEdges, EdgeDeleted{Parent: from.vertices[p], Child: v})
+		}
+	}
+
+	for _, id := range sortedIDs(to.vertices) {
+		u := to.vertices[id]
+		v, ok := from.vertices[id]
+		if !ok {
+			addedVertices = append(addedVertices, VertexAdded{Vertex: u})
+		}
+		if u == v {
+			continue
+		}
+		if v != nil && !sameFields(v, u) {
+			updatedVertices = append(updatedVertices, VertexUpdated{Old: v, Vertex: u})
+		}
+
+		for _, p := range sortedSet(u.Parents) {
+			if v != nil {
+				if _, ok := v.Parents[p]; ok {
+					continue
+				}
+			}
+			addedEdges = append(addedEdges, EdgeAdded{Parent: to.vertices[p], Child: u})
+		}
+	}
+
+	var events []Event
+	events = append(events, deletedEdges...)
+	events = append(events, deletedVertices...)
+	events = append(events, addedVertices...)
+	events = append(events, updatedVertices...)
+	events = append(events, addedEdges...)
+
+	return events
+}
+
+// sameFields reports whether the flag, rank, index and properties of the
+// vertices are equal.
+func sameFields(v, u *Vertex) bool {
+	if v.Flag != u.Flag || v.Rank != u.Rank || v.Index != u.Index || len(v.Properties) != len(u.Properties) {
+		return false
+	}
+	for k, p := range v.Properties {
+		if q, ok := u.Properties[k]; !ok || q != p {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sortedIDs(vertices map[string]*Vertex) []string {
+	ids := make([]string, 0, len(vertices))
+	for id := range vertices {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func sortedSet(set map[string]struct{}) []string {
+	ids := make([]string, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
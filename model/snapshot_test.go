@@ -0,0 +1,137 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	graph := NewDAG()
+	a := NewVertex("a", false, 0)
+	b := NewVertex("b", false, 1)
+	graph.AddVertex(a)
+	graph.AddVertex(b)
+	if err := graph.AddEdge(a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := graph.Snapshot()
+	if snapshot.Version() != graph.Version() {
+		t.Fatalf("expected snapshot version %d, found %d", graph.Version(), snapshot.Version())
+	}
+
+	c := NewVertex("c", true, 2)
+	graph.AddVertex(c)
+	if err := graph.AddEdge(b, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.DeleteVertex(a); err != nil {
+		t.Fatal(err)
+	}
+
+	if snapshot.CountVertex() != 2 || snapshot.CountEdge() != 1 {
+		t.Fatalf("snapshot changed: %d vertices, %d edges", snapshot.CountVertex(), snapshot.CountEdge())
+	}
+	if sb, _ := snapshot.GetVertex("b"); len(sb.Children) != 0 || len(sb.Parents) != 1 {
+		t.Fatal("vertex of the snapshot changed")
+	}
+
+	if graph.CountVertex() != 2 || graph.CountEdge() != 1 {
+		t.Fatalf("expected 2 vertices and 1 edge, found %d and %d", graph.CountVertex(), graph.CountEdge())
+	}
+	if graph.Version() <= snapshot.Version() {
+		t.Fatal("graph version must increase on mutation")
+	}
+
+	// The snapshot can be modified without affecting the graph.
+	snapshot.AddVertex(NewVertex("d", false, 0))
+	if _, err := graph.GetVertex("d"); err == nil {
+		t.Fatal("vertex added to the snapshot must not be in the graph")
+	}
+}
+
+func TestHistoryDiff(t *testing.T) {
+	graph := NewDAG()
+	history := NewHistory(2)
+
+	graph.AddVertex(NewVertex("a", false, 0))
+	graph.AddVertex(NewVertex("b", false, 1))
+	v1 := history.Record(graph)
+
+	a, _ := graph.GetVertex("a")
+	b, _ := graph.GetVertex("b")
+	if err := graph.AddEdge(a, b); err != nil {
+		t.Fatal(err)
+	}
+	graph.AddVertex(NewVertex("c", false, 0))
+	history.Record(graph)
+
+	if err := graph.DeleteVertex(b); err != nil {
+		t.Fatal(err)
+	}
+	v3 := history.Record(graph)
+
+	if _, ok := history.Get(v1.Version()); ok {
+		t.Fatal("oldest snapshot must be evicted")
+	}
+	if versions := history.Versions(); len(versions) != 2 || versions[1] != v3.Version() {
+		t.Fatalf("unexpected versions %v", versions)
+	}
+
+	events := Diff(v1, v3)
+	expected := []EventType{EventVertexDeleted, EventVertexAdded}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, found %d", len(expected), len(events))
+	}
+	for i := range expected {
+		if events[i].Type() != expected[i] {
+			t.Fatalf("expected event %d to be %v, found %v", i, expected[i], events[i].Type())
+		}
+	}
+}
+
+func TestDiffUpdated(t *testing.T) {
+	graph := NewDAG()
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		graph.AddVertex(NewVertex(id, false, 0))
+	}
+	from := graph.Snapshot()
+
+	updates := map[string]func(v *Vertex){
+		"a": func(v *Vertex) { v.Flag = true },
+		"b": func(v *Vertex) { v.Rank = 1 },
+		"c": func(v *Vertex) { v.Index = 1 },
+		"d": func(v *Vertex) { v.Properties = map[string]string{"k": "v"} },
+	}
+	for id, update := range updates {
+		v, _ := graph.GetVertex(id)
+		v = v.Clone()
+		update(v)
+		if err := graph.UpdateVertex(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The vertex e is copied on write by the edge, without being updated.
+	a, _ := graph.GetVertex("a")
+	e, _ := graph.GetVertex("e")
+	if err := graph.AddEdge(a, e); err != nil {
+		t.Fatal(err)
+	}
+
+	events := Diff(from, graph.Snapshot())
+	if len(events) != 5 {
+		t.Fatalf("expected 5 events, found %v", events)
+	}
+	for i, id := range []string{"a", "b", "c", "d"} {
+		updated, ok := events[i].(VertexUpdated)
+		if !ok || updated.Old.ID != id || updated.Vertex.ID != id {
+			t.Fatalf("expected event %d to update %s, found %v", i, id, events[i])
+		}
+		if old, _ := from.GetVertex(id); updated.Old != old {
+			t.Fatalf("expected the old vertex of %s to be the vertex of the snapshot", id)
+		}
+	}
+	if added, ok := events[4].(EdgeAdded); !ok || added.Parent.ID != "a" || added.Child.ID != "e" {
+		t.Fatalf("expected the edge (a,e) to be added, found %v", events[4])
+	}
+}
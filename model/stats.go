@@ -0,0 +1,156 @@
+package model
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Stats describes the shape of a graph.
+type Stats struct {
+	Vertices int `json:"vertices"`
+	Edges    int `json:"edges"`
+
+	// Vertices without parents and without children.
+	Roots  int `json:"roots"`
+	Leaves int `json:"leaves"`
+
+	// Number of edges of the longest path.
+	Depth int `json:"depth"`
+
+	// Number of vertices per rank.
+	RankWidths map[int]int `json:"rank_widths"`
+
+	// Number of vertices per in-degree (parents) and out-degree (children).
+	InDegrees  map[int]int `json:"in_degrees"`
+	OutDegrees map[int]int `json:"out_degrees"`
+
+	// Number of weakly connected components.
+	Components int `json:"components"`
+
+	// Number of vertices with the flag set, and their ratio to all the vertices.
+	Flagged   int     `json:"flagged"`
+	FlagRatio float64 `json:"flag_ratio"`
+}
+
+// Stats computes the statistics of the graph. The edges are taken from the
+// parents of the vertices. It returns an error if the graph has a cycle.
+func (d *DAG) Stats() (*Stats, error) {
+	order, err := d.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stats{
+		Vertices:   len(order),
+		RankWidths: make(map[int]int),
+		InDegrees:  make(map[int]int),
+		OutDegrees: make(map[int]int),
+	}
+
+	// Index the vertices, to count the children and find the components.
+	index := make(map[string]int, len(order))
+	for i, v := range order {
+		index[v.ID] = i
+	}
+
+	children := make([]int, len(order))
+	longest := make([]int, len(order))
+	components := newUnionFind(len(order))
+
+	for i, v := range order {
+		inDegree := 0
+		for p := range v.Parents {
+			j, ok := index[p]
+			if !ok {
+				continue
+			}
+
+			inDegree++
+			children[j]++
+			components.union(i, j)
+
+			if longest[j]+1 > longest[i] {
+				longest[i] = longest[j] + 1
+			}
+		}
+
+		s.Edges += inDegree
+		s.InDegrees[inDegree]++
+		s.RankWidths[v.Rank]++
+
+		if inDegree == 0 {
+			s.Roots++
+		}
+		if longest[i] > s.Depth {
+			s.Depth = longest[i]
+		}
+		if v.Flag {
+			s.Flagged++
+		}
+	}
+
+	for i := range order {
+		s.OutDegrees[children[i]]++
+		if children[i] == 0 {
+			s.Leaves++
+		}
+		if components.find(i) == i {
+			s.Components++
+		}
+	}
+
+	if s.Vertices != 0 {
+		s.FlagRatio = float64(s.Flagged) / float64(s.Vertices)
+	}
+
+	return s, nil
+}
+
+// WriteJSON writes the statistics as indented JSON.
+func (s *Stats) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(s)
+}
+
+// unionFind is a disjoint-set forest of the integers [0, n).
+type unionFind struct {
+	parent []int
+	size   []int
+}
+
+func newUnionFind(n int) *unionFind {
+	u := &unionFind{
+		parent: make([]int, n),
+		size:   make([]int, n),
+	}
+	for i := range u.parent {
+		u.parent[i] = i
+		u.size[i] = 1
+	}
+
+	return u
+}
+
+func (u *unionFind) find(i int) int {
+	for u.parent[i] != i {
+		u.parent[i] = u.parent[u.parent[i]]
+		i = u.parent[i]
+	}
+
+	return i
+}
+
+func (u *unionFind) union(i, j int) {
+	i, j = u.find(i), u.find(j)
+	if i == j {
+		return
+	}
+
+	if u.size[i] < u.size[j] {
+		i, j = j, i
+	}
+	u.parent[j] = i
+	u.size[i] += u.size[j]
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	graph := newTestGraph(t,
+		[2]string{"r1", "a"},
+		[2]string{"r2", "a"},
+		[2]string{"a", "b"},
+		[2]string{"a", "c"},
+		[2]string{"b", "d"},
+		[2]string{"c", "d"},
+		[2]string{"d", "e"},
+	)
+	x := NewVertex("x", true, 1)
+	graph.AddVertex(x)
+
+	stats, err := graph.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Stats{
+		Vertices:   8,
+		Edges:      7,
+		Roots:      3,
+		Leaves:     2,
+		Depth:      4,
+		RankWidths: map[int]int{0: 7, 1: 1},
+		InDegrees:  map[int]int{0: 3, 1: 3, 2: 2},
+		OutDegrees: map[int]int{0: 2, 1: 5, 2: 1},
+		Components: 2,
+		Flagged:    1,
+		FlagRatio:  0.125,
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Fatalf("expected %+v, found %+v", expected, stats)
+	}
+
+	var buf bytes.Buffer
+	if err := stats.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Stats
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, expected) {
+		t.Fatalf("expected %+v after JSON round trip, found %+v", expected, decoded)
+	}
+}
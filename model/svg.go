@@ -0,0 +1,126 @@
+package model
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// SVGOptions configures WriteSVG. The zero value lays the graph out with the
+// default LayoutOptions, and labels the vertices with their IDs.
+type SVGOptions struct {
+	Layout LayoutOptions
+
+	// Label returns the label of a vertex. Nil means the ID. See PropertyLabel.
+	Label func(v *Vertex) string
+
+	// ColorByFlag fills the vertices with a colour depending on their flag.
+	ColorByFlag bool
+
+	// Highlight is a set of vertex IDs to highlight, like DOTOptions.Highlight.
+	Highlight map[string]struct{}
+}
+
+// The approximate width of a character of the labels, to shorten the labels
+// which do not fit in their vertex.
+const svgCharWidth = 7
+
+// WriteSVG lays the graph out with Layout and renders it as SVG, without
+// Graphviz. The options may be nil.
+func (d *DAG) WriteSVG(w io.Writer, opts *SVGOptions) error {
+	if opts == nil {
+		opts = &SVGOptions{}
+	}
+
+	l, err := d.Layout(&opts.Layout)
+	if err != nil {
+		return err
+	}
+
+	return l.WriteSVG(w, opts)
+}
+
+// WriteSVG renders the layout as SVG. The options may be nil, their Layout is ignored.
+func (l *Layout) WriteSVG(w io.Writer, opts *SVGOptions) error {
+	if opts == nil {
+		opts = &SVGOptions{}
+	}
+
+	label := opts.Label
+	if label == nil {
+		label = func(v *Vertex) string {
+			return v.ID
+		}
+	}
+
+	ew := &errWriter{w: w}
+
+	ew.printf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%s\" height=\"%s\" viewBox=\"0 0 %s %s\">\n",
+		svgNumber(l.Width), svgNumber(l.Height), svgNumber(l.Width), svgNumber(l.Height))
+	ew.printf("<defs><marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"8\" markerHeight=\"8\" orient=\"auto\"><path d=\"M0,0 L10,5 L0,10 z\"/></marker></defs>\n")
+
+	ew.printf("<g class=\"edges\" fill=\"none\" stroke=\"black\">\n")
+	for _, e := range l.Edges {
+		points := make([]string, len(e.Points))
+		for i, p := range e.Points {
+			points[i] = svgNumber(p.X) + "," + svgNumber(p.Y)
+		}
+
+		_, highlightParent := opts.Highlight[e.Parent]
+		_, highlightChild := opts.Highlight[e.Child]
+		style := ""
+		if highlightParent && highlightChild {
+			style = fmt.Sprintf(" stroke=\"%s\" stroke-width=\"2\"", dotHighlightColor)
+		}
+
+		ew.printf("<polyline points=\"%s\" marker-end=\"url(#arrow)\"%s/>\n", strings.Join(points, " "), style)
+	}
+	ew.printf("</g>\n")
+
+	ew.printf("<g class=\"nodes\" font-family=\"sans-serif\" font-size=\"12\" text-anchor=\"middle\">\n")
+	for _, n := range l.Nodes {
+		fill := dotNoFlagColor
+		if opts.ColorByFlag && n.Vertex.Flag {
+			fill = dotFlagColor
+		}
+
+		stroke := "black"
+		width := 1
+		if _, ok := opts.Highlight[n.Vertex.ID]; ok {
+			stroke = dotHighlightColor
+			width = 2
+		}
+
+		text := label(n.Vertex)
+		maxChars := int(l.NodeWidth/svgCharWidth) - 1
+		if utf8.RuneCountInString(text) > maxChars && maxChars > 1 {
+			text = string([]rune(text)[:maxChars-1]) + "…"
+		}
+
+		ew.printf("<g><title>%s</title>", svgEscape(n.Vertex.ID))
+		ew.printf("<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" rx=\"4\" fill=\"%s\" stroke=\"%s\" stroke-width=\"%d\"/>",
+			svgNumber(n.X-l.NodeWidth/2), svgNumber(n.Y-l.NodeHeight/2), svgNumber(l.NodeWidth), svgNumber(l.NodeHeight), fill, stroke, width)
+		ew.printf("<text x=\"%s\" y=\"%s\" dominant-baseline=\"central\">%s</text></g>\n",
+			svgNumber(n.X), svgNumber(n.Y), svgEscape(text))
+	}
+	ew.printf("</g>\n")
+
+	ew.printf("</svg>\n")
+
+	return ew.err
+}
+
+// svgNumber formats a coordinate with at most 2 decimals.
+func svgNumber(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func svgEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
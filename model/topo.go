@@ -0,0 +1,60 @@
+package model
+
+import "fmt"
+
+// TopologicalSort returns the vertices ordered so that every vertex comes
+// after all of its parents. Only the parents are followed, parents missing
+// from the graph are ignored. The order is deterministic.
+// It returns an error if the graph has a cycle.
+func (d *DAG) TopologicalSort() ([]*Vertex, error) {
+	const (
+		visiting = 1
+		done     = 2
+	)
+
+	type frame struct {
+		id      string
+		parents []string
+	}
+
+	state := make(map[string]int, len(d.vertices))
+	order := make([]*Vertex, 0, len(d.vertices))
+
+	for _, id := range sortedIDs(d.vertices) {
+		if state[id] != 0 {
+			continue
+		}
+
+		state[id] = visiting
+		s := []frame{{id: id, parents: sortedSet(d.vertices[id].Parents)}}
+
+		for len(s) != 0 {
+			f := &s[len(s)-1]
+
+			if len(f.parents) == 0 {
+				state[f.id] = done
+				order = append(order, d.vertices[f.id])
+				s = s[:len(s)-1]
+				continue
+			}
+
+			p := f.parents[0]
+			f.parents = f.parents[1:]
+
+			pv, ok := d.vertices[p]
+			if !ok {
+				continue
+			}
+
+			switch state[p] {
+			case visiting:
+				return nil, fmt.Errorf("cycle detected at vertex %s", p)
+			case 0:
+				state[p] = visiting
+				s = append(s, frame{id: p, parents: sortedSet(pv.Parents)})
+			}
+		}
+	}
+
+	return order, nil
+}
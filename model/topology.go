@@ -0,0 +1,312 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// The generators below use the Seed, Rand, Size, FlagProbability and ID of
+// the options, the other options are ignored. The vertices are indexed from 1
+// in the order they are generated, the parents of a vertex always have a
+// lower index, and the rank of a vertex is the length of the longest path
+// from a vertex without parents.
+
+// Topology is a named graph generator, for benchmarks.
+type Topology struct {
+	Name     string
+	Generate func(opts GeneratorOptions) (*DAG, error)
+}
+
+// Topologies returns the available generators, with their parameters
+// chosen for benchmarks.
+func Topologies() []Topology {
+	return []Topology{
+		{Name: "Layered", Generate: Generate},
+		{Name: "ErdosRenyi", Generate: func(opts GeneratorOptions) (*DAG, error) {
+			// About four parents per vertex.
+			p := 8.0 / float64(opts.Size)
+			if p > 1 {
+				p = 1
+			}
+			return GenerateErdosRenyi(opts, p)
+		}},
+		{Name: "Chain", Generate: GenerateChain},
+		{Name: "FanIn", Generate: GenerateFanIn},
+		{Name: "FanOut", Generate: GenerateFanOut},
+		{Name: "DiamondLattice", Generate: func(opts GeneratorOptions) (*DAG, error) {
+			return GenerateDiamondLattice(opts, int(math.Sqrt(float64(opts.Size))))
+		}},
+		{Name: "BinaryTree", Generate: GenerateBinaryTree},
+		{Name: "ScaleFree", Generate: func(opts GeneratorOptions) (*DAG, error) {
+			return GenerateScaleFree(opts, 3)
+		}},
+	}
+}
+
+// GenerateErdosRenyi generates a random DAG where each pair of vertices is
+// connected with the probability p, from the lower index to the higher one.
+func GenerateErdosRenyi(opts GeneratorOptions, p float64) (*DAG, error) {
+	if p < 0 || p > 1 {
+		return nil, fmt.Errorf("probability %v is not between 0 and 1", p)
+	}
+
+	t, err := newTopology(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for j := 1; j <= opts.Size; j++ {
+		var parents []int
+
+		if p > 0 {
+			// Skip the pairs which are not connected, with a geometric
+			// distribution, instead of drawing every pair.
+			for i := 0; ; i++ {
+				if p < 1 {
+					i += int(math.Log(1-t.rand.Float64()) / math.Log(1-p))
+				}
+				if i+1 >= j {
+					break
+				}
+				parents = append(parents, i+1)
+			}
+		}
+
+		if err := t.add(j, parents...); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.graph, nil
+}
+
+// GenerateChain generates a single path through all the vertices.
+func GenerateChain(opts GeneratorOptions) (*DAG, error) {
+	t, err := newTopology(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= opts.Size; i++ {
+		var parents []int
+		if i > 1 {
+			parents = append(parents, i-1)
+		}
+
+		if err := t.add(i, parents...); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.graph, nil
+}
+
+// GenerateFanIn generates a star where every vertex is a parent of the last one.
+func GenerateFanIn(opts GeneratorOptions) (*DAG, error) {
+	t, err := newTopology(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var parents []int
+	for i := 1; i <= opts.Size; i++ {
+		if i < opts.Size {
+			if err := t.add(i); err != nil {
+				return nil, err
+			}
+			parents = append(parents, i)
+			continue
+		}
+
+		if err := t.add(i, parents...); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.graph, nil
+}
+
+// GenerateFanOut generates a star where every vertex is a child of the first one.
+func GenerateFanOut(opts GeneratorOptions) (*DAG, error) {
+	t, err := newTopology(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= opts.Size; i++ {
+		var parents []int
+		if i > 1 {
+			parents = append(parents, 1)
+		}
+
+		if err := t.add(i, parents...); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.graph, nil
+}
+
+// GenerateDiamondLattice generates a grid with width columns, where the
+// parents of a vertex are the vertices above and on its left. Every four
+// neighbour vertices form a diamond.
+func GenerateDiamondLattice(opts GeneratorOptions, width int) (*DAG, error) {
+	if width < 1 {
+		return nil, fmt.Errorf("width %d is lower than 1", width)
+	}
+
+	t, err := newTopology(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= opts.Size; i++ {
+		var parents []int
+
+		// Above.
+		if i > width {
+			parents = append(parents, i-width)
+		}
+		// On the left.
+		if (i-1)%width != 0 {
+			parents = append(parents, i-1)
+		}
+
+		if err := t.add(i, parents...); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.graph, nil
+}
+
+// GenerateBinaryTree generates a complete binary tree, where the parent of the
+// vertex i is the vertex i/2.
+func GenerateBinaryTree(opts GeneratorOptions) (*DAG, error) {
+	t, err := newTopology(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= opts.Size; i++ {
+		var parents []int
+		if i > 1 {
+			parents = append(parents, i/2)
+		}
+
+		if err := t.add(i, parents...); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.graph, nil
+}
+
+// GenerateScaleFree generates a DAG by preferential attachment: every vertex
+// gets m parents among the previous vertices, chosen with a probability
+// proportional to their number of children plus one. The number of children
+// follows a power law.
+func GenerateScaleFree(opts GeneratorOptions, m int) (*DAG, error) {
+	if m < 1 {
+		return nil, fmt.Errorf("number of parents %d is lower than 1", m)
+	}
+
+	t, err := newTopology(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every vertex appears once, plus once per child, so that a uniform
+	// choice is proportional to the number of children plus one.
+	var targets []int
+
+	for i := 1; i <= opts.Size; i++ {
+		count := m
+		if count > i-1 {
+			count = i - 1
+		}
+
+		chosen := make(map[int]struct{}, count)
+		var parents []int
+		for len(parents) < count {
+			p := targets[t.rand.Intn(len(targets))]
+			if _, ok := chosen[p]; ok {
+				continue
+			}
+			chosen[p] = struct{}{}
+			parents = append(parents, p)
+		}
+
+		if err := t.add(i, parents...); err != nil {
+			return nil, err
+		}
+
+		targets = append(targets, i)
+		targets = append(targets, parents...)
+	}
+
+	return t.graph, nil
+}
+
+// topology builds a graph from vertex indexes.
+type topology struct {
+	opts  GeneratorOptions
+	rand  *rand.Rand
+	id    func(seed int64, index int) string
+	graph *DAG
+	ids   map[int]*Vertex
+}
+
+func newTopology(opts GeneratorOptions) (*topology, error) {
+	if opts.Size < 0 {
+		return nil, fmt.Errorf("size %d is negative", opts.Size)
+	}
+
+	if opts.FlagProbability < 0 || opts.FlagProbability > 1 {
+		return nil, fmt.Errorf("flag probability %v is not between 0 and 1", opts.FlagProbability)
+	}
+
+	t := &topology{
+		opts:  opts,
+		rand:  opts.Rand,
+		id:    opts.ID,
+		graph: NewDAG(),
+		ids:   make(map[int]*Vertex, opts.Size),
+	}
+
+	if t.rand == nil {
+		t.rand = rand.New(rand.NewSource(opts.Seed))
+	}
+
+	if t.id == nil {
+		t.id = HexID
+	}
+
+	return t, nil
+}
+
+// add creates the vertex with the index, connected to the parents, which must have been added.
+func (t *topology) add(index int, parents ...int) error {
+	v := NewVertex(t.id(t.opts.Seed, index), t.rand.Float64() < t.opts.FlagProbability, 0)
+	v.Index = index
+
+	if _, ok := t.graph.vertices[v.ID]; ok {
+		return fmt.Errorf("vertex %s with index %d already exists", v.ID, index)
+	}
+
+	for _, p := range parents {
+		pv := t.ids[p]
+		pv.Children[v.ID] = struct{}{}
+		v.Parents[pv.ID] = struct{}{}
+
+		if pv.Rank+1 > v.Rank {
+			v.Rank = pv.Rank + 1
+		}
+	}
+
+	t.graph.vertices[v.ID] = v
+	t.ids[index] = v
+
+	return nil
+}
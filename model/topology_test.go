@@ -0,0 +1,96 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestTopologies(t *testing.T) {
+	size := 100
+
+	edges := map[string]int{
+		"Chain":          size - 1,
+		"FanIn":          size - 1,
+		"FanOut":         size - 1,
+		"BinaryTree":     size - 1,
+		"DiamondLattice": 2 * 10 * 9,
+		"ScaleFree":      1 + 2 + 3*(size-3),
+	}
+
+	for _, topology := range Topologies() {
+		t.Run(topology.Name, func(t *testing.T) {
+			opts := DefaultGeneratorOptions(size)
+			opts.Seed = 1
+
+			graph, err := topology.Generate(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if graph.CountVertex() != size {
+				t.Fatalf("expected vertices count %d, found %d", size, graph.CountVertex())
+			}
+
+			if expected, ok := edges[topology.Name]; ok && graph.CountEdge() != expected {
+				t.Fatalf("expected edges count %d, found %d", expected, graph.CountEdge())
+			}
+
+			if _, err := graph.TopologicalSort(); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, v := range graph.Vertices() {
+				rank := 0
+				for p := range v.Parents {
+					pv, err := graph.GetVertex(p)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if pv.Index >= v.Index || pv.Rank >= v.Rank {
+						t.Fatal("parent must have a lower index and rank")
+					}
+					if pv.Rank+1 > rank {
+						rank = pv.Rank + 1
+					}
+				}
+				// The ranks of the layered graph are its layers.
+				if topology.Name != "Layered" && v.Rank != rank {
+					t.Fatalf("expected rank %d, found %d", rank, v.Rank)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTopologies(b *testing.B) {
+	size := 10000
+
+	for _, topology := range Topologies() {
+		opts := DefaultGeneratorOptions(size)
+		opts.Seed = 1
+
+		graph, err := topology.Generate(opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		// The last vertex has the most ancestors in most topologies.
+		var v *Vertex
+		for _, u := range graph.Vertices() {
+			if u.Index == size {
+				v = u
+			}
+		}
+
+		b.Run(topology.Name+"/BFS", func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				graph.AncestorsBFS(v.ID, nil)
+			}
+		})
+
+		b.Run(topology.Name+"/DFS", func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				graph.AncestorsDFS(v.ID, nil)
+			}
+		})
+	}
+}
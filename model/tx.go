@@ -0,0 +1,90 @@
+package model
+
+import "fmt"
+
+// Tx stages mutations of a DAG. Nothing is modified until Commit, which
+// validates all the staged mutations together and applies all or none of them.
+type Tx struct {
+	dag   *DAG
+	batch Batch
+	done  bool
+}
+
+// Begin starts a transaction on the graph.
+func (d *DAG) Begin() *Tx {
+	return &Tx{dag: d}
+}
+
+func (tx *Tx) AddVertex(v *Vertex) {
+	tx.batch.AddVertex(v)
+}
+
+func (tx *Tx) UpdateVertex(v *Vertex) {
+	tx.batch.UpdateVertex(v)
+}
+
+func (tx *Tx) DeleteVertex(id string) {
+	tx.batch.DeleteVertex(id)
+}
+
+func (tx *Tx) AddEdge(parent, child string) {
+	tx.batch.AddEdge(parent, child)
+}
+
+func (tx *Tx) DeleteEdge(parent, child string) {
+	tx.batch.DeleteEdge(parent, child)
+}
+
+// Batch returns the staged mutations, e.g. to apply them to a GraphStore.
+func (tx *Tx) Batch() *Batch {
+	return &tx.batch
+}
+
+// Commit applies the staged mutations. If any of them is invalid, the graph
+// is left untouched and the error is a *BatchError.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return fmt.Errorf("transaction is already done")
+	}
+	tx.done = true
+
+	return tx.dag.Apply(&tx.batch)
+}
+
+// Rollback discards the staged mutations.
+func (tx *Tx) Rollback() {
+	tx.done = true
+	tx.batch.Reset()
+}
+
+// Apply validates the batch and applies it to the graph. If any of the
+// mutations is invalid, the graph is left untouched and the error is a *BatchError.
+func (d *DAG) Apply(b *Batch) error {
+	if _, err := b.Stage(d); err != nil {
+		return err
+	}
+
+	// The batch is valid, so none of the mutations below can fail.
+	for _, op := range b.Ops() {
+		switch op.Type {
+		case OpAddVertex:
+			d.AddVertex(op.Vertex)
+		case OpDeleteVertex:
+			if err := d.DeleteVertex(d.vertices[op.ID]); err != nil {
+				return err
+			}
+		case OpUpdateVertex:
+			if err := d.UpdateVertex(op.Vertex); err != nil {
+				return err
+			}
+		case OpAddEdge:
+			if err := d.AddEdge(d.vertices[op.Parent], d.vertices[op.Child]); err != nil {
+				return err
+			}
+		case OpDeleteEdge:
+			d.DeleteEdge(d.vertices[op.Parent], d.vertices[op.Child])
+		}
+	}
+
+	return nil
+}
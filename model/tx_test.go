@@ -0,0 +1,290 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTxCommit(t *testing.T) {
+	graph := NewDAG()
+
+	tx := graph.Begin()
+	tx.AddVertex(NewVertex("a", false, 0))
+	tx.AddVertex(NewVertex("b", false, 1))
+	tx.AddVertex(NewVertex("c", false, 2))
+	tx.AddEdge("a", "b")
+	tx.AddEdge("b", "c")
+
+	if graph.CountVertex() != 0 {
+		t.Fatal("graph must not be modified before commit")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if graph.CountVertex() != 3 || graph.CountEdge() != 2 {
+		t.Fatalf("expected 3 vertices and 2 edges, found %d and %d", graph.CountVertex(), graph.CountEdge())
+	}
+
+	if err := tx.Commit(); err == nil {
+		t.Fatal("expected error on second commit")
+	}
+
+	tx = graph.Begin()
+	tx.DeleteVertex("b")
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := graph.GetVertex("a")
+	if graph.CountVertex() != 2 || graph.CountEdge() != 0 || len(a.Children) != 0 {
+		t.Fatal("deleting a vertex must delete its edges")
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	graph := NewDAG()
+	graph.AddVertex(NewVertex("a", false, 0))
+	graph.AddVertex(NewVertex("b", false, 1))
+
+	tests := []struct {
+		name  string
+		stage func(tx *Tx)
+		index int
+	}{
+		{
+			name: "duplicate edge",
+			stage: func(tx *Tx) {
+				tx.AddVertex(NewVertex("c", false, 2))
+				tx.AddEdge("a", "b")
+				tx.AddEdge("a", "b")
+			},
+			index: 2,
+		},
+		{
+			name: "cycle",
+			stage: func(tx *Tx) {
+				tx.AddVertex(NewVertex("c", false, 2))
+				tx.AddEdge("a", "b")
+				tx.AddEdge("b", "c")
+				tx.AddEdge("c", "a")
+			},
+			index: 3,
+		},
+		{
+			name: "missing vertex",
+			stage: func(tx *Tx) {
+				tx.AddEdge("a", "b")
+				tx.AddEdge("b", "c")
+			},
+			index: 1,
+		},
+		{
+			name: "duplicate vertex",
+			stage: func(tx *Tx) {
+				tx.AddVertex(NewVertex("a", false, 0))
+			},
+			index: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tx := graph.Begin()
+			test.stage(tx)
+
+			err := tx.Commit()
+
+			var batchErr *BatchError
+			if !errors.As(err, &batchErr) {
+				t.Fatalf("expected a batch error, found %v", err)
+			}
+			if batchErr.Index != test.index {
+				t.Fatalf("expected operation %d to fail, found %d: %s", test.index, batchErr.Index, err)
+			}
+
+			if graph.CountVertex() != 2 || graph.CountEdge() != 0 {
+				t.Fatal("graph must not be modified by a failed commit")
+			}
+		})
+	}
+}
+
+func TestUpdateVertex(t *testing.T) {
+	graph := NewDAG()
+	graph.AddVertex(NewVertex("a", false, 0))
+	graph.AddVertex(NewVertex("b", false, 1))
+	if err := graph.AddEdge(graph.vertices["a"], graph.vertices["b"]); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := graph.Snapshot()
+
+	var events []Event
+	defer graph.Subscribe(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	}))()
+
+	update := NewVertex("b", true, 2)
+	update.Index = 3
+	update.Properties = map[string]string{"name": "B"}
+
+	tx := graph.Begin()
+	tx.UpdateVertex(update)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := graph.GetVertex("b")
+	if !b.Flag || b.Rank != 2 || b.Index != 3 || b.Properties["name"] != "B" {
+		t.Fatalf("unexpected vertex %+v", b)
+	}
+	if _, ok := b.Parents["a"]; !ok {
+		t.Fatal("updating a vertex must keep its edges")
+	}
+
+	update.Properties["name"] = "C"
+	if b.Properties["name"] != "B" {
+		t.Fatal("the updated vertex must not share the properties")
+	}
+
+	old, _ := snapshot.GetVertex("b")
+	if old.Flag || old.Properties != nil {
+		t.Fatal("the snapshot must not be modified")
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, found %d", len(events))
+	}
+	e, ok := events[0].(VertexUpdated)
+	if !ok || e.Old.Flag || e.Vertex != b {
+		t.Fatalf("unexpected event %+v", events[0])
+	}
+
+	tx = graph.Begin()
+	tx.UpdateVertex(NewVertex("c", false, 0))
+	if err := tx.Commit(); !errors.Is(err, ErrVertexNotFound) {
+		t.Fatalf("expected ErrVertexNotFound, found %v", err)
+	}
+}
+
+func TestStager(t *testing.T) {
+	graph := NewDAG()
+	graph.AddVertex(NewVertex("a", false, 0))
+
+	s := NewStager(graph)
+
+	ops := []struct {
+		op    Op
+		valid bool
+	}{
+		{Op{Type: OpAddVertex, Vertex: NewVertex("b", false, 1)}, true},
+		{Op{Type: OpAddVertex, Vertex: NewVertex("a", false, 1)}, false},
+		{Op{Type: OpAddEdge, Parent: "a", Child: "b"}, true},
+		{Op{Type: OpAddEdge, Parent: "b", Child: "a"}, false},
+		{Op{Type: OpAddEdge, Parent: "a", Child: "c"}, false},
+		{Op{Type: OpAddVertex, Vertex: NewVertex("c", false, 2)}, true},
+		{Op{Type: OpAddEdge, Parent: "b", Child: "c"}, true},
+		{Op{Type: OpAddEdge, Parent: "c", Child: "a"}, false},
+	}
+	for i, test := range ops {
+		err := s.Add(test.op)
+		if (err == nil) != test.valid {
+			t.Fatalf("operation %d: expected valid %v, found %v", i, test.valid, err)
+		}
+	}
+
+	if s.Len() != 4 {
+		t.Fatalf("expected 4 valid operations, found %d", s.Len())
+	}
+	if graph.CountVertex() != 1 {
+		t.Fatal("graph must not be modified by the stager")
+	}
+
+	if err := graph.Apply(s.Batch()); err != nil {
+		t.Fatal(err)
+	}
+	if graph.CountVertex() != 3 || graph.CountEdge() != 2 {
+		t.Fatalf("expected 3 vertices and 2 edges, found %d and %d", graph.CountVertex(), graph.CountEdge())
+	}
+}
+
+// A ParentsGetter source is only asked for the children of a deleted vertex.
+func TestStageParents(t *testing.T) {
+	graph := NewDAG()
+	hub := NewVertex("hub", false, 0)
+	graph.AddVertex(hub)
+	for i := 0; i < 100; i++ {
+		v := NewVertex(fmt.Sprintf("child-%d", i), false, 1)
+		graph.AddVertex(v)
+		if err := graph.AddEdge(hub, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var children []string
+	src := ParentsGetterFunc(func(id string, withChildren bool) (*Vertex, error) {
+		v, err := graph.GetVertex(id)
+		if withChildren {
+			children = append(children, id)
+		}
+		if err != nil || withChildren {
+			return v, err
+		}
+		v = v.Clone()
+		v.Children = make(map[string]struct{})
+		return v, nil
+	})
+
+	batch := NewBatch()
+	batch.AddVertex(NewVertex("new", false, 1))
+	batch.AddEdge("hub", "new")
+	batch.DeleteEdge("hub", "child-0")
+	batch.AddEdge("child-1", "child-2")
+	changes, err := batch.Stage(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != 0 {
+		t.Fatalf("expected no children to be read, found the children of %v", children)
+	}
+	if v := changes.Vertices["hub"]; len(v.Children) != 1 {
+		t.Fatalf("expected the staged hub to have the new child only, found %v", v.Children)
+	}
+
+	for _, invalid := range []Op{
+		{Type: OpAddEdge, Parent: "hub", Child: "child-1"},
+		{Type: OpDeleteEdge, Parent: "hub", Child: "child-0"},
+		{Type: OpAddEdge, Parent: "child-2", Child: "hub"},
+	} {
+		b := NewBatch()
+		b.ops = append(append([]Op{}, batch.ops...), invalid)
+		if _, err := b.Stage(src); err == nil {
+			t.Fatalf("expected %v to be invalid", invalid)
+		}
+	}
+
+	// The children of a deleted vertex are read, and merged with the staged
+	// edges.
+	batch.DeleteVertex("hub")
+	changes, err = batch.Stage(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := batch.Stage(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Vertices) != len(expected.Vertices) || len(changes.Events) != len(expected.Events) {
+		t.Fatalf("expected %d vertices and %d events, found %d and %d",
+			len(expected.Vertices), len(expected.Events), len(changes.Vertices), len(changes.Events))
+	}
+	for id, v := range expected.Vertices {
+		if !reflect.DeepEqual(changes.Vertices[id], v) {
+			t.Fatalf("expected %+v, found %+v", v, changes.Vertices[id])
+		}
+	}
+}
@@ -6,36 +6,76 @@ import (
 )
 
 type Vertex struct {
-	ID             string
-	Index          int
-	Flag           bool
-	Rank           int
-	Parents        map[string]struct{}
-	Children       map[string]struct{}
+	ID       string
+	Index    int
+	Flag     bool
+	Rank     int
+	Parents  map[string]struct{}
+	Children map[string]struct{}
+
+	// Properties are optional attributes of the vertex, e.g. a name.
+	// It is nil if the vertex does not have any.
+	Properties map[string]string
 }
 
 func NewVertex(id string, flag bool, rank int) *Vertex {
 	v := &Vertex{
-		ID:             id,
-		Parents:        make(map[string]struct{}),
-		Children:       make(map[string]struct{}),
-		Flag:           flag,
-		Rank:           rank,
+		ID:       id,
+		Parents:  make(map[string]struct{}),
+		Children: make(map[string]struct{}),
+		Flag:     flag,
+		Rank:     rank,
 	}
 
 	return v
 }
+
+// Clone returns a copy of the vertex, which does not share the parents, children and properties.
+func (v *Vertex) Clone() *Vertex {
+	c := *v
+	c.Properties = v.CloneProperties()
+	c.Parents = make(map[string]struct{}, len(v.Parents))
+	c.Children = make(map[string]struct{}, len(v.Children))
+
+	for p := range v.Parents {
+		c.Parents[p] = struct{}{}
+	}
+
+	for ch := range v.Children {
+		c.Children[ch] = struct{}{}
+	}
+
+	return &c
+}
+
+// CloneProperties returns a copy of the properties.
+func (v *Vertex) CloneProperties() map[string]string {
+	if v.Properties == nil {
+		return nil
+	}
+
+	properties := make(map[string]string, len(v.Properties))
+	for k, p := range v.Properties {
+		properties[k] = p
+	}
+
+	return properties
+}
+
 func (v *Vertex) String() string {
 	result := fmt.Sprintf("ID: %s - Parents: %d - Children: %d - Flag: %v\n", v.ID, len(v.Parents), len(v.Children), v.Flag)
 
 	return result
 }
 
+// DOT writes the edges from the parents of the vertex in the DOT language.
 func (v *Vertex) DOT(w io.Writer, graph *DAG) error {
-	for p, _ := range v.Parents {
-		pv, _ := graph.GetVertex(p)
+	for _, p := range sortedSet(v.Parents) {
+		if _, err := graph.GetVertex(p); err != nil {
+			return err
+		}
 
-		_, err := fmt.Fprintf(w, "\t%d -> %d;\n", v.Index, pv.Index)
+		_, err := fmt.Fprintf(w, "\t%s -> %s;\n", dotID(p), dotID(v.ID))
 		if err != nil {
 			return err
 		}
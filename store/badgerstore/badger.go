@@ -1,17 +1,46 @@
 package badgerstore
 
 import (
-	"encoding/json"
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/ahmadmuzakkir/dag/model"
 	"github.com/ahmadmuzakkir/dag/store"
 	"github.com/dgraph-io/badger"
 )
 
-var _ store.GraphStore = (*BadgerStore)(nil)
+var (
+	_ store.GraphStore = (*BadgerStore)(nil)
+	_ store.Viewer     = (*BadgerStore)(nil)
+)
+
+// The edge keys of store.ParentKey and store.ChildKey are prefixed with a
+// NUL byte, so they sort before the vertex records, which are keyed by ID.
+// The vertices with an ID starting with a NUL byte are rejected.
+var (
+	edgeKeyPrefix = []byte{0}
+	vertexStart   = []byte{1}
+)
+
+func edgeKey(key []byte) []byte {
+	return append(append(make([]byte, 0, len(edgeKeyPrefix)+len(key)), edgeKeyPrefix...), key...)
+}
 
+// BadgerStore keeps a vertex in a record without its edges, and every edge in
+// keys of its own, so a change of an edge only writes its keys. The records
+// written with their edges, before the edge-per-key layout, are read, and
+// rewritten by Migrate.
 type BadgerStore struct {
-	db *badger.DB
+	db    *badger.DB
+	codec store.AtomicCodec
+
+	// Migrate spans several transactions, so it excludes Apply, which holds
+	// a read lock.
+	migrating sync.RWMutex
+
+	observers model.Observers
 }
 
 func NewBadgerStore(db *badger.DB) *BadgerStore {
@@ -20,117 +49,415 @@ func NewBadgerStore(db *badger.DB) *BadgerStore {
 	}
 }
 
+// SetCodec sets the codec of the records written from now on. The records
+// of any codec are read. It may be called while the store is used.
+func (b *BadgerStore) SetCodec(c store.Codec) {
+	b.codec.Store(c)
+}
+
 func (b *BadgerStore) Get() (*model.DAG, error) {
 	raw, err := b.get()
 	if err != nil {
 		return nil, err
 	}
 
-	var m = make(map[string]*model.Vertex)
 	graph := model.NewDAG()
 	for _, v := range raw {
-		vertex := model.NewVertex(v.ID, v.Flag, v.Rank)
-		for _, parent := range v.Parents {
-			vertex.Parents[parent] = struct{}{}
+		graph.AddVertex(v)
+	}
+
+	return graph, nil
+}
+
+func (b *BadgerStore) Insert(g *model.DAG) error {
+	// Clear the old data first.
+	if err := b.clear(); err != nil {
+		return err
+	}
+
+	if err := b.insert(g.Vertices()); err != nil {
+		return err
+	}
+
+	b.observers.Emit(model.GraphReplaced{Graph: g})
+
+	return nil
+}
+
+func (b *BadgerStore) Walk(fn func(v *model.Vertex) error) error {
+	return b.db.View(func(txn *badger.Txn) error {
+		start := vertexStart
+		for start != nil {
+			// A transaction allows a single iterator at a time, so the
+			// records are read in batches, and their edges after the
+			// iterator is closed.
+			var list []*model.Vertex
+			var err error
+			if list, start, err = b.readWalkBatch(txn, start); err != nil {
+				return err
+			}
+
+			for _, v := range list {
+				if !store.HasEdges(v) {
+					b.readEdges(txn, v, true)
+				}
+				if err := fn(v); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// The number of records read by an iterator of Walk.
+const walkBatchSize = 1000
+
+// readWalkBatch reads the next batch of records of Walk from the key, and
+// returns the key of the next batch, or nil after the last record.
+func (b *BadgerStore) readWalkBatch(txn *badger.Txn, start []byte) ([]*model.Vertex, []byte, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchSize = walkBatchSize
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var list []*model.Vertex
+	for it.Seek(start); it.Valid(); it.Next() {
+		if len(list) == walkBatchSize {
+			return list, it.Item().KeyCopy(nil), nil
 		}
 
-		for _, children := range v.Children {
-			vertex.Children[children] = struct{}{}
+		data, err := it.Item().Value()
+		if err != nil {
+			return nil, nil, err
 		}
 
-		m[v.ID] = vertex
-		graph.AddVertex(vertex)
+		v, err := b.unmarshal(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		list = append(list, v)
 	}
 
-	// for _, v := range raw {
-	// 	for _, c := range v.Parents {
-	// 		graph.AddEdge(m[c], m[v.ID])
-	// 	}
-	// }
+	return list, nil, nil
+}
 
-	return graph, nil
+// Apply validates the batch against the stored graph and applies it in a single transaction.
+// The batch must fit into one Badger transaction, otherwise it is rejected
+// with an error wrapping badger.ErrTxnTooBig, e.g. the deletion of a vertex
+// with a very large number of edges. Only the keys of the changed edges are
+// written.
+func (b *BadgerStore) Apply(batch *model.Batch) error {
+	b.migrating.RLock()
+	defer b.migrating.RUnlock()
+
+	var changes *model.Changes
+
+	err := b.db.Update(func(txn *badger.Txn) error {
+		// The vertices read, to write only the keys of the changed edges,
+		// and the records with their edges. The children of a vertex are
+		// only read to delete it.
+		old := make(map[string]*model.Vertex)
+		legacy := make(map[string]bool)
+
+		var err error
+		changes, err = batch.Stage(model.ParentsGetterFunc(func(id string, children bool) (*model.Vertex, error) {
+			v, isLegacy, err := b.read(txn, id, children)
+			if err == nil {
+				old[id], legacy[id] = v, isLegacy
+			}
+			return v, err
+		}))
+		if err != nil {
+			return err
+		}
+
+		for id, v := range changes.Vertices {
+			if err := b.putChanged(txn, id, old[id], legacy[id], v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err == badger.ErrTxnTooBig {
+		return fmt.Errorf("the batch changes %d vertices and their edges, which do not fit into one Badger transaction, split it: %w", len(changes.Vertices), err)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, e := range changes.Events {
+		b.observers.Emit(e)
+	}
+
+	return nil
 }
 
-func (b *BadgerStore) Insert(g *model.DAG) error {
-	// Convert each vertex into the internal representation of vertex.
-	vertices := g.Vertices()
+// writer is implemented by a transaction and by a splitTxn.
+type writer interface {
+	Set(key, val []byte) error
+	Delete(key []byte) error
+}
 
-	var data []*badgerVertex
+// put sets the keys of all the edges of the vertex, then writes its record,
+// so the record of a vertex is rewritten last by a splitTxn, e.g. a new
+// record or a record written with its edges.
+func (b *BadgerStore) put(w writer, v *model.Vertex) error {
+	if err := checkID(v.ID); err != nil {
+		return err
+	}
 
-	for _, vertex := range vertices {
-		v := &badgerVertex{
-			ID:   vertex.ID,
-			Flag: vertex.Flag,
-			Rank: vertex.Rank,
-			Index: vertex.Index,
-		}
+	setKey, deleteKey := keyWriters(w)
+	if err := store.DiffEdgeKeys(nil, v, setKey, deleteKey); err != nil {
+		return err
+	}
 
-		for parentID := range vertex.Parents {
-			v.Parents = append(v.Parents, parentID)
+	return b.putRecord(w, v.ID, v)
+}
+
+// putChanged writes the keys of the changed edges of the vertex with the ID
+// changed by a batch, see store.DiffParentKeys, then its record. old is the
+// vertex read by the batch, nil for a new vertex, and the vertex is nil if it
+// is deleted. The keys of all the edges of a record with its edges, legacy,
+// are written, as it is rewritten without them.
+func (b *BadgerStore) putChanged(w writer, id string, old *model.Vertex, legacy bool, v *model.Vertex) error {
+	if v != nil {
+		if err := checkID(id); err != nil {
+			return err
 		}
+	}
 
-		for childrenID := range vertex.Children {
-			v.Children = append(v.Children, childrenID)
+	setKey, deleteKey := keyWriters(w)
+	if legacy {
+		// A record with its edges may have some of their keys, written by a
+		// Migrate which failed before rewriting the record, so they are
+		// deleted before the keys of the vertex are written.
+		if err := store.DiffEdgeKeys(old, nil, nil, deleteKey); err != nil {
+			return err
+		}
+		if err := store.DiffEdgeKeys(nil, v, setKey, deleteKey); err != nil {
+			return err
 		}
-		data = append(data, v)
+	}
+	if err := store.DiffParentKeys(old, v, setKey, deleteKey); err != nil {
+		return err
 	}
 
-	// Clear the old data first.
-	if err := b.clear(); err != nil {
+	return b.putRecord(w, id, v)
+}
+
+// checkID rejects the IDs which would be read as edge keys.
+func checkID(id string) error {
+	if strings.HasPrefix(id, string(edgeKeyPrefix)) {
+		return fmt.Errorf("vertex ID %q starts with a NUL byte, which Badger keeps for the edge keys", id)
+	}
+
+	return nil
+}
+
+// keyWriters returns the functions which set and delete an edge key.
+func keyWriters(w writer) (set, del func(key []byte) error) {
+	set = func(key []byte) error {
+		return w.Set(edgeKey(key), nil)
+	}
+	del = func(key []byte) error {
+		return w.Delete(edgeKey(key))
+	}
+
+	return set, del
+}
+
+// putRecord writes the record of the vertex with the ID, without its edges,
+// or deletes it if the vertex is nil.
+func (b *BadgerStore) putRecord(w writer, id string, v *model.Vertex) error {
+	if v == nil {
+		return w.Delete([]byte(id))
+	}
+
+	data, err := store.EncodeRecord(b.codec.Load(), store.WithoutEdges(v))
+	if err != nil {
+		return err
+	}
+
+	return w.Set([]byte(id), data)
+}
+
+// splitTxn writes in a transaction, which is committed and replaced by a new
+// one once it is too big, so its writes are not atomic.
+type splitTxn struct {
+	db  *badger.DB
+	txn *badger.Txn
+}
+
+func newSplitTxn(db *badger.DB) *splitTxn {
+	return &splitTxn{db: db, txn: db.NewTransaction(true)}
+}
+
+func (s *splitTxn) Set(key, val []byte) error {
+	return s.write(func(txn *badger.Txn) error {
+		return txn.Set(key, val)
+	})
+}
+
+func (s *splitTxn) Delete(key []byte) error {
+	return s.write(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+}
+
+func (s *splitTxn) write(fn func(txn *badger.Txn) error) error {
+	err := fn(s.txn)
+	if err != badger.ErrTxnTooBig {
+		return err
+	}
+
+	if err := s.txn.Commit(nil); err != nil {
 		return err
 	}
+	s.txn = s.db.NewTransaction(true)
+
+	return fn(s.txn)
+}
+
+// Commit commits the current transaction.
+func (s *splitTxn) Commit() error {
+	return s.txn.Commit(nil)
+}
+
+// Discard discards the current transaction, if it is not committed.
+func (s *splitTxn) Discard() {
+	s.txn.Discard()
+}
+
+func (b *BadgerStore) GetVertex(id string) (*model.Vertex, error) {
+	var vertex *model.Vertex
+	err := b.db.View(func(txn *badger.Txn) error {
+		var err error
+		vertex, err = b.getByID(txn, id)
+		return err
+	})
+
+	return vertex, err
+}
+
+// View returns a getter of the vertices of a read transaction, which reads
+// the children of a vertex only if they are needed.
+func (b *BadgerStore) View() (model.VertexGetter, func(), error) {
+	txn := b.db.NewTransaction(false)
+
+	get := model.ParentsGetterFunc(func(id string, children bool) (*model.Vertex, error) {
+		v, _, err := b.read(txn, id, children)
+		return v, err
+	})
+
+	return get, txn.Discard, nil
+}
+
+func (b *BadgerStore) AddVertex(v *model.Vertex) error {
+	batch := model.NewBatch()
+	batch.AddVertex(v)
+
+	return store.OpError(b.Apply(batch))
+}
+
+func (b *BadgerStore) UpdateVertex(v *model.Vertex) error {
+	batch := model.NewBatch()
+	batch.UpdateVertex(v)
+
+	return store.OpError(b.Apply(batch))
+}
+
+func (b *BadgerStore) DeleteVertex(id string) error {
+	batch := model.NewBatch()
+	batch.DeleteVertex(id)
 
-	return b.insert(data)
+	return store.OpError(b.Apply(batch))
 }
+
+func (b *BadgerStore) AddEdge(parent, child string) error {
+	batch := model.NewBatch()
+	batch.AddEdge(parent, child)
+
+	return store.OpError(b.Apply(batch))
+}
+
+func (b *BadgerStore) DeleteEdge(parent, child string) error {
+	batch := model.NewBatch()
+	batch.DeleteEdge(parent, child)
+
+	return store.OpError(b.Apply(batch))
+}
+
+func (b *BadgerStore) Subscribe(o model.Observer) func() {
+	return b.observers.Subscribe(o)
+}
+
 func (b *BadgerStore) AncestorsBFS(id string, filter func(*model.Vertex) bool) ([]*model.Vertex, error) {
+	return b.ancestorsBFS(id, filter, true)
+}
+
+// ancestorsBFS returns the ancestors of the vertex, without their children
+// unless children is set, since only their parents are needed to traverse
+// the graph.
+func (b *BadgerStore) ancestorsBFS(id string, filter func(*model.Vertex) bool, children bool) ([]*model.Vertex, error) {
 	var list []*model.Vertex
 	err := b.db.View(func(txn *badger.Txn) error {
-		_, err := b.getByID(txn, id)
+		v, _, err := b.read(txn, id, children)
 		if err != nil {
 			return err
 		}
 
-		q := []string{id}
-		visited := make(map[string]struct{})
-		visited[id] = struct{}{}
+		list, err = b.bfs(txn, v, filter, children)
+		return err
+	})
 
-		for len(q) != 0 {
-			u := q[0]
-			q = q[1:len(q):len(q)]
+	return list, err
+}
 
-			v, err := b.getByID(txn, u)
-			if err != nil {
-				return err
-			}
+// bfs returns the ancestors of the vertex v, read in the transaction, like
+// ancestorsBFS.
+func (b *BadgerStore) bfs(txn *badger.Txn, v *model.Vertex, filter func(*model.Vertex) bool, children bool) ([]*model.Vertex, error) {
+	var list []*model.Vertex
 
-			for p, _ := range v.Parents {
-				if _, ok := visited[p]; !ok {
-					q = append(q, p)
-					visited[p] = struct{}{}
+	q := []*model.Vertex{v}
+	visited := make(map[string]struct{})
+	visited[v.ID] = struct{}{}
 
-					pv, err := b.getByID(txn, p)
-					if err != nil {
-						return err
-					}
+	for len(q) != 0 {
+		u := q[0]
+		q = q[1:len(q):len(q)]
 
-					if filter == nil || filter(pv) {
-						list = append(list, pv)
-					}
+		for p, _ := range u.Parents {
+			if _, ok := visited[p]; !ok {
+				visited[p] = struct{}{}
+
+				pv, _, err := b.read(txn, p, children)
+				if err != nil {
+					return nil, err
+				}
+				q = append(q, pv)
+
+				if filter == nil || filter(pv) {
+					list = append(list, pv)
 				}
 			}
 		}
+	}
 
-		return nil
-	})
-
-	return list, err
+	return list, nil
 }
 
 func (b *BadgerStore) AncestorsDFS(id string, filter func(*model.Vertex) bool) ([]*model.Vertex, error) {
+	return b.ancestorsDFS(id, filter, true)
+}
+
+// ancestorsDFS returns the ancestors of the vertex, like ancestorsBFS.
+func (b *BadgerStore) ancestorsDFS(id string, filter func(*model.Vertex) bool, children bool) ([]*model.Vertex, error) {
 	var list []*model.Vertex
 	err := b.db.View(func(txn *badger.Txn) error {
-		_, err := b.getByID(txn, id)
-		if err != nil {
+		if _, _, err := b.read(txn, id, false); err != nil {
 			return err
 		}
 
@@ -144,7 +471,7 @@ func (b *BadgerStore) AncestorsDFS(id string, filter func(*model.Vertex) bool) (
 			if _, ok := visited[u]; !ok {
 				visited[u] = struct{}{}
 
-				v, err := b.getByID(txn, u)
+				v, _, err := b.read(txn, u, children)
 				if err != nil {
 					return err
 				}
@@ -172,9 +499,9 @@ func (b *BadgerStore) Reach(algo store.Algo, id string) (int, error) {
 	var err error
 
 	if algo == store.ALGO_DFS {
-		list, err = b.AncestorsDFS(id, nil)
+		list, err = b.ancestorsDFS(id, nil, false)
 	} else {
-		list, err = b.AncestorsBFS(id, nil)
+		list, err = b.ancestorsBFS(id, nil, false)
 	}
 	if err != nil {
 		return 0, err
@@ -188,13 +515,13 @@ func (b *BadgerStore) ConditionalReach(algo store.Algo, id string, flag bool) (i
 	var err error
 
 	if algo == store.ALGO_DFS {
-		list, err = b.AncestorsDFS(id, func(v *model.Vertex) bool {
+		list, err = b.ancestorsDFS(id, func(v *model.Vertex) bool {
 			return v.Flag == flag
-		})
+		}, false)
 	} else {
-		list, err = b.AncestorsBFS(id, func(v *model.Vertex) bool {
+		list, err = b.ancestorsBFS(id, func(v *model.Vertex) bool {
 			return v.Flag == flag
-		})
+		}, false)
 	}
 
 	if err != nil {
@@ -240,6 +567,36 @@ func (b *BadgerStore) ConditionalList(algo store.Algo, id string, flag bool) ([]
 	return list, nil
 }
 
+// MustPassAncestors reads the vertex and its ancestors in one transaction.
+func (b *BadgerStore) MustPassAncestors(id string) ([]*model.Vertex, error) {
+	var vertex *model.Vertex
+	var ancestors []*model.Vertex
+
+	err := b.db.View(func(txn *badger.Txn) error {
+		var err error
+		if vertex, err = b.getByID(txn, id); err != nil {
+			return err
+		}
+
+		ancestors, err = b.bfs(txn, vertex, nil, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return model.MustPassAncestors(vertex, ancestors)
+}
+
+func (b *BadgerStore) Stats() (*model.Stats, error) {
+	graph, err := b.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	return graph.Stats()
+}
+
 func (b *BadgerStore) GetVertexByPosition(index int) (*model.Vertex, error) {
 	var vertex *model.Vertex
 
@@ -250,7 +607,7 @@ func (b *BadgerStore) GetVertexByPosition(index int) (*model.Vertex, error) {
 		defer it.Close()
 
 		i := 0
-		for it.Rewind(); it.Valid(); it.Next() {
+		for it.Seek(vertexStart); it.Valid(); it.Next() {
 			if index == i {
 				item := it.Item()
 				data, err := item.Value()
@@ -265,12 +622,23 @@ func (b *BadgerStore) GetVertexByPosition(index int) (*model.Vertex, error) {
 		}
 		return nil
 	})
+	if err != nil || vertex == nil {
+		return vertex, err
+	}
+
+	// Badger allows a single iterator at a time in a transaction, so the
+	// edges are read in another one.
+	err = b.db.View(func(txn *badger.Txn) error {
+		var err error
+		vertex, err = b.getByID(txn, vertex.ID)
+		return err
+	})
 
 	return vertex, err
 }
 
-func (b *BadgerStore) get() ([]*badgerVertex, error) {
-	var list []*badgerVertex
+func (b *BadgerStore) get() ([]*model.Vertex, error) {
+	var list []*model.Vertex
 
 	err := b.db.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
@@ -278,19 +646,40 @@ func (b *BadgerStore) get() ([]*badgerVertex, error) {
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
-		for it.Rewind(); it.Valid(); it.Next() {
+		vertices := make(map[string]*model.Vertex)
+		for it.Seek(vertexStart); it.Valid(); it.Next() {
 			item := it.Item()
 			data, err := item.Value()
 			if err != nil {
 				return err
 			}
 
-			var vertex badgerVertex
-			if err = json.Unmarshal(data, &vertex); err != nil {
+			vertex, err := b.unmarshal(data)
+			if err != nil {
 				return err
 			}
-			list = append(list, &vertex)
+			list = append(list, vertex)
+			vertices[vertex.ID] = vertex
 		}
+
+		for it.Seek(edgeKeyPrefix); it.ValidForPrefix(edgeKeyPrefix); it.Next() {
+			key := it.Item().Key()[len(edgeKeyPrefix):]
+			id, other, err := store.ParseEdgeKey(key)
+			if err != nil {
+				return err
+			}
+			v := vertices[id]
+			if v == nil {
+				return fmt.Errorf("edge key of missing vertex %s", id)
+			}
+
+			if bytes.HasPrefix(key, store.ParentsKeyPrefix) {
+				v.Parents[other] = struct{}{}
+			} else {
+				v.Children[other] = struct{}{}
+			}
+		}
+
 		return nil
 	})
 
@@ -298,32 +687,18 @@ func (b *BadgerStore) get() ([]*badgerVertex, error) {
 }
 
 // Insert the vertices into the database
-func (b *BadgerStore) insert(list []*badgerVertex) error {
-	txn := b.db.NewTransaction(true)
+func (b *BadgerStore) insert(vertices map[string]*model.Vertex) error {
+	txn := newSplitTxn(b.db)
 	defer txn.Discard()
 
-	for i := range list {
-		data, err := json.Marshal(list[i])
-		if err != nil {
+	for _, v := range vertices {
+		if err := b.put(txn, v); err != nil {
 			return err
 		}
-		if err := txn.Set([]byte(list[i].ID), data); err != nil {
-			if err != badger.ErrTxnTooBig {
-				return err
-			}
-			if err := txn.Commit(nil); err != nil {
-				return err
-			}
-
-			txn = b.db.NewTransaction(true)
-			if err := txn.Set([]byte(list[i].ID), data); err != nil {
-				return err
-			}
-		}
 	}
 
 	// Commit the transaction and check for error.
-	if err := txn.Commit(nil); err != nil {
+	if err := txn.Commit(); err != nil {
 		return err
 	}
 
@@ -378,42 +753,171 @@ func (b *BadgerStore) clear() error {
 }
 
 func (b *BadgerStore) getByID(txn *badger.Txn, id string) (*model.Vertex, error) {
+	v, _, err := b.read(txn, id, true)
+
+	return v, err
+}
+
+// read returns the vertex with its parents, and its children if children is
+// set. legacy reports whether its record has its edges, rather than keys.
+func (b *BadgerStore) read(txn *badger.Txn, id string, children bool) (v *model.Vertex, legacy bool, err error) {
 	item, err := txn.Get([]byte(id))
+	if err == badger.ErrKeyNotFound {
+		return nil, false, fmt.Errorf("%w: %s", model.ErrVertexNotFound, id)
+	}
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	data, err := item.Value()
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+
+	if v, err = b.unmarshal(data); err != nil {
+		return nil, false, err
+	}
+	if store.HasEdges(v) {
+		return v, true, nil
 	}
 
-	return b.unmarshal(data)
+	b.readEdges(txn, v, children)
+
+	return v, false, nil
 }
 
-func (b *BadgerStore) unmarshal(data []byte) (*model.Vertex, error) {
-	var v badgerVertex
-	if err := json.Unmarshal(data, &v); err != nil {
-		return nil, err
+// readEdges adds the parents of the vertex, and its children if children is
+// set, from its edge keys.
+func (b *BadgerStore) readEdges(txn *badger.Txn, v *model.Vertex, children bool) {
+	scan(txn, edgeKey(store.ParentsPrefix(v.ID)), v.Parents)
+	if children {
+		scan(txn, edgeKey(store.ChildrenPrefix(v.ID)), v.Children)
 	}
+}
+
+// scan adds the IDs of the edge keys with the prefix to ids.
+func scan(txn *badger.Txn, prefix []byte, ids map[string]struct{}) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
 
-	vertex := model.NewVertex(v.ID, v.Flag, v.Rank)
-	for _, parent := range v.Parents {
-		vertex.Parents[parent] = struct{}{}
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		ids[string(it.Item().Key()[len(prefix):])] = struct{}{}
 	}
+}
 
-	for childrenID := range vertex.Children {
-		v.Children = append(v.Children, childrenID)
+func (b *BadgerStore) unmarshal(data []byte) (*model.Vertex, error) {
+	return store.DecodeRecord(data)
+}
+
+// The number of writes, of records and edge keys, of a batch of Migrate.
+const migrateBatchWrites = 10000
+
+// Migrate rewrites in place the records which are not written with the codec
+// of the store at the current schema version, e.g. the JSON records of schema
+// version 0, or which have the edges of the vertex, rather than edge keys,
+// and returns their number. The records are rewritten in batches of writes,
+// in as many transactions as they need, e.g. for a vertex with a very large
+// number of edges. The edge keys of a vertex are written before its record,
+// which is read until it is rewritten, so Migrate can be run again if it
+// fails. The mutations wait for Migrate.
+func (b *BadgerStore) Migrate() (int, error) {
+	b.migrating.Lock()
+	defer b.migrating.Unlock()
+
+	migrated := 0
+	var after []byte
+
+	for {
+		var batch *migrateBatch
+		err := b.db.View(func(txn *badger.Txn) error {
+			var err error
+			batch, err = b.readMigrateBatch(txn, after)
+			return err
+		})
+		if err != nil {
+			return migrated, err
+		}
+
+		if err := b.writeMigrateBatch(batch.vertices); err != nil {
+			return migrated, err
+		}
+
+		migrated += len(batch.vertices)
+		if !batch.more {
+			return migrated, nil
+		}
+		after = batch.last
 	}
+}
 
-	return vertex, nil
+// writeMigrateBatch rewrites the records of the vertices, and writes the keys
+// of their edges.
+func (b *BadgerStore) writeMigrateBatch(vertices []*model.Vertex) error {
+	txn := newSplitTxn(b.db)
+	defer txn.Discard()
+
+	for _, v := range vertices {
+		// The edges of a record do not have keys yet.
+		if err := b.put(txn, v); err != nil {
+			return err
+		}
+	}
+
+	return txn.Commit()
 }
 
-type badgerVertex struct {
-	ID       string   `json:"id"`
-	Parents  []string `json:"parents"`
-	Children []string `json:"children"`
-	Flag     bool     `json:"flag"`
-	Rank     int      `json:"rank"`
-	Index    int      `json:"index"`
+// migrateBatch is a batch of records of Migrate.
+type migrateBatch struct {
+	vertices []*model.Vertex
+	writes   int
+
+	// The last key read, and whether there are more keys after it.
+	last []byte
+	more bool
+}
+
+// readMigrateBatch reads the next batch of records to rewrite, after the key
+// if it is not nil.
+func (b *BadgerStore) readMigrateBatch(txn *badger.Txn, after []byte) (*migrateBatch, error) {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	it.Seek(vertexStart)
+	if after != nil {
+		it.Seek(after)
+		if it.Valid() && bytes.Equal(it.Item().Key(), after) {
+			it.Next()
+		}
+	}
+
+	batch := &migrateBatch{}
+	for ; it.Valid(); it.Next() {
+		if batch.writes >= migrateBatchWrites {
+			batch.more = true
+			break
+		}
+
+		item := it.Item()
+		batch.last = item.KeyCopy(batch.last)
+
+		data, err := item.Value()
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := b.unmarshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("vertex %s: %w", item.Key(), err)
+		}
+		if !store.NeedsMigration(data, b.codec.Load()) && !store.HasEdges(v) {
+			continue
+		}
+
+		batch.vertices = append(batch.vertices, v)
+		batch.writes += 1 + len(v.Parents) + len(v.Children)
+	}
+
+	return batch, nil
 }
@@ -1,28 +1,24 @@
 package badgerstore
 
 import (
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
-	"time"
 
 	"github.com/ahmadmuzakkir/dag/model"
 	"github.com/ahmadmuzakkir/dag/store"
+	"github.com/ahmadmuzakkir/dag/store/storetest"
 	"github.com/dgraph-io/badger"
 )
 
 const (
-	testBadgerDir = "/tmp/badger_test"
-	testGraphSize = 100000
+	testBadgerDir         = "/tmp/badger_test"
+	testBadgerTopologyDir = "/tmp/badger_test_topology"
 )
 
-// Run the tests for both BFS and DFS
-var tesalgos = []testalgo{
-	testalgo{name: "BFS", algo: store.ALGO_BFS},
-	testalgo{name: "DFS", algo: store.ALGO_DFS},
-}
-
 // Initiate the database and insert a new graph.
 func init() {
 	ds, teardown, err := getBadgerDataStore()
@@ -31,149 +27,308 @@ func init() {
 		log.Fatal(err)
 	}
 
-	graph := model.GenerateGraph(testGraphSize)
-
-	err = ds.Insert(graph)
-	if err != nil {
+	if err := storetest.InsertBenchGraph(ds); err != nil {
 		log.Fatal(err)
 	}
 }
 
 func TestDAG(t *testing.T) {
-	edges := testGraphSize - 1
-
 	ds, teardown, err := getBadgerDataStore()
 	defer teardown()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	graph, err := ds.Get()
+	storetest.CheckBenchGraph(t, ds)
+}
+
+// A batch which does not fit into one transaction is rejected entirely.
+func TestApplyTooBig(t *testing.T) {
+	ds, teardown, err := openSmallBadgerDataStore(t.TempDir())
+	defer teardown()
 	if err != nil {
-		t.Fatalf("failed to get graph: %s", err)
+		t.Fatal(err)
 	}
 
-	foundSize := graph.CountVertex()
-	if foundSize != testGraphSize {
-		t.Fatalf("expected vertices count %d, found %d", testGraphSize, foundSize)
+	graph := model.NewDAG()
+	hub := model.NewVertex("hub", false, 0)
+	graph.AddVertex(hub)
+	for i := 0; i < 5000; i++ {
+		v := model.NewVertex(fmt.Sprintf("child-%d", i), false, 1)
+		graph.AddVertex(v)
+		if err := graph.AddEdge(hub, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ds.Insert(graph); err != nil {
+		t.Fatal(err)
 	}
 
-	foundEdges := graph.CountEdge()
-	if foundEdges != edges {
-		t.Fatalf("expected edges count %d, found %d", edges, foundEdges)
+	err = ds.DeleteVertex("hub")
+	if !errors.Is(err, badger.ErrTxnTooBig) || !strings.Contains(err.Error(), "split it") {
+		t.Fatalf("expected a batch too big error, found %v", err)
 	}
-}
 
-func BenchmarkReach(t *testing.B) {
-	ds, teardown, err := getBadgerDataStore()
-	defer teardown()
+	v, err := ds.GetVertex("hub")
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(v.Children) != 5000 {
+		t.Fatalf("expected 5000 children, found %d", len(v.Children))
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.GraphStore {
+		ds, teardown, err := openBadgerDataStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(teardown)
+
+		return ds
+	})
+}
 
-	// Choose a random vertex
-	v, err := ds.GetVertexByPosition(rand.Intn(testGraphSize))
+func TestMigrate(t *testing.T) {
+	ds, teardown, err := openBadgerDataStore(testBadgerTopologyDir)
+	defer teardown()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	t.ResetTimer()
+	storetest.RunMigrate(t, ds, rawRecords{ds}, migrateBatchWrites/2)
+}
+
+// rawRecords are the records of the vertices.
+type rawRecords struct {
+	ds *BadgerStore
+}
+
+func (r rawRecords) Put(records map[string][]byte) error {
+	txn := newSplitTxn(r.ds.db)
+	defer txn.Discard()
 
-	for _, algo := range tesalgos {
-		t.Run(algo.name, func(b *testing.B) {
-			for n := 0; n < b.N; n++ {
-				if _, err := ds.Reach(algo.algo, v.ID); err != nil {
-					t.Fatal(err)
-				}
+	for id, data := range records {
+		if err := txn.Set([]byte(id), data); err != nil {
+			return err
+		}
+	}
+
+	return txn.Commit()
+}
+
+func (r rawRecords) Walk(fn func(id string, data []byte) error) error {
+	return r.ds.Walk(func(v *model.Vertex) error {
+		return r.ds.db.View(func(txn *badger.Txn) error {
+			item, err := txn.Get([]byte(v.ID))
+			if err != nil {
+				return err
+			}
+			data, err := item.Value()
+			if err != nil {
+				return err
 			}
+			return fn(v.ID, data)
 		})
-	}
+	})
 }
 
-func BenchmarkConditionalReach(t *testing.B) {
-	ds, teardown, err := getBadgerDataStore()
+// The edge keys written by a Migrate which failed before rewriting the record
+// of a vertex are replaced when the vertex is changed.
+func TestMigratePartial(t *testing.T) {
+	ds, teardown, err := openBadgerDataStore(t.TempDir())
 	defer teardown()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Choose a random vertex
-	v, err := ds.GetVertexByPosition(rand.Intn(testGraphSize))
+	expected := storetest.KnownGraph()
+	records := make(map[string][]byte)
+	for id, v := range expected.Vertices() {
+		data, err := store.EncodeRecord(store.VarintCodec, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records[id] = data
+	}
+	if err := (rawRecords{ds}).Put(records); err != nil {
+		t.Fatal(err)
+	}
+
+	err = ds.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(edgeKey(store.ChildKey("a", "b with space")), nil)
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	t.ResetTimer()
+	batch := model.NewBatch()
+	batch.DeleteEdge("a", "b with space")
+	if err := ds.Apply(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := expected.Apply(batch); err != nil {
+		t.Fatal(err)
+	}
 
-	for _, algo := range tesalgos {
-		t.Run(algo.name, func(b *testing.B) {
-			for n := 0; n < b.N; n++ {
-				if _, err := ds.ConditionalReach(algo.algo, v.ID, true); err != nil {
-					t.Fatal(err)
-				}
+	for _, migrate := range []bool{false, true} {
+		if migrate {
+			if _, err := ds.Migrate(); err != nil {
+				t.Fatal(err)
 			}
-		})
+		}
+
+		graph, err := ds.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(graph.Vertices(), expected.Vertices()) {
+			t.Fatalf("expected the deleted edge to stay deleted, migrated %v", migrate)
+		}
 	}
 }
 
-func BenchmarkList(t *testing.B) {
-	ds, teardown, err := getBadgerDataStore()
+// A vertex with more edges than a transaction can hold is migrated in several.
+func TestMigrateHub(t *testing.T) {
+	ds, teardown, err := openSmallBadgerDataStore(t.TempDir())
 	defer teardown()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Choose a random vertex
-	v, err := ds.GetVertexByPosition(rand.Intn(testGraphSize))
-	if err != nil {
+	expected := model.NewDAG()
+	hub := model.NewVertex("hub", false, 0)
+	expected.AddVertex(hub)
+	for i := 0; i < 5000; i++ {
+		v := model.NewVertex(fmt.Sprintf("child-%d", i), false, 1)
+		expected.AddVertex(v)
+		if err := expected.AddEdge(hub, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Write the records with their edges.
+	txn := newSplitTxn(ds.db)
+	for id, v := range expected.Vertices() {
+		data, err := store.EncodeRecord(store.VarintCodec, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := txn.Set([]byte(id), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := txn.Commit(); err != nil {
 		t.Fatal(err)
 	}
 
-	t.ResetTimer()
+	if n, err := ds.Migrate(); err != nil || n != expected.CountVertex() {
+		t.Fatalf("expected %d migrated records, found %d, %v", expected.CountVertex(), n, err)
+	}
 
-	for _, algo := range tesalgos {
-		t.Run(algo.name, func(b *testing.B) {
-			for n := 0; n < b.N; n++ {
-				if _, err := ds.List(algo.algo, v.ID); err != nil {
-					t.Fatal(err)
-				}
-			}
-		})
+	graph, err := ds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(graph.Vertices(), expected.Vertices()) {
+		t.Fatal("expected the migrated records to be read")
+	}
+
+	v, legacy, err := func() (*model.Vertex, bool, error) {
+		txn := ds.db.NewTransaction(false)
+		defer txn.Discard()
+		return ds.read(txn, "hub", true)
+	}()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy || len(v.Children) != 5000 {
+		t.Fatalf("expected the 5000 children of the hub in edge keys, found %d, legacy %v", len(v.Children), legacy)
 	}
 }
 
-func BenchmarkConditionalList(t *testing.B) {
-	ds, teardown, err := getBadgerDataStore()
+// The IDs starting with a NUL byte would be read as edge keys.
+func TestNULID(t *testing.T) {
+	ds, teardown, err := openBadgerDataStore(t.TempDir())
 	defer teardown()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Choose a random vertex
-	v, err := ds.GetVertexByPosition(rand.Intn(testGraphSize))
+	if err := ds.AddVertex(model.NewVertex("\x00a", false, 0)); err == nil {
+		t.Fatal("expected an error for an ID starting with a NUL byte")
+	}
+
+	graph := model.NewDAG()
+	graph.AddVertex(model.NewVertex("\x00a", false, 0))
+	if err := ds.Insert(graph); err == nil {
+		t.Fatal("expected an error for an ID starting with a NUL byte")
+	}
+
+	found, err := ds.Get()
 	if err != nil {
 		t.Fatal(err)
 	}
+	if found.CountVertex() != 0 {
+		t.Fatalf("expected no vertex, found %d", found.CountVertex())
+	}
+}
 
-	t.ResetTimer()
+func BenchmarkReach(b *testing.B) {
+	benchmarkQuery(b, storetest.Reach)
+}
 
-	for _, algo := range tesalgos {
-		t.Run(algo.name, func(b *testing.B) {
-			for n := 0; n < b.N; n++ {
-				if _, err := ds.ConditionalList(algo.algo, v.ID, true); err != nil {
-					t.Fatal(err)
-				}
-			}
-		})
+func BenchmarkConditionalReach(b *testing.B) {
+	benchmarkQuery(b, storetest.ConditionalReach)
+}
+
+func BenchmarkList(b *testing.B) {
+	benchmarkQuery(b, storetest.List)
+}
+
+func BenchmarkConditionalList(b *testing.B) {
+	benchmarkQuery(b, storetest.ConditionalList)
+}
+
+func BenchmarkTopologies(b *testing.B) {
+	ds, teardown, err := openBadgerDataStore(testBadgerTopologyDir)
+	defer teardown()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	storetest.BenchmarkTopologies(b, ds)
+}
+
+func BenchmarkAddEdge(b *testing.B) {
+	ds, teardown, err := openBadgerDataStore(testBadgerTopologyDir)
+	defer teardown()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	storetest.BenchmarkAddEdge(b, ds)
+}
+
+func benchmarkQuery(b *testing.B, query storetest.Query) {
+	ds, teardown, err := getBadgerDataStore()
+	defer teardown()
+	if err != nil {
+		b.Fatal(err)
 	}
+
+	storetest.BenchmarkQuery(b, ds, query)
 }
 
 func getBadgerDataStore() (*BadgerStore, func(), error) {
-	rand.Seed(time.Now().UnixNano())
+	return openBadgerDataStore(testBadgerDir)
+}
 
+func openBadgerDataStore(dir string) (*BadgerStore, func(), error) {
 	opts := badger.DefaultOptions
-	opts.Dir = testBadgerDir
-	opts.ValueDir = testBadgerDir
+	opts.Dir = dir
+	opts.ValueDir = dir
 	db, err := badger.Open(opts)
 	if err != nil {
 		return nil, func() {}, fmt.Errorf("failed to open badger: %s", err)
@@ -187,7 +342,17 @@ func getBadgerDataStore() (*BadgerStore, func(), error) {
 	return ds, teardown, nil
 }
 
-type testalgo struct {
-	name string
-	algo store.Algo
+// openSmallBadgerDataStore opens a store with small tables, so a transaction
+// holds a few thousand writes.
+func openSmallBadgerDataStore(dir string) (*BadgerStore, func(), error) {
+	opts := badger.DefaultOptions
+	opts.Dir = dir
+	opts.ValueDir = dir
+	opts.MaxTableSize = 1 << 20
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("failed to open badger: %s", err)
+	}
+
+	return NewBadgerStore(db), func() { db.Close() }, nil
 }
@@ -1,18 +1,36 @@
 package boltstore
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
+	"sort"
 
 	"github.com/ahmadmuzakkir/dag/model"
 	"github.com/ahmadmuzakkir/dag/store"
 	"github.com/boltdb/bolt"
 )
 
-var _ store.GraphStore = (*BoltStore)(nil)
+var (
+	_ store.GraphStore = (*BoltStore)(nil)
+	_ store.Viewer     = (*BoltStore)(nil)
+)
+
+// The bucket of the vertex records, keyed by ID, and the bucket of the edge
+// keys of store.ParentKey and store.ChildKey.
+var (
+	graphBucket = []byte("graph")
+	edgesBucket = []byte("edges")
+)
 
+// BoltStore keeps a vertex in a record without its edges, and every edge in
+// keys of its own, so a change of an edge only writes its keys. The records
+// written with their edges, before the edge-per-key layout, are read, and
+// rewritten by Migrate.
 type BoltStore struct {
-	db *bolt.DB
+	db    *bolt.DB
+	codec store.AtomicCodec
+
+	observers model.Observers
 }
 
 func NewBoltStore(db *bolt.DB) *BoltStore {
@@ -21,67 +39,232 @@ func NewBoltStore(db *bolt.DB) *BoltStore {
 	}
 }
 
+// SetCodec sets the codec of the records written from now on. The records
+// of any codec are read. It may be called while the store is used.
+func (b *BoltStore) SetCodec(c store.Codec) {
+	b.codec.Store(c)
+}
+
 func (b *BoltStore) Get() (*model.DAG, error) {
 	raw, err := b.get()
 	if err != nil {
 		return nil, err
 	}
 
-	var m = make(map[string]*model.Vertex)
 	graph := model.NewDAG()
 	for _, v := range raw {
-		vertex := model.NewVertex(v.ID, v.Flag, v.Rank)
-		for _, parent := range v.Parents {
-			vertex.Parents[parent] = struct{}{}
-		}
+		graph.AddVertex(v)
+	}
 
-		for childrenID := range vertex.Children {
-			v.Children = append(v.Children, childrenID)
-		}
+	return graph, nil
+}
 
-		m[v.ID] = vertex
-		graph.AddVertex(vertex)
+func (b *BoltStore) Insert(g *model.DAG) error {
+	if err := b.insert(g.Vertices()); err != nil {
+		return err
 	}
 
-	// for _, v := range raw {
-	// 	for _, c := range v.Parents {
-	// 		graph.AddEdge(m[c], m[v.ID])
-	// 	}
-	// }
+	b.observers.Emit(model.GraphReplaced{Graph: g})
 
-	return graph, nil
+	return nil
 }
 
-func (b *BoltStore) Insert(g *model.DAG) error {
-	// Convert each vertex into the internal representation of vertex.
-	vertices := g.Vertices()
-	var data []*boltVertex
-
-	for _, vertex := range vertices {
-		v := &boltVertex{
-			ID:   vertex.ID,
-			Flag: vertex.Flag,
-			Rank: vertex.Rank,
-			Index: vertex.Index,
+func (b *BoltStore) Walk(fn func(v *model.Vertex) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(graphBucket)
+		if bucket == nil {
+			return nil
 		}
+		edges := tx.Bucket(edgesBucket)
+
+		c := bucket.Cursor()
+		for k, data := c.First(); k != nil; k, data = c.Next() {
+			v, err := b.unmarshal(data)
+			if err != nil {
+				return err
+			}
+			readEdges(edges, v, true)
 
-		for parentID := range vertex.Parents {
-			v.Parents = append(v.Parents, parentID)
+			if err := fn(v); err != nil {
+				return err
+			}
 		}
 
-		for childrenID := range vertex.Children {
-			v.Children = append(v.Children, childrenID)
+		return nil
+	})
+}
+
+// Apply validates the batch against the stored graph and applies it in a single transaction.
+// Only the keys of the changed edges are written.
+func (b *BoltStore) Apply(batch *model.Batch) error {
+	var changes *model.Changes
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(graphBucket)
+		if err != nil {
+			return err
+		}
+		edges, err := tx.CreateBucketIfNotExists(edgesBucket)
+		if err != nil {
+			return err
+		}
+
+		// The vertices read, to write only the keys of the changed edges,
+		// and the records with their edges. The children of a vertex are
+		// only read to delete it.
+		old := make(map[string]*model.Vertex)
+		legacy := make(map[string]bool)
+		changes, err = batch.Stage(model.ParentsGetterFunc(func(id string, children bool) (*model.Vertex, error) {
+			v, isLegacy, err := b.read(tx, id, children)
+			if err == nil {
+				old[id], legacy[id] = v, isLegacy
+			}
+			return v, err
+		}))
+		if err != nil {
+			return err
+		}
+
+		for id, v := range changes.Vertices {
+			if err := b.putChanged(bucket, edges, id, old[id], legacy[id], v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range changes.Events {
+		b.observers.Emit(e)
+	}
+
+	return nil
+}
+
+// put writes the record of the vertex and the keys of all its edges, e.g. a
+// new record or a record written with its edges.
+func (b *BoltStore) put(bucket, edges *bolt.Bucket, v *model.Vertex) error {
+	if err := b.putRecord(bucket, v.ID, v); err != nil {
+		return err
+	}
+
+	return store.DiffEdgeKeys(nil, v, putKey(edges), edges.Delete)
+}
+
+// putChanged writes the record of the vertex with the ID changed by a batch,
+// and the keys of its changed edges, see store.DiffParentKeys. old is the
+// vertex read by the batch, nil for a new vertex, and the vertex is nil if it
+// is deleted. The keys of all the edges of a record with its edges, legacy,
+// are written, as it is rewritten without them.
+func (b *BoltStore) putChanged(bucket, edges *bolt.Bucket, id string, old *model.Vertex, legacy bool, v *model.Vertex) error {
+	if err := b.putRecord(bucket, id, v); err != nil {
+		return err
+	}
+
+	if legacy {
+		if err := store.DiffEdgeKeys(nil, v, putKey(edges), edges.Delete); err != nil {
+			return err
 		}
-		data = append(data, v)
 	}
 
-	return b.insert(data)
+	return store.DiffParentKeys(old, v, putKey(edges), edges.Delete)
+}
+
+// putRecord writes the record of the vertex with the ID, without its edges,
+// or deletes it if the vertex is nil.
+func (b *BoltStore) putRecord(bucket *bolt.Bucket, id string, v *model.Vertex) error {
+	if v == nil {
+		return bucket.Delete([]byte(id))
+	}
+
+	data, err := store.EncodeRecord(b.codec.Load(), store.WithoutEdges(v))
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(id), data)
+}
+
+// putKey returns a function which puts an edge key into the bucket.
+func putKey(edges *bolt.Bucket) func(key []byte) error {
+	return func(key []byte) error {
+		return edges.Put(key, nil)
+	}
+}
+
+func (b *BoltStore) GetVertex(id string) (*model.Vertex, error) {
+	var vertex *model.Vertex
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		vertex, err = b.getByID(tx, id)
+		return err
+	})
+
+	return vertex, err
+}
+
+// View returns a getter of the vertices of a read transaction, which reads
+// the children of a vertex only if they are needed.
+func (b *BoltStore) View() (model.VertexGetter, func(), error) {
+	tx, err := b.db.Begin(false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	get := model.ParentsGetterFunc(func(id string, children bool) (*model.Vertex, error) {
+		v, _, err := b.read(tx, id, children)
+		return v, err
+	})
+
+	return get, func() { tx.Rollback() }, nil
+}
+
+func (b *BoltStore) AddVertex(v *model.Vertex) error {
+	batch := model.NewBatch()
+	batch.AddVertex(v)
+
+	return store.OpError(b.Apply(batch))
+}
+
+func (b *BoltStore) UpdateVertex(v *model.Vertex) error {
+	batch := model.NewBatch()
+	batch.UpdateVertex(v)
+
+	return store.OpError(b.Apply(batch))
+}
+
+func (b *BoltStore) DeleteVertex(id string) error {
+	batch := model.NewBatch()
+	batch.DeleteVertex(id)
+
+	return store.OpError(b.Apply(batch))
+}
+
+func (b *BoltStore) AddEdge(parent, child string) error {
+	batch := model.NewBatch()
+	batch.AddEdge(parent, child)
+
+	return store.OpError(b.Apply(batch))
+}
+
+func (b *BoltStore) DeleteEdge(parent, child string) error {
+	batch := model.NewBatch()
+	batch.DeleteEdge(parent, child)
+
+	return store.OpError(b.Apply(batch))
+}
+
+func (b *BoltStore) Subscribe(o model.Observer) func() {
+	return b.observers.Subscribe(o)
 }
 
 func (b *BoltStore) GetVertexByPosition(position int) (*model.Vertex, error) {
 	var vertex *model.Vertex
 	err := b.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("graph"))
+		bucket := tx.Bucket(graphBucket)
 		if bucket == nil {
 			return fmt.Errorf("bucket does not exist")
 		}
@@ -95,6 +278,7 @@ func (b *BoltStore) GetVertexByPosition(position int) (*model.Vertex, error) {
 				if vertex, err = b.unmarshal(v); err != nil {
 					return err
 				}
+				readEdges(tx.Bucket(edgesBucket), vertex, true)
 			}
 			i++
 		}
@@ -106,65 +290,70 @@ func (b *BoltStore) GetVertexByPosition(position int) (*model.Vertex, error) {
 }
 
 func (b *BoltStore) AncestorsBFS(id string, filter func(*model.Vertex) bool) ([]*model.Vertex, error) {
+	return b.ancestorsBFS(id, filter, true)
+}
+
+// ancestorsBFS returns the ancestors of the vertex, without their children
+// unless children is set, since only their parents are needed to traverse
+// the graph.
+func (b *BoltStore) ancestorsBFS(id string, filter func(*model.Vertex) bool, children bool) ([]*model.Vertex, error) {
 	var list []*model.Vertex
 
 	err := b.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("graph"))
-		if bucket == nil {
-			return fmt.Errorf("bucket does not exist")
+		v, _, err := b.read(tx, id, children)
+		if err != nil {
+			return err
 		}
 
-		v := bucket.Get([]byte(id))
-		if v == nil {
-			return fmt.Errorf("vertex with id %s does not exist", id)
-		}
+		list, err = b.bfs(tx, v, filter, children)
+		return err
+	})
+	return list, err
+}
 
-		q := []string{id}
-		visited := make(map[string]struct{})
-		visited[id] = struct{}{}
+// bfs returns the ancestors of the vertex v, read in the transaction, like
+// ancestorsBFS.
+func (b *BoltStore) bfs(tx *bolt.Tx, v *model.Vertex, filter func(*model.Vertex) bool, children bool) ([]*model.Vertex, error) {
+	var list []*model.Vertex
 
-		for len(q) != 0 {
-			u := q[0]
-			q = q[1:len(q):len(q)]
+	q := []*model.Vertex{v}
+	visited := make(map[string]struct{})
+	visited[v.ID] = struct{}{}
 
-			v, err := b.getByID(bucket, u)
-			if err != nil {
-				return err
-			}
+	for len(q) != 0 {
+		u := q[0]
+		q = q[1:len(q):len(q)]
 
-			for p, _ := range v.Parents {
-				if _, ok := visited[p]; !ok {
-					q = append(q, p)
-					visited[p] = struct{}{}
+		for p, _ := range u.Parents {
+			if _, ok := visited[p]; !ok {
+				visited[p] = struct{}{}
 
-					pv, err := b.getByID(bucket, p)
-					if err != nil {
-						return err
-					}
+				pv, _, err := b.read(tx, p, children)
+				if err != nil {
+					return nil, err
+				}
+				q = append(q, pv)
 
-					if filter == nil || filter(pv) {
-						list = append(list, pv)
-					}
+				if filter == nil || filter(pv) {
+					list = append(list, pv)
 				}
 			}
 		}
+	}
 
-		return nil
-	})
-	return list, err
+	return list, nil
 }
 
 func (b *BoltStore) AncestorsDFS(id string, filter func(*model.Vertex) bool) ([]*model.Vertex, error) {
+	return b.ancestorsDFS(id, filter, true)
+}
+
+// ancestorsDFS returns the ancestors of the vertex, like ancestorsBFS.
+func (b *BoltStore) ancestorsDFS(id string, filter func(*model.Vertex) bool, children bool) ([]*model.Vertex, error) {
 	var list []*model.Vertex
 
 	err := b.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("graph"))
-		if bucket == nil {
-			return fmt.Errorf("bucket does not exist")
-		}
-
-		_, err := b.getByID(bucket, id)
-		if err != nil {
+		if _, _, err := b.read(tx, id, false); err != nil {
 			return err
 		}
 
@@ -178,7 +367,7 @@ func (b *BoltStore) AncestorsDFS(id string, filter func(*model.Vertex) bool) ([]
 			if _, ok := visited[u]; !ok {
 				visited[u] = struct{}{}
 
-				v, err := b.getByID(bucket, u)
+				v, _, err := b.read(tx, u, children)
 				if err != nil {
 					return err
 				}
@@ -205,9 +394,9 @@ func (b *BoltStore) Reach(algo store.Algo, id string) (int, error) {
 	var err error
 
 	if algo == store.ALGO_DFS {
-		list, err = b.AncestorsDFS(id, nil)
+		list, err = b.ancestorsDFS(id, nil, false)
 	} else {
-		list, err = b.AncestorsBFS(id, nil)
+		list, err = b.ancestorsBFS(id, nil, false)
 	}
 
 	if err != nil {
@@ -222,13 +411,13 @@ func (b *BoltStore) ConditionalReach(algo store.Algo, id string, flag bool) (int
 	var err error
 
 	if algo == store.ALGO_DFS {
-		list, err = b.AncestorsDFS(id, func(v *model.Vertex) bool {
+		list, err = b.ancestorsDFS(id, func(v *model.Vertex) bool {
 			return v.Flag == flag
-		})
+		}, false)
 	} else {
-		list, err = b.AncestorsBFS(id, func(v *model.Vertex) bool {
+		list, err = b.ancestorsBFS(id, func(v *model.Vertex) bool {
 			return v.Flag == flag
-		})
+		}, false)
 	}
 
 	if err != nil {
@@ -276,66 +465,158 @@ func (b *BoltStore) ConditionalList(algo store.Algo, id string, flag bool) ([]*m
 	return list, nil
 }
 
-func (b *BoltStore) getByID(bucket *bolt.Bucket, id string) (*model.Vertex, error) {
+// MustPassAncestors reads the vertex and its ancestors in one transaction.
+func (b *BoltStore) MustPassAncestors(id string) ([]*model.Vertex, error) {
+	var vertex *model.Vertex
+	var ancestors []*model.Vertex
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		if vertex, err = b.getByID(tx, id); err != nil {
+			return err
+		}
+
+		ancestors, err = b.bfs(tx, vertex, nil, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return model.MustPassAncestors(vertex, ancestors)
+}
+
+func (b *BoltStore) Stats() (*model.Stats, error) {
+	graph, err := b.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	return graph.Stats()
+}
+
+func (b *BoltStore) getByID(tx *bolt.Tx, id string) (*model.Vertex, error) {
+	v, _, err := b.read(tx, id, true)
+
+	return v, err
+}
+
+// read returns the vertex with its parents, and its children if children is
+// set. legacy reports whether its record has its edges, rather than keys.
+func (b *BoltStore) read(tx *bolt.Tx, id string, children bool) (v *model.Vertex, legacy bool, err error) {
+	bucket := tx.Bucket(graphBucket)
+	if bucket == nil {
+		return nil, false, fmt.Errorf("%w: %s", model.ErrVertexNotFound, id)
+	}
+
 	data := bucket.Get([]byte(id))
 	if data == nil {
-		return nil, fmt.Errorf("vertex with id %s does not exist", id)
+		return nil, false, fmt.Errorf("%w: %s", model.ErrVertexNotFound, id)
+	}
+
+	if v, err = b.unmarshal(data); err != nil {
+		return nil, false, err
+	}
+	if store.HasEdges(v) {
+		return v, true, nil
 	}
 
-	return b.unmarshal(data)
+	readEdges(tx.Bucket(edgesBucket), v, children)
+
+	return v, false, nil
 }
 
-func (b *BoltStore) unmarshal(data []byte) (*model.Vertex, error) {
-	var v boltVertex
-	if err := json.Unmarshal(data, &v); err != nil {
-		return nil, fmt.Errorf("unmarshal error: %s", err)
+// readEdges adds the parents of the vertex, and its children if children is
+// set, from its edge keys.
+func readEdges(edges *bolt.Bucket, v *model.Vertex, children bool) {
+	if edges == nil {
+		return
 	}
 
-	vertex := model.NewVertex(v.ID, v.Flag, v.Rank)
-	for _, parent := range v.Parents {
-		vertex.Parents[parent] = struct{}{}
+	scan(edges, store.ParentsPrefix(v.ID), v.Parents)
+	if children {
+		scan(edges, store.ChildrenPrefix(v.ID), v.Children)
 	}
+}
 
-	for childrenID := range vertex.Children {
-		v.Children = append(v.Children, childrenID)
+// scan adds the IDs of the edge keys with the prefix to ids.
+func scan(edges *bolt.Bucket, prefix []byte, ids map[string]struct{}) {
+	c := edges.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		ids[string(k[len(prefix):])] = struct{}{}
+	}
+}
+
+func (b *BoltStore) unmarshal(data []byte) (*model.Vertex, error) {
+	v, err := store.DecodeRecord(data)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal error: %w", err)
 	}
 
-	return vertex, nil
+	return v, nil
 }
 
-func (b *BoltStore) get() ([]*boltVertex, error) {
-	var list []*boltVertex
+func (b *BoltStore) get() ([]*model.Vertex, error) {
+	var list []*model.Vertex
 
 	err := b.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("graph"))
+		bucket := tx.Bucket(graphBucket)
 		if bucket == nil {
 			return fmt.Errorf("bucket does not exist")
 		}
 
+		vertices := make(map[string]*model.Vertex)
 		c := bucket.Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
-			var vertex boltVertex
-			if err := json.Unmarshal(v, &vertex); err != nil {
+			vertex, err := b.unmarshal(v)
+			if err != nil {
 				return err
 			}
-			list = append(list, &vertex)
+			list = append(list, vertex)
+			vertices[vertex.ID] = vertex
 		}
 
-		return nil
+		edges := tx.Bucket(edgesBucket)
+		if edges == nil {
+			return nil
+		}
+
+		return edges.ForEach(func(k, _ []byte) error {
+			id, other, err := store.ParseEdgeKey(k)
+			if err != nil {
+				return err
+			}
+			v := vertices[id]
+			if v == nil {
+				return fmt.Errorf("edge key of missing vertex %s", id)
+			}
+
+			if bytes.HasPrefix(k, store.ParentsKeyPrefix) {
+				v.Parents[other] = struct{}{}
+			} else {
+				v.Children[other] = struct{}{}
+			}
+
+			return nil
+		})
 	})
 
 	return list, err
 }
 
 // Insert the vertices into the database
-func (b *BoltStore) insert(data []*boltVertex) error {
+func (b *BoltStore) insert(vertices map[string]*model.Vertex) error {
 	// Clear the old data first.
 	err := b.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("graph"))
-		if bucket == nil {
-			return nil
+		for _, name := range [][]byte{graphBucket, edgesBucket} {
+			if tx.Bucket(name) == nil {
+				continue
+			}
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
 		}
-		return tx.DeleteBucket([]byte("graph"))
+		return nil
 	})
 	if err != nil {
 		return err
@@ -343,17 +624,48 @@ func (b *BoltStore) insert(data []*boltVertex) error {
 
 	// insert
 	err = b.db.Update(func(tx *bolt.Tx) error {
-		bucket, err := tx.CreateBucketIfNotExists([]byte("graph"))
+		bucket, err := tx.CreateBucketIfNotExists(graphBucket)
+		if err != nil {
+			return err
+		}
+		edges, err := tx.CreateBucketIfNotExists(edgesBucket)
 		if err != nil {
 			return err
 		}
 
-		for i := range data {
-			b, err := json.Marshal(data[i])
+		// The keys are put in order, which Bolt writes much faster, into
+		// pages filled for appends.
+		bucket.FillPercent = 0.9
+		edges.FillPercent = 0.9
+
+		ids := make([]string, 0, len(vertices))
+		var keys [][]byte
+		for id, v := range vertices {
+			ids = append(ids, id)
+			for p := range v.Parents {
+				keys = append(keys, store.ParentKey(id, p))
+			}
+			for c := range v.Children {
+				keys = append(keys, store.ChildKey(id, c))
+			}
+		}
+		sort.Strings(ids)
+		sort.Slice(keys, func(i, j int) bool {
+			return bytes.Compare(keys[i], keys[j]) < 0
+		})
+
+		codec := b.codec.Load()
+		for _, id := range ids {
+			data, err := store.EncodeRecord(codec, store.WithoutEdges(vertices[id]))
 			if err != nil {
 				return err
 			}
-			if err := bucket.Put([]byte(data[i].ID), b); err != nil {
+			if err := bucket.Put([]byte(id), data); err != nil {
+				return err
+			}
+		}
+		for _, key := range keys {
+			if err := edges.Put(key, nil); err != nil {
 				return err
 			}
 		}
@@ -363,12 +675,76 @@ func (b *BoltStore) insert(data []*boltVertex) error {
 	return err
 }
 
-// The internal representation of the vertex.
-type boltVertex struct {
-	ID       string   `json:"id"`
-	Parents  []string `json:"parents"`
-	Children []string `json:"children"`
-	Flag     bool     `json:"flag"`
-	Rank     int      `json:"rank"`
-	Index    int      `json:"index"`
+// The number of records rewritten by a transaction of Migrate.
+const migrateBatchSize = 10000
+
+// Migrate rewrites in place the records which are not written with the codec
+// of the store at the current schema version, e.g. the JSON records of schema
+// version 0, or which have the edges of the vertex, rather than edge keys,
+// and returns their number. The records are rewritten in batches of
+// transactions, so Migrate can be run again if it fails.
+func (b *BoltStore) Migrate() (int, error) {
+	migrated := 0
+	var after []byte
+
+	for {
+		n := 0
+		err := b.db.Update(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(graphBucket)
+			if bucket == nil {
+				return nil
+			}
+			edges, err := tx.CreateBucketIfNotExists(edgesBucket)
+			if err != nil {
+				return err
+			}
+
+			var vertices []*model.Vertex
+
+			c := bucket.Cursor()
+			k, data := c.First()
+			if after != nil {
+				k, data = c.Seek(after)
+				if bytes.Equal(k, after) {
+					k, data = c.Next()
+				}
+			}
+
+			for ; k != nil && len(vertices) < migrateBatchSize; k, data = c.Next() {
+				after = append(after[:0], k...)
+
+				v, err := b.unmarshal(data)
+				if err != nil {
+					return fmt.Errorf("vertex %s: %w", k, err)
+				}
+				if !store.NeedsMigration(data, b.codec.Load()) && !store.HasEdges(v) {
+					continue
+				}
+				vertices = append(vertices, v)
+			}
+
+			// Write after the iteration, which the writes would invalidate.
+			// The edges of a record do not have keys yet.
+			for _, v := range vertices {
+				if err := b.put(bucket, edges, v); err != nil {
+					return err
+				}
+			}
+
+			n = len(vertices)
+			if k == nil {
+				after = nil
+			}
+
+			return nil
+		})
+		if err != nil {
+			return migrated, err
+		}
+
+		migrated += n
+		if after == nil {
+			return migrated, nil
+		}
+	}
 }
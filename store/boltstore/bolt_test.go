@@ -3,28 +3,20 @@ package boltstore
 import (
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
 
-	"github.com/ahmadmuzakkir/dag/model"
 	"github.com/ahmadmuzakkir/dag/store"
+	"github.com/ahmadmuzakkir/dag/store/storetest"
 	"github.com/boltdb/bolt"
 )
 
 const (
-	testBoltPath = "/tmp/bolt/graph_test.db"
-
-	testGraphSize = 100000
+	testBoltPath         = "/tmp/bolt/graph_test.db"
+	testBoltTopologyPath = "/tmp/bolt/graph_test_topology.db"
 )
 
-// Run the tests for both BFS and DFS
-var tesalgos = []testalgo{
-	testalgo{name: "BFS", algo: store.ALGO_BFS},
-	testalgo{name: "DFS", algo: store.ALGO_DFS},
-}
-
 // Initiate the database and insert a new graph.
 func init() {
 	ds, teardown, err := getBoltDataStore()
@@ -33,152 +25,132 @@ func init() {
 		log.Fatal(err)
 	}
 
-	graph := model.GenerateGraph(testGraphSize)
-
-	err = ds.Insert(graph)
-	if err != nil {
+	if err := storetest.InsertBenchGraph(ds); err != nil {
 		log.Fatal(err)
 	}
 }
 
 func TestDAG(t *testing.T) {
-	edges := testGraphSize - 1
-
 	ds, teardown, err := getBoltDataStore()
 	defer teardown()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	graph, err := ds.Get()
-	if err != nil {
-		t.Fatalf("failed to get graph: %s", err)
-	}
+	storetest.CheckBenchGraph(t, ds)
+}
 
-	foundSize := graph.CountVertex()
-	if foundSize != testGraphSize {
-		t.Fatalf("expected vertices count %d, found %d", testGraphSize, foundSize)
-	}
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.GraphStore {
+		ds, teardown, err := openBoltDataStore(filepath.Join(t.TempDir(), "graph.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(teardown)
 
-	foundEdges := graph.CountEdge()
-	if foundEdges != edges {
-		t.Fatalf("expected edges count %d, found %d", edges, foundEdges)
-	}
+		return ds
+	})
 }
 
-func BenchmarkReach(t *testing.B) {
-	ds, teardown, err := getBoltDataStore()
+func TestMigrate(t *testing.T) {
+	ds, teardown, err := openBoltDataStore(testBoltTopologyPath)
 	defer teardown()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Choose a random vertex
-	v, err := ds.GetVertexByPosition(rand.Intn(testGraphSize))
-	if err != nil {
-		t.Fatal(err)
-	}
+	storetest.RunMigrate(t, ds, rawRecords{ds}, migrateBatchSize+500)
+}
+
+// rawRecords are the records of the graph bucket.
+type rawRecords struct {
+	ds *BoltStore
+}
 
-	t.ResetTimer()
+func (r rawRecords) Put(records map[string][]byte) error {
+	return r.ds.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("graph"))
+		if err != nil {
+			return err
+		}
 
-	for _, algo := range tesalgos {
-		t.Run(algo.name, func(b *testing.B) {
-			for n := 0; n < b.N; n++ {
-				if _, err := ds.Reach(algo.algo, v.ID); err != nil {
-					t.Fatal(err)
-				}
+		for id, data := range records {
+			if err := bucket.Put([]byte(id), data); err != nil {
+				return err
 			}
+		}
+
+		return nil
+	})
+}
+
+func (r rawRecords) Walk(fn func(id string, data []byte) error) error {
+	return r.ds.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("graph")).ForEach(func(k, data []byte) error {
+			return fn(string(k), data)
 		})
-	}
+	})
 }
 
-func BenchmarkConditionalReach(t *testing.B) {
-	ds, teardown, err := getBoltDataStore()
-	defer teardown()
-	if err != nil {
-		t.Fatal(err)
-	}
+func BenchmarkReach(b *testing.B) {
+	benchmarkQuery(b, storetest.Reach)
+}
 
-	// Choose a random vertex
-	v, err := ds.GetVertexByPosition(rand.Intn(testGraphSize))
-	if err != nil {
-		t.Fatal(err)
-	}
+func BenchmarkConditionalReach(b *testing.B) {
+	benchmarkQuery(b, storetest.ConditionalReach)
+}
 
-	t.ResetTimer()
+func BenchmarkList(b *testing.B) {
+	benchmarkQuery(b, storetest.List)
+}
 
-	for _, algo := range tesalgos {
-		t.Run(algo.name, func(b *testing.B) {
-			for n := 0; n < b.N; n++ {
-				if _, err := ds.ConditionalReach(algo.algo, v.ID, true); err != nil {
-					t.Fatal(err)
-				}
-			}
-		})
-	}
+func BenchmarkConditionalList(b *testing.B) {
+	benchmarkQuery(b, storetest.ConditionalList)
 }
 
-func BenchmarkList(t *testing.B) {
-	ds, teardown, err := getBoltDataStore()
+func BenchmarkTopologies(b *testing.B) {
+	ds, teardown, err := openBoltDataStore(testBoltTopologyPath)
 	defer teardown()
 	if err != nil {
-		t.Fatal(err)
+		b.Fatal(err)
 	}
 
-	// Choose a random vertex
-	v, err := ds.GetVertexByPosition(rand.Intn(testGraphSize))
+	storetest.BenchmarkTopologies(b, ds)
+}
+
+func BenchmarkAddEdge(b *testing.B) {
+	ds, teardown, err := openBoltDataStore(testBoltTopologyPath)
+	defer teardown()
 	if err != nil {
-		t.Fatal(err)
+		b.Fatal(err)
 	}
 
-	t.ResetTimer()
-
-	for _, algo := range tesalgos {
-		t.Run(algo.name, func(b *testing.B) {
-			for n := 0; n < b.N; n++ {
-				if _, err := ds.List(algo.algo, v.ID); err != nil {
-					t.Fatal(err)
-				}
-			}
-		})
-	}
+	storetest.BenchmarkAddEdge(b, ds)
 }
 
-func BenchmarkConditionalList(t *testing.B) {
+func benchmarkQuery(b *testing.B, query storetest.Query) {
 	ds, teardown, err := getBoltDataStore()
 	defer teardown()
 	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Choose a random vertex
-	v, err := ds.GetVertexByPosition(rand.Intn(testGraphSize))
-	if err != nil {
-		t.Fatal(err)
+		b.Fatal(err)
 	}
 
-	t.ResetTimer()
-
-	for _, algo := range tesalgos {
-		t.Run(algo.name, func(b *testing.B) {
-			for n := 0; n < b.N; n++ {
-				if _, err := ds.ConditionalList(algo.algo, v.ID, true); err != nil {
-					t.Fatal(err)
-				}
-			}
-		})
-	}
+	storetest.BenchmarkQuery(b, ds, query)
 }
 
 func getBoltDataStore() (*BoltStore, func(), error) {
+	return openBoltDataStore(testBoltPath)
+}
+
+func openBoltDataStore(path string) (*BoltStore, func(), error) {
 	// Create directory if it does not exist
-	if _, err := os.Stat(testBoltPath); os.IsNotExist(err) {
-		if err2 := os.MkdirAll(filepath.Dir(testBoltPath), os.ModePerm); err2 != nil {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err2 := os.MkdirAll(filepath.Dir(path), os.ModePerm); err2 != nil {
 			return nil, func() {}, fmt.Errorf("failed to create bolt directory: %s", err)
 		}
 	}
 
-	db, err := bolt.Open(testBoltPath, 0600, nil)
+	db, err := bolt.Open(path, 0600, nil)
 	if err != nil {
 		return nil, func() {}, fmt.Errorf("failed to open bolt: %s", err)
 	}
@@ -190,8 +162,3 @@ func getBoltDataStore() (*BoltStore, func(), error) {
 
 	return ds, teardown, nil
 }
-
-type testalgo struct {
-	name string
-	algo store.Algo
-}
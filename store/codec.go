@@ -0,0 +1,602 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+
+	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/golang/protobuf/proto"
+)
+
+// The stores keep every vertex in a record: a header of 3 bytes, recordMagic,
+// the schema version and the ID of the codec, followed by the vertex encoded
+// by the codec. The records written before the header are JSON objects,
+// which are read as schema version 0.
+const (
+	recordMagic      = 0xDA
+	recordHeaderSize = 3
+)
+
+// SchemaVersion is the version of the records written by the stores.
+const SchemaVersion = 1
+
+var (
+	// ErrUnknownCodec is returned for a record written by a codec which is
+	// not known.
+	ErrUnknownCodec = errors.New("unknown codec")
+
+	// ErrInvalidRecord is returned for a record which cannot be decoded.
+	ErrInvalidRecord = errors.New("invalid record")
+)
+
+// Codec encodes the vertices into the records of the stores.
+type Codec interface {
+	// ID identifies the codec in the header of the records.
+	ID() byte
+
+	Name() string
+
+	Marshal(v *model.Vertex) ([]byte, error)
+	Unmarshal(data []byte) (*model.Vertex, error)
+}
+
+var (
+	// JSONCodec encodes the vertices as JSON objects, like the records of
+	// schema version 0.
+	JSONCodec Codec = jsonCodec{}
+
+	// ProtobufCodec encodes the vertices as Protocol Buffers messages.
+	ProtobufCodec Codec = protobufCodec{}
+
+	// VarintCodec encodes the vertices in a compact binary format, with the
+	// hexadecimal IDs packed into bytes.
+	VarintCodec Codec = varintCodec{}
+
+	// DefaultCodec is the codec of the new stores.
+	DefaultCodec = VarintCodec
+)
+
+// Codecs returns the known codecs.
+func Codecs() []Codec {
+	return []Codec{JSONCodec, ProtobufCodec, VarintCodec}
+}
+
+// CodecByName returns the known codec with the name.
+func CodecByName(name string) (Codec, error) {
+	for _, c := range Codecs() {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, name)
+}
+
+func codecByID(id byte) (Codec, error) {
+	for _, c := range Codecs() {
+		if c.ID() == id {
+			return c, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: ID %d", ErrUnknownCodec, id)
+}
+
+// Migrator is implemented by the stores which keep their vertices in records.
+type Migrator interface {
+	// SetCodec sets the codec of the records written from now on. It may be
+	// called while the store is used.
+	SetCodec(c Codec)
+
+	// Migrate rewrites in place the records which are not written with the
+	// codec of the store at SchemaVersion, or which still have the edges of
+	// their vertex rather than edge keys, and returns their number.
+	Migrate() (int, error)
+}
+
+// AtomicCodec holds the codec of the records written by a store, which may be
+// set while the store is used. The zero value holds DefaultCodec.
+type AtomicCodec struct {
+	v atomic.Value
+}
+
+// codecValue wraps the codecs, since an atomic.Value only holds values of a
+// single type.
+type codecValue struct {
+	codec Codec
+}
+
+func (a *AtomicCodec) Load() Codec {
+	if v, ok := a.v.Load().(codecValue); ok {
+		return v.codec
+	}
+
+	return DefaultCodec
+}
+
+func (a *AtomicCodec) Store(c Codec) {
+	a.v.Store(codecValue{c})
+}
+
+// EncodeRecord encodes the vertex with the codec into a record.
+func EncodeRecord(c Codec, v *model.Vertex) ([]byte, error) {
+	body, err := c.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, recordHeaderSize+len(body))
+	data[0] = recordMagic
+	data[1] = SchemaVersion
+	data[2] = c.ID()
+	copy(data[recordHeaderSize:], body)
+
+	return data, nil
+}
+
+// DecodeRecord decodes a record of any schema version and codec.
+func DecodeRecord(data []byte) (*model.Vertex, error) {
+	version, c, err := RecordHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	body := data
+	if version != 0 {
+		body = data[recordHeaderSize:]
+	}
+
+	v, err := c.Unmarshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s codec: %v", ErrInvalidRecord, c.Name(), err)
+	}
+
+	return v, nil
+}
+
+// RecordHeader returns the schema version and the codec of a record.
+func RecordHeader(data []byte) (int, Codec, error) {
+	if len(data) != 0 && data[0] == '{' {
+		return 0, JSONCodec, nil
+	}
+
+	if len(data) < recordHeaderSize || data[0] != recordMagic {
+		return 0, nil, fmt.Errorf("%w: missing header", ErrInvalidRecord)
+	}
+
+	if data[1] == 0 || data[1] > SchemaVersion {
+		return 0, nil, fmt.Errorf("%w: unknown schema version %d", ErrInvalidRecord, data[1])
+	}
+
+	c, err := codecByID(data[2])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return int(data[1]), c, nil
+}
+
+// NeedsMigration reports whether the record is not written with the codec at
+// SchemaVersion.
+func NeedsMigration(data []byte, c Codec) bool {
+	version, current, err := RecordHeader(data)
+
+	return err != nil || version != SchemaVersion || current.ID() != c.ID()
+}
+
+func sortedIDs(ids map[string]struct{}) []string {
+	list := make([]string, 0, len(ids))
+	for id := range ids {
+		list = append(list, id)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// jsonCodec encodes the vertices like the records of schema version 0.
+type jsonCodec struct{}
+
+type jsonVertex struct {
+	ID       string   `json:"id"`
+	Parents  []string `json:"parents"`
+	Children []string `json:"children"`
+	Flag     bool     `json:"flag"`
+	Rank     int      `json:"rank"`
+	Index    int      `json:"index"`
+
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+func (jsonCodec) ID() byte     { return 1 }
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(v *model.Vertex) ([]byte, error) {
+	return json.Marshal(&jsonVertex{
+		ID:         v.ID,
+		Parents:    sortedIDs(v.Parents),
+		Children:   sortedIDs(v.Children),
+		Flag:       v.Flag,
+		Rank:       v.Rank,
+		Index:      v.Index,
+		Properties: v.Properties,
+	})
+}
+
+func (jsonCodec) Unmarshal(data []byte) (*model.Vertex, error) {
+	var jv jsonVertex
+	if err := json.Unmarshal(data, &jv); err != nil {
+		return nil, err
+	}
+
+	v := newVertex(jv.ID, jv.Flag, jv.Rank, jv.Index, jv.Properties)
+	addIDs(v.Parents, jv.Parents)
+	addIDs(v.Children, jv.Children)
+
+	return v, nil
+}
+
+func newVertex(id string, flag bool, rank, index int, properties map[string]string) *model.Vertex {
+	v := model.NewVertex(id, flag, rank)
+	v.Index = index
+	if len(properties) != 0 {
+		v.Properties = properties
+	}
+
+	return v
+}
+
+func addIDs(set map[string]struct{}, ids []string) {
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+}
+
+// protobufCodec encodes the vertices as the message:
+//
+//	message Vertex {
+//	  string id = 1;
+//	  repeated string parents = 2;
+//	  repeated string children = 3;
+//	  bool flag = 4;
+//	  sint64 rank = 5;
+//	  sint64 index = 6;
+//	  map<string, string> properties = 7;
+//	}
+type protobufCodec struct{}
+
+type protobufVertex struct {
+	Id         string            `protobuf:"bytes,1,opt,name=id,proto3"`
+	Parents    []string          `protobuf:"bytes,2,rep,name=parents,proto3"`
+	Children   []string          `protobuf:"bytes,3,rep,name=children,proto3"`
+	Flag       bool              `protobuf:"varint,4,opt,name=flag,proto3"`
+	Rank       int64             `protobuf:"zigzag64,5,opt,name=rank,proto3"`
+	Index      int64             `protobuf:"zigzag64,6,opt,name=index,proto3"`
+	Properties map[string]string `protobuf:"bytes,7,rep,name=properties,proto3" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *protobufVertex) Reset()         { *m = protobufVertex{} }
+func (m *protobufVertex) String() string { return proto.CompactTextString(m) }
+func (*protobufVertex) ProtoMessage()    {}
+
+func (protobufCodec) ID() byte     { return 2 }
+func (protobufCodec) Name() string { return "protobuf" }
+
+func (protobufCodec) Marshal(v *model.Vertex) ([]byte, error) {
+	return proto.Marshal(&protobufVertex{
+		Id:         v.ID,
+		Parents:    sortedIDs(v.Parents),
+		Children:   sortedIDs(v.Children),
+		Flag:       v.Flag,
+		Rank:       int64(v.Rank),
+		Index:      int64(v.Index),
+		Properties: v.Properties,
+	})
+}
+
+func (protobufCodec) Unmarshal(data []byte) (*model.Vertex, error) {
+	var pv protobufVertex
+	if err := proto.Unmarshal(data, &pv); err != nil {
+		return nil, err
+	}
+
+	v := newVertex(pv.Id, pv.Flag, int(pv.Rank), int(pv.Index), pv.Properties)
+	addIDs(v.Parents, pv.Parents)
+	addIDs(v.Children, pv.Children)
+
+	return v, nil
+}
+
+// varintCodec encodes the vertices as:
+//
+//	id, flag byte, rank varint, index varint,
+//	parent count uvarint, parent ids, child count uvarint, child ids,
+//	property count uvarint, property names and values
+//
+// The names and values of the properties are strings: a uvarint length
+// followed by the bytes. The IDs are a uvarint of the length shifted left by
+// 2 bits, with the low bits for the case of a hexadecimal ID packed into
+// bytes, followed by the bytes.
+type varintCodec struct{}
+
+const (
+	varintRawID = iota
+	varintUpperHexID
+	varintLowerHexID
+)
+
+func (varintCodec) ID() byte     { return 3 }
+func (varintCodec) Name() string { return "varint" }
+
+func (varintCodec) Marshal(v *model.Vertex) ([]byte, error) {
+	size := 2*binary.MaxVarintLen64 + 1 + len(v.ID)
+	for id := range v.Parents {
+		size += binary.MaxVarintLen32 + len(id)
+	}
+	for id := range v.Children {
+		size += binary.MaxVarintLen32 + len(id)
+	}
+	for k, p := range v.Properties {
+		size += 2*binary.MaxVarintLen32 + len(k) + len(p)
+	}
+
+	e := varintEncoder{buf: make([]byte, 0, size+3*binary.MaxVarintLen32)}
+	e.id(v.ID)
+
+	var flag byte
+	if v.Flag {
+		flag = 1
+	}
+	e.buf = append(e.buf, flag)
+	e.varint(int64(v.Rank))
+	e.varint(int64(v.Index))
+
+	for _, ids := range []map[string]struct{}{v.Parents, v.Children} {
+		e.uvarint(uint64(len(ids)))
+		for _, id := range sortedIDs(ids) {
+			e.id(id)
+		}
+	}
+
+	keys := make([]string, 0, len(v.Properties))
+	for k := range v.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	e.uvarint(uint64(len(keys)))
+	for _, k := range keys {
+		e.string(k)
+		e.string(v.Properties[k])
+	}
+
+	return e.buf, nil
+}
+
+func (varintCodec) Unmarshal(data []byte) (*model.Vertex, error) {
+	d := varintDecoder{buf: data}
+
+	id := d.id()
+	flag := d.byte()
+	rank := d.varint()
+	index := d.varint()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if flag > 1 {
+		return nil, fmt.Errorf("invalid flag %d", flag)
+	}
+
+	v := &model.Vertex{
+		ID:       id,
+		Index:    int(index),
+		Flag:     flag == 1,
+		Rank:     int(rank),
+		Parents:  d.ids(),
+		Children: d.ids(),
+	}
+
+	if n := d.count(); n != 0 {
+		v.Properties = make(map[string]string, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			k := d.string()
+			v.Properties[k] = d.string()
+		}
+	}
+
+	if d.err == nil && len(d.buf) != 0 {
+		d.err = fmt.Errorf("%d trailing bytes", len(d.buf))
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	return v, nil
+}
+
+type varintEncoder struct {
+	buf     []byte
+	scratch [binary.MaxVarintLen64]byte
+}
+
+func (e *varintEncoder) uvarint(x uint64) {
+	n := binary.PutUvarint(e.scratch[:], x)
+	e.buf = append(e.buf, e.scratch[:n]...)
+}
+
+func (e *varintEncoder) varint(x int64) {
+	n := binary.PutVarint(e.scratch[:], x)
+	e.buf = append(e.buf, e.scratch[:n]...)
+}
+
+func (e *varintEncoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *varintEncoder) id(id string) {
+	kind := hexIDKind(id)
+	if kind == varintRawID {
+		e.uvarint(uint64(len(id)) << 2)
+		e.buf = append(e.buf, id...)
+		return
+	}
+
+	e.uvarint(uint64(len(id)/2)<<2 | uint64(kind))
+	n := len(e.buf)
+	e.buf = append(e.buf, make([]byte, len(id)/2)...)
+	hex.Decode(e.buf[n:], []byte(id))
+}
+
+// hexIDKind returns how the ID is packed: into bytes if it is made of an even
+// number of hexadecimal digits of a single case.
+func hexIDKind(id string) int {
+	if len(id) == 0 || len(id)%2 != 0 {
+		return varintRawID
+	}
+
+	upper, lower := false, false
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case c >= '0' && c <= '9':
+		case c >= 'A' && c <= 'F':
+			upper = true
+		case c >= 'a' && c <= 'f':
+			lower = true
+		default:
+			return varintRawID
+		}
+	}
+
+	switch {
+	case upper && lower:
+		return varintRawID
+	case lower:
+		return varintLowerHexID
+	default:
+		return varintUpperHexID
+	}
+}
+
+type varintDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *varintDecoder) fail(what string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("truncated %s", what)
+	}
+	d.buf = nil
+}
+
+func (d *varintDecoder) byte() byte {
+	if len(d.buf) == 0 {
+		d.fail("flag")
+		return 0
+	}
+
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+
+	return b
+}
+
+func (d *varintDecoder) uvarint() uint64 {
+	x, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail("varint")
+		return 0
+	}
+	d.buf = d.buf[n:]
+
+	return x
+}
+
+func (d *varintDecoder) varint() int64 {
+	x, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail("varint")
+		return 0
+	}
+	d.buf = d.buf[n:]
+
+	return x
+}
+
+// count reads a number of elements, each of at least one byte.
+func (d *varintDecoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.fail("list")
+		return 0
+	}
+
+	return int(n)
+}
+
+func (d *varintDecoder) bytes(n uint64) []byte {
+	if n > uint64(len(d.buf)) {
+		d.fail("string")
+		return nil
+	}
+
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+
+	return b
+}
+
+func (d *varintDecoder) string() string {
+	return string(d.bytes(d.uvarint()))
+}
+
+func (d *varintDecoder) id() string {
+	header := d.uvarint()
+	b := d.bytes(header >> 2)
+
+	switch header & 3 {
+	case varintRawID:
+		return string(b)
+	case varintUpperHexID:
+		return hexString(b, "0123456789ABCDEF")
+	case varintLowerHexID:
+		return hexString(b, "0123456789abcdef")
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("invalid ID kind %d", header&3)
+		}
+		return ""
+	}
+}
+
+func (d *varintDecoder) ids() map[string]struct{} {
+	n := d.count()
+
+	ids := make(map[string]struct{}, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		ids[d.id()] = struct{}{}
+	}
+
+	return ids
+}
+
+// hexString formats the bytes in hexadecimal with the digits.
+func hexString(b []byte, digits string) string {
+	var scratch [128]byte
+	buf := scratch[:0]
+	if 2*len(b) > len(scratch) {
+		buf = make([]byte, 0, 2*len(b))
+	}
+
+	for _, c := range b {
+		buf = append(buf, digits[c>>4], digits[c&0xf])
+	}
+
+	return string(buf)
+}
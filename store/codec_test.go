@@ -0,0 +1,196 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ahmadmuzakkir/dag/model"
+)
+
+func TestCodecs(t *testing.T) {
+	graph := model.GenerateGraph(200)
+
+	v := model.NewVertex("raw id", true, -3)
+	v.Index = 7
+	v.Properties = map[string]string{"name": "a\x00b", "": "empty"}
+	v.Parents["abcdef"] = struct{}{}
+	v.Parents["ABCDEF"] = struct{}{}
+	v.Parents["AbCdEf"] = struct{}{}
+	v.Parents["123"] = struct{}{}
+	v.Children["0099"] = struct{}{}
+	v.Children[""] = struct{}{}
+	graph.AddVertex(v)
+
+	for _, c := range Codecs() {
+		t.Run(c.Name(), func(t *testing.T) {
+			size := 0
+			for _, expected := range graph.Vertices() {
+				data, err := EncodeRecord(c, expected)
+				if err != nil {
+					t.Fatal(err)
+				}
+				size += len(data)
+
+				found, err := DecodeRecord(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(found, expected) {
+					t.Fatalf("expected %+v, found %+v", expected, found)
+				}
+
+				if NeedsMigration(data, c) {
+					t.Fatal("expected the record not to need a migration")
+				}
+			}
+
+			t.Logf("%d bytes", size)
+		})
+	}
+}
+
+func TestCodecSizes(t *testing.T) {
+	v := model.NewVertex(model.HexID(1, 1), false, 3)
+	v.Parents[model.HexID(1, 2)] = struct{}{}
+	v.Children[model.HexID(1, 3)] = struct{}{}
+
+	sizes := make(map[string]int)
+	for _, c := range Codecs() {
+		data, err := EncodeRecord(c, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes[c.Name()] = len(data)
+	}
+
+	if sizes["protobuf"] >= sizes["json"] || sizes["varint"] >= sizes["protobuf"] {
+		t.Fatalf("unexpected sizes %v", sizes)
+	}
+	// The 3 IDs of 64 hexadecimal characters are packed into 32 bytes, after
+	// a header of 2 bytes.
+	if sizes["varint"] != 3+3*34+6 {
+		t.Fatalf("unexpected varint size %d", sizes["varint"])
+	}
+}
+
+func TestLegacyRecord(t *testing.T) {
+	data := []byte(`{"id":"b","parents":["a"],"children":null,"flag":true,"rank":1,"index":2,"properties":{"name":"B"}}`)
+
+	version, c, err := RecordHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 0 || c != JSONCodec {
+		t.Fatalf("expected schema version 0 of the JSON codec, found %d of %s", version, c.Name())
+	}
+
+	v, err := DecodeRecord(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := model.NewVertex("b", true, 1)
+	expected.Index = 2
+	expected.Parents["a"] = struct{}{}
+	expected.Properties = map[string]string{"name": "B"}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %+v, found %+v", expected, v)
+	}
+
+	if !NeedsMigration(data, JSONCodec) {
+		t.Fatal("expected a legacy record to need a migration")
+	}
+}
+
+func TestRecordErrors(t *testing.T) {
+	v := model.NewVertex("a", false, 0)
+	v.Parents["b"] = struct{}{}
+	v.Properties = map[string]string{"name": "A"}
+
+	valid, err := EncodeRecord(VarintCodec, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+		msg  string
+	}{
+		{"empty", nil, ErrInvalidRecord, "missing header"},
+		{"magic", []byte{1, 1, 3, 0}, ErrInvalidRecord, "missing header"},
+		{"version", []byte{recordMagic, SchemaVersion + 1, 3}, ErrInvalidRecord, "unknown schema version"},
+		{"codec", []byte{recordMagic, SchemaVersion, 42}, ErrUnknownCodec, "ID 42"},
+		{"truncated", valid[:len(valid)-1], ErrInvalidRecord, "truncated"},
+		{"trailing", append(append([]byte(nil), valid...), 0), ErrInvalidRecord, "trailing"},
+		{"json", []byte(`{"id":`), ErrInvalidRecord, "json codec"},
+		{"protobuf", []byte{recordMagic, SchemaVersion, 2, 0xff}, ErrInvalidRecord, "protobuf codec"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := DecodeRecord(test.data)
+			if !errors.Is(err, test.err) || !strings.Contains(err.Error(), test.msg) {
+				t.Fatalf("expected %v with %q, found %v", test.err, test.msg, err)
+			}
+
+			if !NeedsMigration(test.data, VarintCodec) && test.name != "truncated" && test.name != "trailing" {
+				t.Fatal("expected an invalid record to need a migration")
+			}
+		})
+	}
+
+	if _, err := CodecByName("xml"); !errors.Is(err, ErrUnknownCodec) {
+		t.Fatalf("expected ErrUnknownCodec, found %v", err)
+	}
+}
+
+func BenchmarkDecodeRecord(b *testing.B) {
+	v := model.NewVertex(model.HexID(1, 1), false, 3)
+	for i := 0; i < 4; i++ {
+		v.Parents[model.HexID(2, i)] = struct{}{}
+		v.Children[model.HexID(3, i)] = struct{}{}
+	}
+
+	for _, c := range Codecs() {
+		data, err := EncodeRecord(c, v)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(c.Name(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := DecodeRecord(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestAtomicCodec(t *testing.T) {
+	var codec AtomicCodec
+	if codec.Load() != DefaultCodec {
+		t.Fatalf("expected the default codec, found %s", codec.Load().Name())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, c := range []Codec{JSONCodec, ProtobufCodec, VarintCodec} {
+			codec.Store(c)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		codec.Load()
+	}
+	<-done
+
+	if codec.Load() != VarintCodec {
+		t.Fatalf("expected the varint codec, found %s", codec.Load().Name())
+	}
+}
@@ -0,0 +1,550 @@
+package store
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ahmadmuzakkir/dag/model"
+)
+
+// CSVOptions configures ImportCSV and ExportCSV.
+type CSVOptions struct {
+	// Comma is the field separator, ',' by default, or '\t' for TSV. If it is
+	// ' ', the fields are separated by any run of spaces and tabs, as in edge
+	// lists, and cannot be quoted.
+	Comma rune
+
+	// Header is set if the first row of the edge file is a header. The vertex
+	// file always has a header.
+	Header bool
+
+	// Number of mutations per batch. Defaults to 10000.
+	BatchSize int
+}
+
+func (o *CSVOptions) comma() rune {
+	if o.Comma == 0 {
+		return ','
+	}
+
+	return o.Comma
+}
+
+// RejectedRow is a row which was not imported.
+type RejectedRow struct {
+	// File is "vertices" or "edges".
+	File   string
+	Line   int
+	Record []string
+	Reason string
+}
+
+func (r RejectedRow) String() string {
+	return fmt.Sprintf("%s:%d: %s: %q", r.File, r.Line, r.Reason, r.Record)
+}
+
+// ImportReport is the result of ImportCSV.
+type ImportReport struct {
+	Vertices int
+	Edges    int
+	Rejected []RejectedRow
+}
+
+// ImportCSV loads a graph into the store, replacing the existing graph.
+//
+// The edge file has two columns, parent and child. The vertex file, which may
+// be nil, has a header with the columns id, flag, rank and index, of which
+// only id is required; the other columns are stored as properties, unless
+// they are empty. Without a vertex file, the vertices are created from the
+// edges.
+//
+// The rows are written in batches. The invalid rows, the edges to unknown
+// vertices and the edges which would create a cycle are rejected and listed
+// in the report, the others are imported. An error is returned only if a file
+// cannot be read or the store fails.
+//
+// The rows of a batch are validated against a single view of the store if it
+// is a Viewer. The IDs of the imported vertices are kept in memory, to find
+// the dangling references without reading the store, so the import needs
+// memory in proportion to the number and length of the IDs.
+func ImportCSV(ds GraphStore, vertices, edges io.Reader, opts CSVOptions) (*ImportReport, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 10000
+	}
+
+	if err := ds.Insert(model.NewDAG()); err != nil {
+		return nil, err
+	}
+
+	l := &csvLoader{
+		ds:     ds,
+		size:   opts.BatchSize,
+		seen:   make(map[string]struct{}),
+		report: &ImportReport{},
+	}
+	if err := l.newStager(); err != nil {
+		return nil, err
+	}
+	defer func() { l.release() }()
+
+	if vertices != nil {
+		if err := l.loadVertices(newRowReader(vertices, opts.comma())); err != nil {
+			return nil, err
+		}
+	}
+
+	rows := newRowReader(edges, opts.comma())
+	if opts.Header {
+		// A malformed header is rejected like the other rows.
+		_, _, err := rows.next()
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			l.reject(RejectedRow{File: "edges", Line: parseErr.Line, Reason: parseErr.Err.Error()})
+		} else if err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+
+	if err := l.loadEdges(rows, vertices == nil); err != nil {
+		return nil, err
+	}
+
+	return l.report, nil
+}
+
+type csvLoader struct {
+	ds   GraphStore
+	size int
+
+	// The IDs of the staged vertices, to find the dangling references before
+	// staging the edges.
+	seen map[string]struct{}
+
+	// The valid mutations of the pending batch. Every row is validated as it
+	// is read, so the rejected rows are in order and a batch is applied once.
+	// The stager reads the view of the store, which is released before the
+	// batch is applied.
+	stager  *model.Stager
+	release func()
+	report  *ImportReport
+
+	// The failure of the store while validating a row, which is not a
+	// failure of the row.
+	err error
+}
+
+// newStager starts a batch, which reads a view of the store if it is a
+// Viewer, or else the store.
+func (l *csvLoader) newStager() error {
+	var src model.VertexGetter = l.ds
+	l.release = func() {}
+	if viewer, ok := l.ds.(Viewer); ok {
+		view, release, err := viewer.View()
+		if err != nil {
+			return err
+		}
+		src, l.release = view, release
+	}
+
+	record := func(v *model.Vertex, err error) (*model.Vertex, error) {
+		if err != nil && !errors.Is(err, model.ErrVertexNotFound) {
+			l.err = err
+		}
+		return v, err
+	}
+
+	// The stager reads the vertices of a ParentsGetter without their
+	// children.
+	if parents, ok := src.(model.ParentsGetter); ok {
+		l.stager = model.NewStager(model.ParentsGetterFunc(func(id string, children bool) (*model.Vertex, error) {
+			if children {
+				return record(parents.GetVertex(id))
+			}
+			return record(parents.GetVertexParents(id))
+		}))
+		return nil
+	}
+
+	l.stager = model.NewStager(model.VertexGetterFunc(func(id string) (*model.Vertex, error) {
+		return record(src.GetVertex(id))
+	}))
+
+	return nil
+}
+
+func (l *csvLoader) loadVertices(rows rowReader) error {
+	header, _, err := rows.next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	column := make(map[string]int, len(header))
+	for i, name := range header {
+		column[strings.TrimSpace(name)] = i
+	}
+	if _, ok := column["id"]; !ok {
+		return fmt.Errorf("vertices: missing id column in header %q", header)
+	}
+
+	for {
+		record, line, err := rows.next()
+		if err == io.EOF {
+			break
+		}
+
+		row := RejectedRow{File: "vertices", Line: line, Record: record}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			row.Line, row.Reason = parseErr.Line, parseErr.Err.Error()
+			l.reject(row)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		v, err := csvVertex(header, column, record)
+		if err != nil {
+			row.Reason = err.Error()
+			l.reject(row)
+			continue
+		}
+
+		if _, ok := l.seen[v.ID]; ok {
+			row.Reason = "duplicate vertex " + v.ID
+			l.reject(row)
+			continue
+		}
+
+		if _, err := l.add(model.Op{Type: model.OpAddVertex, Vertex: v}, row); err != nil {
+			return err
+		}
+	}
+
+	return l.flush()
+}
+
+func csvVertex(header []string, column map[string]int, record []string) (*model.Vertex, error) {
+	if len(record) != len(header) {
+		return nil, fmt.Errorf("expected %d fields, found %d", len(header), len(record))
+	}
+
+	v := model.NewVertex(strings.TrimSpace(record[column["id"]]), false, 0)
+	if v.ID == "" {
+		return nil, fmt.Errorf("missing id")
+	}
+
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		value := record[i]
+		var err error
+
+		switch name {
+		case "id":
+			continue
+		case "flag":
+			v.Flag, err = strconv.ParseBool(strings.TrimSpace(value))
+		case "rank":
+			v.Rank, err = strconv.Atoi(strings.TrimSpace(value))
+		case "index":
+			v.Index, err = strconv.Atoi(strings.TrimSpace(value))
+		default:
+			// An empty value is a missing property.
+			if value == "" {
+				continue
+			}
+			if v.Properties == nil {
+				v.Properties = make(map[string]string)
+			}
+			v.Properties[name] = value
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", name, value)
+		}
+	}
+
+	return v, nil
+}
+
+func (l *csvLoader) loadEdges(rows rowReader, addVertices bool) error {
+rows:
+	for {
+		record, line, err := rows.next()
+		if err == io.EOF {
+			break
+		}
+
+		row := RejectedRow{File: "edges", Line: line, Record: record}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			row.Line, row.Reason = parseErr.Line, parseErr.Err.Error()
+			l.reject(row)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(record) != 2 {
+			row.Reason = fmt.Sprintf("expected 2 fields, found %d", len(record))
+			l.reject(row)
+			continue
+		}
+
+		parent, child := strings.TrimSpace(record[0]), strings.TrimSpace(record[1])
+		if parent == "" || child == "" {
+			row.Reason = "missing parent or child"
+			l.reject(row)
+			continue
+		}
+		if parent == child {
+			row.Reason = "edge (" + parent + "," + child + ") creates a cycle"
+			l.reject(row)
+			continue
+		}
+
+		for _, id := range []string{parent, child} {
+			if _, ok := l.seen[id]; ok {
+				continue
+			}
+
+			if !addVertices {
+				row.Reason = "dangling reference to vertex " + id
+				l.reject(row)
+				continue rows
+			}
+
+			added, err := l.add(model.Op{Type: model.OpAddVertex, Vertex: model.NewVertex(id, false, 0)}, row)
+			if err != nil {
+				return err
+			}
+			if !added {
+				continue rows
+			}
+		}
+
+		if _, err := l.add(model.Op{Type: model.OpAddEdge, Parent: parent, Child: child}, row); err != nil {
+			return err
+		}
+	}
+
+	return l.flush()
+}
+
+func (l *csvLoader) reject(row RejectedRow) {
+	l.report.Rejected = append(l.report.Rejected, row)
+}
+
+// add validates the mutation of the row against the store and the pending
+// mutations, and stages it, or rejects the row if it is invalid. It applies
+// the pending batch once it is full.
+func (l *csvLoader) add(op model.Op, row RejectedRow) (bool, error) {
+	if err := l.stager.Add(op); err != nil {
+		if l.err != nil {
+			return false, l.err
+		}
+
+		row.Reason = err.Error()
+		l.reject(row)
+		return false, nil
+	}
+	if op.Type == model.OpAddVertex {
+		l.seen[op.Vertex.ID] = struct{}{}
+	}
+
+	if l.stager.Len() < l.size {
+		return true, nil
+	}
+
+	return true, l.flush()
+}
+
+// flush applies the pending mutations, which are all valid.
+func (l *csvLoader) flush() error {
+	if l.stager.Len() == 0 {
+		return nil
+	}
+
+	batch := l.stager.Batch()
+	l.release()
+	l.release = func() {}
+	if err := l.ds.Apply(batch); err != nil {
+		return err
+	}
+
+	for _, op := range batch.Ops() {
+		if op.Type == model.OpAddVertex {
+			l.report.Vertices++
+		} else {
+			l.report.Edges++
+		}
+	}
+
+	return l.newStager()
+}
+
+// rowReader reads the records of a CSV or edge list file, with their line.
+type rowReader interface {
+	// next returns io.EOF at the end of the file, and a *csv.ParseError for
+	// an invalid row, after which the reading can go on.
+	next() ([]string, int, error)
+}
+
+func newRowReader(r io.Reader, comma rune) rowReader {
+	if comma == ' ' {
+		return &fieldsReader{s: bufio.NewScanner(r)}
+	}
+
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+
+	return &csvRowReader{r: cr}
+}
+
+type csvRowReader struct {
+	r *csv.Reader
+}
+
+func (r *csvRowReader) next() ([]string, int, error) {
+	record, err := r.r.Read()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	line, _ := r.r.FieldPos(0)
+
+	return record, line, nil
+}
+
+// fieldsReader reads the whitespace-separated fields of the lines, skipping
+// the empty lines and the comments starting with '#'.
+type fieldsReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func (r *fieldsReader) next() ([]string, int, error) {
+	for r.s.Scan() {
+		r.line++
+
+		text := strings.TrimSpace(r.s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		return strings.Fields(text), r.line, nil
+	}
+
+	if err := r.s.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return nil, 0, io.EOF
+}
+
+// ExportCSV writes the stored graph as a vertex file and an edge file, which
+// can be read by ImportCSV. The vertex file, which may be nil, has a header
+// with the columns id, flag, rank, index and the names of the properties. The
+// edge file has a header if opts.Header is set.
+//
+// The store is walked once per file, and once more for the names of the
+// properties, so the graph is never loaded in memory.
+func ExportCSV(ds GraphStore, vertices, edges io.Writer, opts CSVOptions) error {
+	comma := opts.comma()
+
+	if vertices != nil {
+		if err := exportCSVVertices(ds, vertices, comma); err != nil {
+			return err
+		}
+	}
+
+	w := csv.NewWriter(edges)
+	w.Comma = comma
+
+	if opts.Header {
+		if err := w.Write([]string{"parent", "child"}); err != nil {
+			return err
+		}
+	}
+
+	err := ds.Walk(func(v *model.Vertex) error {
+		children := make([]string, 0, len(v.Children))
+		for c := range v.Children {
+			children = append(children, c)
+		}
+		sort.Strings(children)
+
+		for _, c := range children {
+			if err := w.Write([]string{v.ID, c}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
+
+func exportCSVVertices(ds GraphStore, out io.Writer, comma rune) error {
+	names := make(map[string]struct{})
+	err := ds.Walk(func(v *model.Vertex) error {
+		for name := range v.Properties {
+			names[name] = struct{}{}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	properties := make([]string, 0, len(names))
+	for name := range names {
+		properties = append(properties, name)
+	}
+	sort.Strings(properties)
+
+	w := csv.NewWriter(out)
+	w.Comma = comma
+
+	if err := w.Write(append([]string{"id", "flag", "rank", "index"}, properties...)); err != nil {
+		return err
+	}
+
+	err = ds.Walk(func(v *model.Vertex) error {
+		record := []string{v.ID, strconv.FormatBool(v.Flag), strconv.Itoa(v.Rank), strconv.Itoa(v.Index)}
+		for _, name := range properties {
+			record = append(record, v.Properties[name])
+		}
+
+		return w.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ahmadmuzakkir/dag/model"
+)
+
+// The keys of the edge-per-key layout. An edge from parent to child has a
+// key among the parents of the child, ParentKey(child, parent), and a key
+// among the children of the parent, ChildKey(parent, child), so the edges of
+// a vertex are scanned by prefix, and an edge change only writes its two
+// keys, whatever the number of edges of the vertices.
+//
+// The first ID of a key is prefixed with its length, so the prefix of a
+// vertex is not the prefix of another vertex with a longer ID.
+//
+// This differs from the p/<parent>/<child> and c/<child>/<parent> keys first
+// proposed for the layout. A key starts with the vertex whose edges it lists,
+// so p/ lists the parents of a child and c/ the children of a parent, as the
+// names say, and the IDs are not separated with '/', which an ID may contain.
+var (
+	ParentsKeyPrefix  = []byte("p/")
+	ChildrenKeyPrefix = []byte("c/")
+)
+
+// ParentsPrefix returns the prefix of the keys of the parents of the vertex.
+func ParentsPrefix(id string) []byte {
+	return edgePrefix(ParentsKeyPrefix, id)
+}
+
+// ChildrenPrefix returns the prefix of the keys of the children of the vertex.
+func ChildrenPrefix(id string) []byte {
+	return edgePrefix(ChildrenKeyPrefix, id)
+}
+
+// ParentKey returns the key of the parent among the parents of the child.
+func ParentKey(child, parent string) []byte {
+	return append(ParentsPrefix(child), parent...)
+}
+
+// ChildKey returns the key of the child among the children of the parent.
+func ChildKey(parent, child string) []byte {
+	return append(ChildrenPrefix(parent), child...)
+}
+
+func edgePrefix(prefix []byte, id string) []byte {
+	var n [binary.MaxVarintLen64]byte
+	size := binary.PutUvarint(n[:], uint64(len(id)))
+
+	// With room for the other ID of a key, which usually has the same length.
+	key := make([]byte, 0, len(prefix)+size+2*len(id))
+	key = append(key, prefix...)
+	key = append(key, n[:size]...)
+
+	return append(key, id...)
+}
+
+// ParseEdgeKey returns the IDs of a key of ParentKey or ChildKey: the vertex
+// of the prefix, and its parent or child.
+func ParseEdgeKey(key []byte) (id, other string, err error) {
+	if len(key) < len(ParentsKeyPrefix) {
+		return "", "", fmt.Errorf("invalid edge key %q", key)
+	}
+
+	rest := key[len(ParentsKeyPrefix):]
+	n, size := binary.Uvarint(rest)
+	if size <= 0 || uint64(len(rest)-size) < n {
+		return "", "", fmt.Errorf("invalid edge key %q", key)
+	}
+
+	return string(rest[size : size+int(n)]), string(rest[size+int(n):]), nil
+}
+
+// DiffEdgeKeys calls put with the edge keys of v which are not edge keys of
+// old, and del with the edge keys of old which are not edge keys of v, so
+// only the changed edges of a vertex are written. old is nil for a new vertex
+// or a vertex without edge keys, and v for a deleted vertex.
+func DiffEdgeKeys(old, v *model.Vertex, put, del func(key []byte) error) error {
+	var id string
+	var oldParents, oldChildren, parents, children map[string]struct{}
+	if old != nil {
+		id, oldParents, oldChildren = old.ID, old.Parents, old.Children
+	}
+	if v != nil {
+		id, parents, children = v.ID, v.Parents, v.Children
+	}
+
+	for _, edges := range []struct {
+		key      func(id, other string) []byte
+		old, new map[string]struct{}
+	}{
+		{ParentKey, oldParents, parents},
+		{ChildKey, oldChildren, children},
+	} {
+		for other := range edges.new {
+			if _, ok := edges.old[other]; !ok {
+				if err := put(edges.key(id, other)); err != nil {
+					return err
+				}
+			}
+		}
+		for other := range edges.old {
+			if _, ok := edges.new[other]; !ok {
+				if err := del(edges.key(id, other)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// DiffParentKeys calls put with the two keys of every edge from a parent of
+// v which is not a parent of old, and del with the two keys of every edge
+// from a parent of old which is not a parent of v. A changed edge changes the
+// parents of its child, so the parents of the vertices changed by a batch
+// give all its changed edges, and the vertices may be staged without their
+// children, see model.ParentsGetter. old is nil for a new vertex, and v for a
+// deleted vertex.
+func DiffParentKeys(old, v *model.Vertex, put, del func(key []byte) error) error {
+	var id string
+	var oldParents, parents map[string]struct{}
+	if old != nil {
+		id, oldParents = old.ID, old.Parents
+	}
+	if v != nil {
+		id, parents = v.ID, v.Parents
+	}
+
+	for p := range parents {
+		if _, ok := oldParents[p]; !ok {
+			if err := put(ParentKey(id, p)); err != nil {
+				return err
+			}
+			if err := put(ChildKey(p, id)); err != nil {
+				return err
+			}
+		}
+	}
+	for p := range oldParents {
+		if _, ok := parents[p]; !ok {
+			if err := del(ParentKey(id, p)); err != nil {
+				return err
+			}
+			if err := del(ChildKey(p, id)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// WithoutEdges returns a copy of the vertex without its parents and children,
+// for its record in the edge-per-key layout. The copy shares the properties.
+func WithoutEdges(v *model.Vertex) *model.Vertex {
+	record := *v
+	record.Parents = nil
+	record.Children = nil
+
+	return &record
+}
+
+// HasEdges reports whether the vertex has parents or children, e.g. a record
+// written before the edge-per-key layout.
+func HasEdges(v *model.Vertex) bool {
+	return len(v.Parents) != 0 || len(v.Children) != 0
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"github.com/ahmadmuzakkir/dag/model"
+)
+
+// BatchWriter accumulates mutations and applies them to a store in batches.
+// Vertices must be added before the edges which reference them, but they
+// may be in an earlier batch.
+//
+// A batch is bounded by its number of mutations, vertices and edges, so the
+// edges which follow all the vertices of an import are batched too. A batch
+// therefore has fewer vertices than its size when it also has edges.
+type BatchWriter struct {
+	// Progress, if set, is called after every applied batch with the number
+	// of vertices written so far, which does not grow by the size of a batch
+	// when the batches have edges.
+	Progress func(written int)
+
+	ds      GraphStore
+	size    int
+	batch   *model.Batch
+	pending int
+	written int
+}
+
+// NewBatchWriter creates a writer which applies a batch every size mutations.
+func NewBatchWriter(ds GraphStore, size int) *BatchWriter {
+	if size < 1 {
+		size = 1
+	}
+
+	return &BatchWriter{
+		ds:    ds,
+		size:  size,
+		batch: model.NewBatch(),
+	}
+}
+
+// AddVertex adds the vertex, without its edges. It applies the pending batch
+// first if it is full.
+func (w *BatchWriter) AddVertex(v *model.Vertex) error {
+	if err := w.flushIfFull(); err != nil {
+		return err
+	}
+
+	vertex := model.NewVertex(v.ID, v.Flag, v.Rank)
+	vertex.Index = v.Index
+	vertex.Properties = v.CloneProperties()
+
+	w.batch.AddVertex(vertex)
+	w.pending++
+
+	return nil
+}
+
+// AddEdge adds the edge. It applies the pending batch first if it is full.
+func (w *BatchWriter) AddEdge(parent, child string) error {
+	if err := w.flushIfFull(); err != nil {
+		return err
+	}
+
+	w.batch.AddEdge(parent, child)
+
+	return nil
+}
+
+func (w *BatchWriter) flushIfFull() error {
+	if w.batch.Len() < w.size {
+		return nil
+	}
+
+	return w.Flush()
+}
+
+// Flush applies the pending batch.
+func (w *BatchWriter) Flush() error {
+	if w.batch.Len() == 0 {
+		return nil
+	}
+
+	if err := w.ds.Apply(w.batch); err != nil {
+		return err
+	}
+
+	w.batch.Reset()
+	w.written += w.pending
+	w.pending = 0
+
+	if w.Progress != nil {
+		w.Progress(w.written)
+	}
+
+	return nil
+}
+
+// StreamOptions configures Generate.
+type StreamOptions struct {
+	// Number of mutations per batch. Defaults to 1000.
+	BatchSize int
+
+	// Progress, if set, is called after every batch with the number of
+	// vertices written so far. A batch has the edges of the parents of its
+	// vertices, so it has fewer vertices than BatchSize.
+	Progress func(written, total int)
+}
+
+// Generate generates a graph with model.GenerateStream and writes it into the
+// store, replacing the existing graph. The vertices are written rank by rank
+// in batches, so the graph never has to fit in memory.
+func Generate(ds GraphStore, opts model.GeneratorOptions, sopts StreamOptions) error {
+	if sopts.BatchSize <= 0 {
+		sopts.BatchSize = 1000
+	}
+
+	// Clear the old data first.
+	if err := ds.Insert(model.NewDAG()); err != nil {
+		return err
+	}
+
+	w := NewBatchWriter(ds, sopts.BatchSize)
+	if sopts.Progress != nil {
+		w.Progress = func(written int) {
+			sopts.Progress(written, opts.Size)
+		}
+	}
+
+	err := model.GenerateStream(opts, func(v *model.Vertex) error {
+		if err := w.AddVertex(v); err != nil {
+			return err
+		}
+
+		for p := range v.Parents {
+			if err := w.AddEdge(p, v.ID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,676 @@
+package leveldbstore
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/ahmadmuzakkir/dag/store"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+var (
+	_ store.GraphStore = (*LevelDBStore)(nil)
+	_ store.Viewer     = (*LevelDBStore)(nil)
+)
+
+// The prefix of the vertex keys. A vertex is a record without its edges at
+// v/<id>, and an edge is an empty value at the keys of store.ParentKey and
+// store.ChildKey.
+var vertexPrefix = []byte("v/")
+
+// LevelDBStore is a GraphStore which keeps the graph in a LevelDB database,
+// with a key per vertex and per edge, so a mutation only writes the keys of
+// the vertices and edges it changes.
+type LevelDBStore struct {
+	db    *leveldb.DB
+	codec store.AtomicCodec
+
+	// The mutations read the graph before they write it, so they are
+	// serialized.
+	mu sync.Mutex
+
+	observers model.Observers
+}
+
+func NewLevelDBStore(db *leveldb.DB) *LevelDBStore {
+	return &LevelDBStore{
+		db: db,
+	}
+}
+
+// SetCodec sets the codec of the records written from now on. The records
+// of any codec are read. It may be called while the store is used.
+func (l *LevelDBStore) SetCodec(c store.Codec) {
+	l.codec.Store(c)
+}
+
+// source is implemented by the database and its snapshots.
+type source interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+}
+
+// reader reads the vertices of a source. The edges are scanned with a single
+// iterator, which seeks the prefix of a vertex, since creating an iterator
+// costs more than seeking one.
+type reader struct {
+	source
+	edges iterator.Iterator
+}
+
+func newReader(s source) *reader {
+	return &reader{
+		source: s,
+		edges:  s.NewIterator(nil, nil),
+	}
+}
+
+func (r *reader) release() {
+	r.edges.Release()
+}
+
+// scan calls fn with the suffix of the edge keys with the prefix.
+func (r *reader) scan(prefix []byte, fn func(suffix []byte)) error {
+	for ok := r.edges.Seek(prefix); ok && bytes.HasPrefix(r.edges.Key(), prefix); ok = r.edges.Next() {
+		fn(r.edges.Key()[len(prefix):])
+	}
+
+	return r.edges.Error()
+}
+
+func vertexKey(id string) []byte {
+	return append(append([]byte(nil), vertexPrefix...), id...)
+}
+
+// view calls fn with a snapshot of the database, so its reads see the same
+// graph.
+func (l *LevelDBStore) view(fn func(r *reader) error) error {
+	snapshot, err := l.db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+
+	r := newReader(snapshot)
+	defer r.release()
+
+	return fn(r)
+}
+
+// View returns a getter of the vertices of a snapshot of the database, which
+// reads the children of a vertex only if they are needed.
+func (l *LevelDBStore) View() (model.VertexGetter, func(), error) {
+	snapshot, err := l.db.GetSnapshot()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := newReader(snapshot)
+	get := model.ParentsGetterFunc(func(id string, children bool) (*model.Vertex, error) {
+		return l.read(r, id, children)
+	})
+
+	return get, func() {
+		r.release()
+		snapshot.Release()
+	}, nil
+}
+
+func (l *LevelDBStore) Get() (*model.DAG, error) {
+	vertices := make(map[string]*model.Vertex)
+
+	err := l.view(func(r *reader) error {
+		it := r.NewIterator(util.BytesPrefix(vertexPrefix), nil)
+		for it.Next() {
+			v, err := l.unmarshal(it.Value())
+			if err != nil {
+				it.Release()
+				return err
+			}
+			vertices[v.ID] = v
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return err
+		}
+
+		// The children keys of a vertex are in a single run, so its ID is
+		// decoded once.
+		it = r.NewIterator(util.BytesPrefix(store.ChildrenKeyPrefix), nil)
+		defer it.Release()
+
+		var parent *model.Vertex
+		var prefix []byte
+		for it.Next() {
+			key := it.Key()
+			if parent == nil || !bytes.HasPrefix(key, prefix) {
+				id, _, err := store.ParseEdgeKey(key)
+				if err != nil {
+					return err
+				}
+				if parent = vertices[id]; parent == nil {
+					return fmt.Errorf("edge from missing vertex %s", id)
+				}
+				prefix = store.ChildrenPrefix(id)
+			}
+
+			child := vertices[string(key[len(prefix):])]
+			if child == nil {
+				return fmt.Errorf("edge to missing vertex %s", key[len(prefix):])
+			}
+			parent.Children[child.ID] = struct{}{}
+			child.Parents[parent.ID] = struct{}{}
+		}
+
+		return it.Error()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	graph := model.NewDAG()
+	for _, v := range vertices {
+		graph.AddVertex(v)
+	}
+
+	return graph, nil
+}
+
+func (l *LevelDBStore) GetVertexByPosition(position int) (*model.Vertex, error) {
+	var vertex *model.Vertex
+	err := l.view(func(r *reader) error {
+		it := r.NewIterator(util.BytesPrefix(vertexPrefix), nil)
+		defer it.Release()
+
+		for i := 0; it.Next(); i++ {
+			if i == position {
+				var err error
+				vertex, err = l.unmarshal(it.Value())
+				if err != nil {
+					return err
+				}
+
+				return l.readEdges(r, vertex, true)
+			}
+		}
+
+		return it.Error()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if vertex == nil {
+		return nil, fmt.Errorf("vertex at position %v does not exist", position)
+	}
+
+	return vertex, nil
+}
+
+func (l *LevelDBStore) Insert(g *model.DAG) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The old graph is deleted in the same batch, so the graph is replaced
+	// atomically.
+	batch := new(leveldb.Batch)
+	for _, prefix := range [][]byte{vertexPrefix, store.ParentsKeyPrefix, store.ChildrenKeyPrefix} {
+		it := l.db.NewIterator(util.BytesPrefix(prefix), nil)
+		for it.Next() {
+			batch.Delete(append([]byte(nil), it.Key()...))
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range g.Vertices() {
+		if err := l.put(batch, v); err != nil {
+			return err
+		}
+	}
+
+	if err := l.db.Write(batch, nil); err != nil {
+		return err
+	}
+
+	l.observers.Emit(model.GraphReplaced{Graph: g})
+
+	return nil
+}
+
+// Walk calls fn with the vertices of a snapshot, in order of ID.
+func (l *LevelDBStore) Walk(fn func(v *model.Vertex) error) error {
+	return l.view(func(r *reader) error {
+		it := r.NewIterator(util.BytesPrefix(vertexPrefix), nil)
+		defer it.Release()
+
+		for it.Next() {
+			v, err := l.unmarshal(it.Value())
+			if err != nil {
+				return err
+			}
+			if err := l.readEdges(r, v, true); err != nil {
+				return err
+			}
+
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+
+		return it.Error()
+	})
+}
+
+// Apply validates the batch against the stored graph and applies it in a single write.
+func (l *LevelDBStore) Apply(b *model.Batch) error {
+	changes, err := l.apply(b)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range changes.Events {
+		l.observers.Emit(e)
+	}
+
+	return nil
+}
+
+func (l *LevelDBStore) apply(b *model.Batch) (*model.Changes, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r := newReader(l.db)
+	defer r.release()
+
+	// The vertices read, to write only the keys of the changed edges. The
+	// children of a vertex are only read to delete it.
+	old := make(map[string]*model.Vertex)
+	changes, err := b.Stage(model.ParentsGetterFunc(func(id string, children bool) (*model.Vertex, error) {
+		v, err := l.read(r, id, children)
+		if err == nil {
+			old[id] = v
+		}
+		return v, err
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	batch := new(leveldb.Batch)
+	for id, v := range changes.Vertices {
+		if err := l.putChanged(batch, id, old[id], v); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := l.db.Write(batch, nil); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// put adds the keys of the vertex and of all its edges to the batch.
+func (l *LevelDBStore) put(batch *leveldb.Batch, v *model.Vertex) error {
+	if err := l.putRecord(batch, v.ID, v); err != nil {
+		return err
+	}
+
+	return store.DiffEdgeKeys(nil, v, batchPut(batch), batchDelete(batch))
+}
+
+// putChanged adds the key of the vertex with the ID changed by a batch, and
+// the keys of its changed edges, see store.DiffParentKeys, to the batch. old
+// is the vertex read by the batch, nil for a new vertex, and the vertex is
+// nil if it is deleted.
+func (l *LevelDBStore) putChanged(batch *leveldb.Batch, id string, old, v *model.Vertex) error {
+	if err := l.putRecord(batch, id, v); err != nil {
+		return err
+	}
+
+	return store.DiffParentKeys(old, v, batchPut(batch), batchDelete(batch))
+}
+
+// putRecord adds the record of the vertex with the ID, without its edges, to
+// the batch, or its deletion if the vertex is nil.
+func (l *LevelDBStore) putRecord(batch *leveldb.Batch, id string, v *model.Vertex) error {
+	if v == nil {
+		batch.Delete(vertexKey(id))
+		return nil
+	}
+
+	data, err := store.EncodeRecord(l.codec.Load(), store.WithoutEdges(v))
+	if err != nil {
+		return err
+	}
+	batch.Put(vertexKey(id), data)
+
+	return nil
+}
+
+func batchPut(batch *leveldb.Batch) func(key []byte) error {
+	return func(key []byte) error {
+		batch.Put(key, nil)
+		return nil
+	}
+}
+
+func batchDelete(batch *leveldb.Batch) func(key []byte) error {
+	return func(key []byte) error {
+		batch.Delete(key)
+		return nil
+	}
+}
+
+func (l *LevelDBStore) GetVertex(id string) (*model.Vertex, error) {
+	var vertex *model.Vertex
+	err := l.view(func(r *reader) error {
+		var err error
+		vertex, err = l.getByID(r, id)
+		return err
+	})
+
+	return vertex, err
+}
+
+func (l *LevelDBStore) AddVertex(v *model.Vertex) error {
+	batch := model.NewBatch()
+	batch.AddVertex(v)
+
+	return store.OpError(l.Apply(batch))
+}
+
+func (l *LevelDBStore) UpdateVertex(v *model.Vertex) error {
+	batch := model.NewBatch()
+	batch.UpdateVertex(v)
+
+	return store.OpError(l.Apply(batch))
+}
+
+func (l *LevelDBStore) DeleteVertex(id string) error {
+	batch := model.NewBatch()
+	batch.DeleteVertex(id)
+
+	return store.OpError(l.Apply(batch))
+}
+
+func (l *LevelDBStore) AddEdge(parent, child string) error {
+	batch := model.NewBatch()
+	batch.AddEdge(parent, child)
+
+	return store.OpError(l.Apply(batch))
+}
+
+func (l *LevelDBStore) DeleteEdge(parent, child string) error {
+	batch := model.NewBatch()
+	batch.DeleteEdge(parent, child)
+
+	return store.OpError(l.Apply(batch))
+}
+
+func (l *LevelDBStore) Subscribe(o model.Observer) func() {
+	return l.observers.Subscribe(o)
+}
+
+func (l *LevelDBStore) AncestorsBFS(id string, filter func(*model.Vertex) bool) ([]*model.Vertex, error) {
+	var list []*model.Vertex
+
+	err := l.view(func(r *reader) error {
+		v, err := l.getByID(r, id)
+		if err != nil {
+			return err
+		}
+
+		list, err = l.bfs(r, v, filter)
+		return err
+	})
+	return list, err
+}
+
+// bfs returns the ancestors of the vertex v, read from the snapshot of r,
+// like AncestorsBFS.
+func (l *LevelDBStore) bfs(r *reader, v *model.Vertex, filter func(*model.Vertex) bool) ([]*model.Vertex, error) {
+	var list []*model.Vertex
+
+	q := []*model.Vertex{v}
+	visited := make(map[string]struct{})
+	visited[v.ID] = struct{}{}
+
+	for len(q) != 0 {
+		u := q[0]
+		q = q[1:len(q):len(q)]
+
+		for p := range u.Parents {
+			if _, ok := visited[p]; !ok {
+				visited[p] = struct{}{}
+
+				pv, err := l.getByID(r, p)
+				if err != nil {
+					return nil, err
+				}
+				q = append(q, pv)
+
+				if filter == nil || filter(pv) {
+					list = append(list, pv)
+				}
+			}
+		}
+	}
+
+	return list, nil
+}
+
+func (l *LevelDBStore) AncestorsDFS(id string, filter func(*model.Vertex) bool) ([]*model.Vertex, error) {
+	var list []*model.Vertex
+
+	err := l.view(func(r *reader) error {
+		if _, err := l.getByID(r, id); err != nil {
+			return err
+		}
+
+		s := []string{id}
+		visited := make(map[string]struct{})
+
+		for len(s) != 0 {
+			u := s[len(s)-1]
+			s = s[: len(s)-1 : len(s)-1]
+
+			if _, ok := visited[u]; !ok {
+				visited[u] = struct{}{}
+
+				v, err := l.getByID(r, u)
+				if err != nil {
+					return err
+				}
+
+				if filter == nil || filter(v) {
+					list = append(list, v)
+				}
+
+				for p := range v.Parents {
+					if _, ok := visited[p]; !ok {
+						s = append(s, p)
+					}
+				}
+			}
+		}
+
+		return nil
+	})
+	return list, err
+}
+
+func (l *LevelDBStore) ancestors(algo store.Algo, id string, filter func(*model.Vertex) bool) ([]*model.Vertex, error) {
+	if algo == store.ALGO_DFS {
+		return l.AncestorsDFS(id, filter)
+	}
+
+	return l.AncestorsBFS(id, filter)
+}
+
+func flagFilter(flag bool) func(*model.Vertex) bool {
+	return func(v *model.Vertex) bool {
+		return v.Flag == flag
+	}
+}
+
+func (l *LevelDBStore) Reach(algo store.Algo, id string) (int, error) {
+	list, err := l.ancestors(algo, id, nil)
+
+	return len(list), err
+}
+
+func (l *LevelDBStore) ConditionalReach(algo store.Algo, id string, flag bool) (int, error) {
+	list, err := l.ancestors(algo, id, flagFilter(flag))
+
+	return len(list), err
+}
+
+func (l *LevelDBStore) List(algo store.Algo, id string) ([]*model.Vertex, error) {
+	return l.ancestors(algo, id, nil)
+}
+
+func (l *LevelDBStore) ConditionalList(algo store.Algo, id string, flag bool) ([]*model.Vertex, error) {
+	return l.ancestors(algo, id, flagFilter(flag))
+}
+
+// MustPassAncestors reads the vertex and its ancestors from one snapshot.
+func (l *LevelDBStore) MustPassAncestors(id string) ([]*model.Vertex, error) {
+	var vertex *model.Vertex
+	var ancestors []*model.Vertex
+
+	err := l.view(func(r *reader) error {
+		var err error
+		if vertex, err = l.getByID(r, id); err != nil {
+			return err
+		}
+
+		ancestors, err = l.bfs(r, vertex, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return model.MustPassAncestors(vertex, ancestors)
+}
+
+func (l *LevelDBStore) Stats() (*model.Stats, error) {
+	graph, err := l.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	return graph.Stats()
+}
+
+func (l *LevelDBStore) getByID(r *reader, id string) (*model.Vertex, error) {
+	return l.read(r, id, true)
+}
+
+// read reads the vertex with its parents, and its children if children is
+// set.
+func (l *LevelDBStore) read(r *reader, id string, children bool) (*model.Vertex, error) {
+	data, err := r.Get(vertexKey(id), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, fmt.Errorf("%w: %s", model.ErrVertexNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := l.unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, l.readEdges(r, v, children)
+}
+
+// readEdges reads the parents of the vertex, and its children if children is
+// set, from its edge keys.
+func (l *LevelDBStore) readEdges(r *reader, v *model.Vertex, children bool) error {
+	if err := scanIDs(r, store.ParentsPrefix(v.ID), v.Parents); err != nil {
+		return err
+	}
+	if children {
+		return scanIDs(r, store.ChildrenPrefix(v.ID), v.Children)
+	}
+
+	return nil
+}
+
+// scanIDs adds the IDs of the edge keys with the prefix to ids.
+func scanIDs(r *reader, prefix []byte, ids map[string]struct{}) error {
+	return r.scan(prefix, func(id []byte) {
+		ids[string(id)] = struct{}{}
+	})
+}
+
+func (l *LevelDBStore) unmarshal(data []byte) (*model.Vertex, error) {
+	v, err := store.DecodeRecord(data)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal error: %w", err)
+	}
+
+	return v, nil
+}
+
+// The number of records rewritten by a write of Migrate.
+const migrateBatchSize = 10000
+
+// Migrate rewrites in place the vertex records which are not written with the
+// codec of the store at the current schema version, and returns their number.
+// The records are rewritten in batches of writes, so Migrate can be run again
+// if it fails.
+func (l *LevelDBStore) Migrate() (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	codec := l.codec.Load()
+	migrated := 0
+	it := l.db.NewIterator(util.BytesPrefix(vertexPrefix), nil)
+	defer it.Release()
+
+	batch := new(leveldb.Batch)
+	for it.Next() {
+		if !store.NeedsMigration(it.Value(), codec) {
+			continue
+		}
+
+		v, err := l.unmarshal(it.Value())
+		if err != nil {
+			return migrated, fmt.Errorf("vertex %s: %w", it.Key()[len(vertexPrefix):], err)
+		}
+		data, err := store.EncodeRecord(codec, v)
+		if err != nil {
+			return migrated, err
+		}
+		batch.Put(append([]byte(nil), it.Key()...), data)
+
+		if batch.Len() == migrateBatchSize {
+			if err := l.db.Write(batch, nil); err != nil {
+				return migrated, err
+			}
+			migrated += batch.Len()
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return migrated, err
+	}
+
+	if err := l.db.Write(batch, nil); err != nil {
+		return migrated, err
+	}
+
+	return migrated + batch.Len(), nil
+}
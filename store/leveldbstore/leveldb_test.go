@@ -0,0 +1,208 @@
+package leveldbstore
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"testing"
+
+	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/ahmadmuzakkir/dag/store"
+	"github.com/ahmadmuzakkir/dag/store/storetest"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	testLevelDBDir         = "/tmp/leveldb_test"
+	testLevelDBTopologyDir = "/tmp/leveldb_test_topology"
+)
+
+// Initiate the database and insert a new graph.
+func init() {
+	ds, teardown, err := getLevelDBDataStore()
+	defer teardown()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := storetest.InsertBenchGraph(ds); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func TestDAG(t *testing.T) {
+	ds, teardown, err := getLevelDBDataStore()
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storetest.CheckBenchGraph(t, ds)
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.GraphStore {
+		ds, teardown, err := openLevelDBDataStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(teardown)
+
+		return ds
+	})
+}
+
+// A mutation only writes the keys of the vertices and edges it changes.
+func TestKeys(t *testing.T) {
+	ds, teardown, err := openLevelDBDataStore(t.TempDir())
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph := model.NewDAG()
+	graph.AddVertex(model.NewVertex("a", false, 0))
+	graph.AddVertex(model.NewVertex("ab", true, 1))
+	if err := ds.Insert(graph); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.AddEdge("a", "ab"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := func() []string {
+		var keys []string
+		it := ds.db.NewIterator(nil, nil)
+		defer it.Release()
+		for it.Next() {
+			keys = append(keys, string(it.Key()))
+		}
+		return keys
+	}
+
+	// The IDs of the edge keys are prefixed with their length, so the
+	// children of a are not mixed up with the children of ab.
+	expected := []string{"c/\x01aab", "p/\x02aba", "v/a", "v/ab"}
+	if found := keys(); !reflect.DeepEqual(found, expected) {
+		t.Fatalf("expected the keys %q, found %q", expected, found)
+	}
+
+	if err := ds.DeleteVertex("a"); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"v/ab"}
+	if found := keys(); !reflect.DeepEqual(found, expected) {
+		t.Fatalf("expected the keys %q, found %q", expected, found)
+	}
+
+	v, err := ds.GetVertex("ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Parents) != 0 {
+		t.Fatalf("unexpected parents %v", v.Parents)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ds, teardown, err := openLevelDBDataStore(t.TempDir())
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := model.GenerateGraph(migrateBatchSize + 500)
+	ds.SetCodec(store.JSONCodec)
+	if err := ds.Insert(expected); err != nil {
+		t.Fatal(err)
+	}
+
+	ds.SetCodec(store.VarintCodec)
+	if n, err := ds.Migrate(); err != nil || n != expected.CountVertex() {
+		t.Fatalf("expected %d migrated records, found %d, %v", expected.CountVertex(), n, err)
+	}
+	if n, err := ds.Migrate(); err != nil || n != 0 {
+		t.Fatalf("expected no migrated records, found %d, %v", n, err)
+	}
+
+	it := ds.db.NewIterator(util.BytesPrefix(vertexPrefix), nil)
+	for it.Next() {
+		if store.NeedsMigration(it.Value(), store.VarintCodec) {
+			t.Fatalf("record %s is not migrated", it.Key())
+		}
+	}
+	it.Release()
+
+	graph, err := ds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(graph.Vertices(), expected.Vertices()) {
+		t.Fatal("expected the migrated records to be read")
+	}
+}
+
+func BenchmarkReach(b *testing.B) {
+	benchmarkQuery(b, storetest.Reach)
+}
+
+func BenchmarkConditionalReach(b *testing.B) {
+	benchmarkQuery(b, storetest.ConditionalReach)
+}
+
+func BenchmarkList(b *testing.B) {
+	benchmarkQuery(b, storetest.List)
+}
+
+func BenchmarkConditionalList(b *testing.B) {
+	benchmarkQuery(b, storetest.ConditionalList)
+}
+
+func BenchmarkTopologies(b *testing.B) {
+	ds, teardown, err := openLevelDBDataStore(testLevelDBTopologyDir)
+	defer teardown()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	storetest.BenchmarkTopologies(b, ds)
+}
+
+func BenchmarkAddEdge(b *testing.B) {
+	ds, teardown, err := openLevelDBDataStore(testLevelDBTopologyDir)
+	defer teardown()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	storetest.BenchmarkAddEdge(b, ds)
+}
+
+func benchmarkQuery(b *testing.B, query storetest.Query) {
+	ds, teardown, err := getLevelDBDataStore()
+	defer teardown()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	storetest.BenchmarkQuery(b, ds, query)
+}
+
+func getLevelDBDataStore() (*LevelDBStore, func(), error) {
+	return openLevelDBDataStore(testLevelDBDir)
+}
+
+func openLevelDBDataStore(dir string) (*LevelDBStore, func(), error) {
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("failed to open leveldb: %s", err)
+	}
+	teardown := func() {
+		db.Close()
+	}
+
+	var ds = NewLevelDBStore(db)
+
+	return ds, teardown, nil
+}
@@ -0,0 +1,406 @@
+package memorystore
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/ahmadmuzakkir/dag/store"
+)
+
+var (
+	_ store.GraphStore = (*MemoryStore)(nil)
+	_ store.Viewer     = (*MemoryStore)(nil)
+)
+
+// MemoryStore is a GraphStore which keeps the graph in memory, in a
+// model.DAG. It is safe for concurrent use: the queries run concurrently, and
+// the mutations one at a time. The zero value is an empty store.
+//
+// The vertices returned by the store are copies, or belong to a snapshot of
+// the graph, so they are not modified by the later mutations.
+type MemoryStore struct {
+	mu  sync.RWMutex
+	dag *model.DAG
+
+	// The IDs in order, for GetVertexByPosition, and the version of the
+	// graph they were sorted at.
+	ids        []string
+	idsVersion uint64
+
+	// The path of the binary snapshot of Save, if any.
+	path string
+
+	observers model.Observers
+}
+
+// DataMock is the former name of MemoryStore.
+//
+// Deprecated: use MemoryStore.
+type DataMock = MemoryStore
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		dag: model.NewDAG(),
+	}
+}
+
+// OpenMemoryStore returns a store which is persisted to the binary snapshot
+// at path by Save. The graph of the snapshot is loaded if the file exists.
+func OpenMemoryStore(path string) (*MemoryStore, error) {
+	m := NewMemoryStore()
+	m.path = path
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if m.dag, err = model.ReadBinary(bufio.NewReader(f)); err != nil {
+		return nil, fmt.Errorf("read snapshot %s: %w", path, err)
+	}
+
+	return m, nil
+}
+
+// Save writes the graph to the binary snapshot at the path of
+// OpenMemoryStore. The snapshot is replaced atomically, and the store can be
+// used while it is written.
+func (m *MemoryStore) Save() error {
+	if m.path == "" {
+		return fmt.Errorf("store is not opened with a snapshot path")
+	}
+
+	graph, err := m.Get()
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(m.path), filepath.Base(m.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	if err := graph.WriteBinary(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), m.path)
+}
+
+// graph returns the graph, creating it for the zero value. The lock must be
+// held for writing if the graph may not exist yet.
+func (m *MemoryStore) graph() *model.DAG {
+	if m.dag == nil {
+		m.dag = model.NewDAG()
+	}
+
+	return m.dag
+}
+
+// Get returns a snapshot of the graph, which is not modified by the later
+// mutations of the store.
+func (m *MemoryStore) Get() (*model.DAG, error) {
+	// Taking a snapshot modifies the graph.
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.graph().Snapshot(), nil
+}
+
+// View returns a snapshot of the graph, whose vertices must not be modified.
+// It does not need to be released.
+func (m *MemoryStore) View() (model.VertexGetter, func(), error) {
+	graph, err := m.Get()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return graph, func() {}, nil
+}
+
+// GetVertexByPosition returns the vertex at the position in order of ID.
+func (m *MemoryStore) GetVertexByPosition(position int) (*model.Vertex, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	graph := m.graph()
+	if m.ids == nil || m.idsVersion != graph.Version() {
+		m.ids = m.ids[:0]
+		for id := range graph.Vertices() {
+			m.ids = append(m.ids, id)
+		}
+		sort.Strings(m.ids)
+		m.idsVersion = graph.Version()
+	}
+
+	if position < 0 || position >= len(m.ids) {
+		return nil, fmt.Errorf("vertex at position %v does not exist", position)
+	}
+
+	v, err := graph.GetVertex(m.ids[position])
+	if err != nil {
+		return nil, err
+	}
+
+	return v.Clone(), nil
+}
+
+// Insert replaces the graph with a snapshot of g, so the later mutations of
+// g do not modify the store.
+func (m *MemoryStore) Insert(g *model.DAG) error {
+	m.mu.Lock()
+	m.dag = g.Snapshot()
+	m.ids = nil
+	m.mu.Unlock()
+
+	m.observers.Emit(model.GraphReplaced{Graph: g})
+
+	return nil
+}
+
+// Walk calls fn with the vertices of a snapshot of the graph, in order of ID.
+func (m *MemoryStore) Walk(fn func(v *model.Vertex) error) error {
+	graph, err := m.Get()
+	if err != nil {
+		return err
+	}
+
+	vertices := graph.Vertices()
+	ids := make([]string, 0, len(vertices))
+	for id := range vertices {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if err := fn(vertices[id]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Apply validates the batch and applies it to the graph. The events are
+// emitted once the store is unlocked, so the observers can query it, with
+// copies of the vertices as they were after each operation.
+func (m *MemoryStore) Apply(b *model.Batch) error {
+	// The graph keeps the vertices added, so they are copied.
+	copied := model.NewBatch()
+	for _, op := range b.Ops() {
+		if op.Type == model.OpAddVertex {
+			op.Vertex = op.Vertex.Clone()
+		}
+		copied.Append(op)
+	}
+
+	var events []model.Event
+
+	m.mu.Lock()
+	graph := m.graph()
+	unsubscribe := graph.Subscribe(model.ObserverFunc(func(e model.Event) {
+		events = append(events, cloneEvent(e))
+	}))
+	err := graph.Apply(copied)
+	unsubscribe()
+	m.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		m.observers.Emit(e)
+	}
+
+	return nil
+}
+
+// cloneEvent returns a copy of the event of the graph, with copies of its
+// vertices, which the observers may keep or modify.
+func cloneEvent(e model.Event) model.Event {
+	switch e := e.(type) {
+	case model.VertexAdded:
+		return model.VertexAdded{Vertex: e.Vertex.Clone()}
+	case model.VertexDeleted:
+		return model.VertexDeleted{Vertex: e.Vertex.Clone()}
+	case model.VertexUpdated:
+		return model.VertexUpdated{Old: e.Old, Vertex: e.Vertex.Clone()}
+	case model.EdgeAdded:
+		return model.EdgeAdded{Parent: e.Parent.Clone(), Child: e.Child.Clone()}
+	case model.EdgeDeleted:
+		return model.EdgeDeleted{Parent: e.Parent.Clone(), Child: e.Child.Clone()}
+	}
+
+	return e
+}
+
+func (m *MemoryStore) GetVertex(id string) (*model.Vertex, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.dag == nil {
+		return nil, fmt.Errorf("%w: %s", model.ErrVertexNotFound, id)
+	}
+
+	v, err := m.dag.GetVertex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.Clone(), nil
+}
+
+func (m *MemoryStore) AddVertex(v *model.Vertex) error {
+	batch := model.NewBatch()
+	batch.AddVertex(v)
+
+	return store.OpError(m.Apply(batch))
+}
+
+func (m *MemoryStore) UpdateVertex(v *model.Vertex) error {
+	batch := model.NewBatch()
+	batch.UpdateVertex(v)
+
+	return store.OpError(m.Apply(batch))
+}
+
+func (m *MemoryStore) DeleteVertex(id string) error {
+	batch := model.NewBatch()
+	batch.DeleteVertex(id)
+
+	return store.OpError(m.Apply(batch))
+}
+
+func (m *MemoryStore) AddEdge(parent, child string) error {
+	batch := model.NewBatch()
+	batch.AddEdge(parent, child)
+
+	return store.OpError(m.Apply(batch))
+}
+
+func (m *MemoryStore) DeleteEdge(parent, child string) error {
+	batch := model.NewBatch()
+	batch.DeleteEdge(parent, child)
+
+	return store.OpError(m.Apply(batch))
+}
+
+func (m *MemoryStore) Subscribe(o model.Observer) func() {
+	return m.observers.Subscribe(o)
+}
+
+// ancestors returns the ancestors of the vertex with the algorithm, like the
+// other stores. The lock must be held for reading.
+func (m *MemoryStore) ancestors(algo store.Algo, id string, filter func(*model.Vertex) bool) ([]*model.Vertex, error) {
+	if m.dag == nil {
+		return nil, fmt.Errorf("%w: %s", model.ErrVertexNotFound, id)
+	}
+	if _, err := m.dag.GetVertex(id); err != nil {
+		return nil, err
+	}
+
+	if algo == store.ALGO_DFS {
+		return m.dag.AncestorsDFS(id, filter), nil
+	}
+
+	return m.dag.AncestorsBFS(id, filter), nil
+}
+
+func flagFilter(flag bool) func(*model.Vertex) bool {
+	return func(v *model.Vertex) bool {
+		return v.Flag == flag
+	}
+}
+
+func (m *MemoryStore) Reach(algo store.Algo, id string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list, err := m.ancestors(algo, id, nil)
+
+	return len(list), err
+}
+
+func (m *MemoryStore) ConditionalReach(algo store.Algo, id string, flag bool) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list, err := m.ancestors(algo, id, flagFilter(flag))
+
+	return len(list), err
+}
+
+func (m *MemoryStore) List(algo store.Algo, id string) ([]*model.Vertex, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list, err := m.ancestors(algo, id, nil)
+
+	return cloneVertices(list), err
+}
+
+func (m *MemoryStore) ConditionalList(algo store.Algo, id string, flag bool) ([]*model.Vertex, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list, err := m.ancestors(algo, id, flagFilter(flag))
+
+	return cloneVertices(list), err
+}
+
+func (m *MemoryStore) MustPassAncestors(id string) ([]*model.Vertex, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.dag == nil {
+		return nil, fmt.Errorf("%w: %s", model.ErrVertexNotFound, id)
+	}
+
+	list, err := m.dag.MustPassAncestors(id)
+
+	return cloneVertices(list), err
+}
+
+func (m *MemoryStore) Stats() (*model.Stats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.dag == nil {
+		return model.NewDAG().Stats()
+	}
+
+	return m.dag.Stats()
+}
+
+func cloneVertices(list []*model.Vertex) []*model.Vertex {
+	for i, v := range list {
+		list[i] = v.Clone()
+	}
+
+	return list
+}
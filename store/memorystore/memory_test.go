@@ -0,0 +1,321 @@
+package memorystore
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/ahmadmuzakkir/dag/store"
+	"github.com/ahmadmuzakkir/dag/store/storetest"
+)
+
+func TestQueries(t *testing.T) {
+	graph := model.GenerateGraph(2000)
+
+	ds := NewMemoryStore()
+	if err := ds.Insert(graph); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]string, 0, graph.CountVertex())
+	for id := range graph.Vertices() {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for i := 0; i < len(ids); i += 97 {
+		id := ids[i]
+
+		v, err := ds.GetVertexByPosition(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.ID != id {
+			t.Fatalf("expected vertex %s at position %d, found %s", id, i, v.ID)
+		}
+
+		bfs := graph.AncestorsBFS(id, nil)
+		dfs := graph.AncestorsDFS(id, nil)
+		flagged := graph.AncestorsBFS(id, func(v *model.Vertex) bool {
+			return v.Flag
+		})
+
+		if n, err := ds.Reach(store.ALGO_BFS, id); err != nil || n != len(bfs) {
+			t.Fatalf("expected BFS reach %d, found %d, %v", len(bfs), n, err)
+		}
+		if n, err := ds.Reach(store.ALGO_DFS, id); err != nil || n != len(dfs) {
+			t.Fatalf("expected DFS reach %d, found %d, %v", len(dfs), n, err)
+		}
+		if n, err := ds.ConditionalReach(store.ALGO_BFS, id, true); err != nil || n != len(flagged) {
+			t.Fatalf("expected conditional reach %d, found %d, %v", len(flagged), n, err)
+		}
+
+		list, err := ds.List(store.ALGO_DFS, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(list, dfs) {
+			t.Fatalf("expected the DFS list of %s to be the ancestors of the graph", id)
+		}
+
+		list, err = ds.ConditionalList(store.ALGO_BFS, id, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != len(flagged) {
+			t.Fatalf("expected %d flagged ancestors, found %d", len(flagged), len(list))
+		}
+	}
+
+	if _, err := ds.GetVertexByPosition(len(ids)); err == nil {
+		t.Fatal("expected a position error")
+	}
+
+	for _, algo := range []store.Algo{store.ALGO_BFS, store.ALGO_DFS} {
+		if _, err := ds.Reach(algo, "missing"); !errors.Is(err, model.ErrVertexNotFound) {
+			t.Fatalf("expected ErrVertexNotFound, found %v", err)
+		}
+	}
+	if _, err := ds.MustPassAncestors("missing"); !errors.Is(err, model.ErrVertexNotFound) {
+		t.Fatalf("expected ErrVertexNotFound, found %v", err)
+	}
+
+	stats, err := ds.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Vertices != graph.CountVertex() {
+		t.Fatalf("expected %d vertices, found %d", graph.CountVertex(), stats.Vertices)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.GraphStore {
+		return NewMemoryStore()
+	})
+}
+
+func TestIsolation(t *testing.T) {
+	// The zero value is an empty store.
+	var ds DataMock
+
+	a := model.NewVertex("a", false, 0)
+	if err := ds.AddVertex(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.AddVertex(model.NewVertex("b", false, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.AddEdge("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.AddEdge("b", "a"); err == nil {
+		t.Fatal("expected a cycle error")
+	}
+
+	// The vertices given to and returned by the store are copies.
+	a.Flag = true
+	v, err := ds.GetVertex("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Flag {
+		t.Fatal("the added vertex must be copied")
+	}
+	v.Children["c"] = struct{}{}
+
+	graph, err := ds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.DeleteEdge("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if graph.CountEdge() != 1 {
+		t.Fatal("the graph of Get must not be modified by the store")
+	}
+
+	if err := graph.DeleteVertex(model.NewVertex("b", false, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.GetVertex("b"); err != nil {
+		t.Fatal("the store must not be modified by the graph of Get")
+	}
+
+	a, err = ds.GetVertex("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Children) != 0 {
+		t.Fatalf("unexpected children %v", a.Children)
+	}
+}
+
+func TestEventIsolation(t *testing.T) {
+	ds := NewMemoryStore()
+
+	// The vertices of the events are copies, which the observers may modify.
+	defer ds.Subscribe(model.ObserverFunc(func(e model.Event) {
+		switch e := e.(type) {
+		case model.VertexAdded:
+			e.Vertex.Flag = true
+			e.Vertex.Children["c"] = struct{}{}
+		case model.VertexUpdated:
+			e.Vertex.Rank = 10
+		case model.EdgeAdded:
+			e.Parent.Properties = map[string]string{"k": "v"}
+			delete(e.Child.Parents, e.Parent.ID)
+		}
+	}))()
+
+	if err := ds.AddVertex(model.NewVertex("a", false, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.AddVertex(model.NewVertex("b", false, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.AddEdge("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.UpdateVertex(model.NewVertex("b", false, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := ds.GetVertex("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Flag || a.Properties != nil || !reflect.DeepEqual(a.Children, map[string]struct{}{"b": {}}) {
+		t.Fatalf("the store must not be modified by the events, found %v", a)
+	}
+
+	b, err := ds.GetVertex("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Rank != 2 || !reflect.DeepEqual(b.Parents, map[string]struct{}{"a": {}}) {
+		t.Fatalf("the store must not be modified by the events, found %v", b)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	ds := NewMemoryStore()
+	if err := ds.Insert(model.GenerateGraph(500)); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := ds.GetVertexByPosition(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The observers can query the store.
+	defer ds.Subscribe(model.ObserverFunc(func(e model.Event) {
+		if added, ok := e.(model.VertexAdded); ok {
+			if _, err := ds.GetVertex(added.Vertex.ID); err != nil {
+				t.Error(err)
+			}
+		}
+	}))()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < 50; i++ {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				if err := ds.AddVertex(model.NewVertex(id, i%2 == 0, 0)); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := ds.AddEdge(root.ID, id); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 50; i++ {
+				if _, err := ds.List(store.ALGO_BFS, root.ID); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := ds.Get(); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := ds.GetVertexByPosition(i); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	v, err := ds.GetVertex(root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Children) < 200 {
+		t.Fatalf("expected at least 200 children, found %d", len(v.Children))
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.dagb")
+
+	ds, err := OpenMemoryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := model.GenerateGraph(300)
+	if err := ds.Insert(expected); err != nil {
+		t.Fatal(err)
+	}
+	v, err := ds.GetVertexByPosition(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Flag = !v.Flag
+	v.Properties = map[string]string{"name": "updated"}
+	if err := ds.UpdateVertex(v); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := OpenMemoryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err := ds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := loaded.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(found.Vertices(), graph.Vertices()) {
+		t.Fatal("expected the loaded graph to be the saved graph")
+	}
+
+	if err := NewMemoryStore().Save(); err == nil {
+		t.Fatal("expected an error without a snapshot path")
+	}
+}
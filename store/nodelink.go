@@ -0,0 +1,75 @@
+package store
+
+import (
+	"io"
+	"sort"
+
+	"github.com/ahmadmuzakkir/dag/model"
+)
+
+// ExportNodeLink writes the stored graph in the node-link JSON format of
+// model.NodeLinkEncoder. The store is walked twice, once for the vertices and
+// once for the edges, so the graph is never loaded in memory. The edges of a
+// vertex are written in order of child ID.
+func ExportNodeLink(ds GraphStore, w io.Writer) error {
+	e := model.NewNodeLinkEncoder(w)
+
+	err := ds.Walk(func(v *model.Vertex) error {
+		return e.WriteVertex(v)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ds.Walk(func(v *model.Vertex) error {
+		children := make([]string, 0, len(v.Children))
+		for c := range v.Children {
+			children = append(children, c)
+		}
+		sort.Strings(children)
+
+		for _, c := range children {
+			if err := e.WriteEdge(v.ID, c); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return e.Close()
+}
+
+// ImportNodeLink reads a graph in the node-link JSON format into the store,
+// replacing the existing graph. The graph is written in batches of batchSize
+// mutations as it is decoded. If the input is invalid, the batches written
+// before the error are kept.
+func ImportNodeLink(ds GraphStore, r io.Reader, batchSize int) error {
+	if err := ds.Insert(model.NewDAG()); err != nil {
+		return err
+	}
+
+	w := NewBatchWriter(ds, batchSize)
+
+	if err := model.DecodeNodeLink(r, batchWriterHandler{w}); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// batchWriterHandler writes the decoded elements with a BatchWriter.
+type batchWriterHandler struct {
+	w *BatchWriter
+}
+
+func (h batchWriterHandler) Vertex(v *model.Vertex) error {
+	return h.w.AddVertex(v)
+}
+
+func (h batchWriterHandler) Edge(parent, child string) error {
+	return h.w.AddEdge(parent, child)
+}
@@ -0,0 +1,592 @@
+package sqlitestore
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/ahmadmuzakkir/dag/store"
+)
+
+var (
+	_ store.GraphStore = (*SQLiteStore)(nil)
+	_ store.Viewer     = (*SQLiteStore)(nil)
+)
+
+// Schema creates the tables of the graph, so it can also be queried with
+// plain SQL. A vertex is a row of vertices, with its properties as a JSON
+// object, or NULL if it does not have any. An edge is a row of edges.
+const Schema = `
+CREATE TABLE IF NOT EXISTS vertices (
+	id TEXT NOT NULL PRIMARY KEY,
+	flag INTEGER NOT NULL,
+	rank INTEGER NOT NULL,
+	idx INTEGER NOT NULL,
+	properties TEXT
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS edges (
+	parent TEXT NOT NULL,
+	child TEXT NOT NULL,
+	PRIMARY KEY (child, parent)
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS edges_parent ON edges (parent, child);
+`
+
+// vertexColumns selects a vertex of the table v, with the JSON arrays of its
+// parents and children.
+const vertexColumns = `v.id, v.flag, v.rank, v.idx, v.properties,
+	(SELECT json_group_array(e.parent) FROM edges e WHERE e.child = v.id),
+	(SELECT json_group_array(e.child) FROM edges e WHERE e.parent = v.id)`
+
+// The ancestors of a vertex, without the vertex itself for BFS, and with it
+// for DFS, like the other stores. UNION discards the vertices already
+// reached, so every vertex is visited once.
+const (
+	ancestorsBFS = `WITH RECURSIVE ancestors(id) AS (
+		SELECT parent FROM edges WHERE child = ?
+		UNION
+		SELECT e.parent FROM edges e JOIN ancestors a ON e.child = a.id
+	)`
+
+	ancestorsDFS = `WITH RECURSIVE ancestors(id) AS (
+		SELECT ?
+		UNION
+		SELECT e.parent FROM edges e JOIN ancestors a ON e.child = a.id
+	)`
+)
+
+// SQLiteStore is a GraphStore which keeps the graph in the tables of Schema.
+// The queries of the ancestors are recursive common table expressions.
+//
+// SQLite walks the ancestors in the order they are reached for both
+// algorithms; the algorithm only decides whether the vertex itself is
+// counted.
+type SQLiteStore struct {
+	db *sql.DB
+
+	// The transactions of the mutations read the graph before they write
+	// it, so they are serialized.
+	mu sync.Mutex
+
+	observers model.Observers
+}
+
+// NewSQLiteStore returns a store of the database, and creates the tables of
+// Schema if they do not exist.
+func NewSQLiteStore(db *sql.DB) (*SQLiteStore, error) {
+	if _, err := db.Exec(Schema); err != nil {
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+
+	return &SQLiteStore{
+		db: db,
+	}, nil
+}
+
+func (s *SQLiteStore) Get() (*model.DAG, error) {
+	graph := model.NewDAG()
+
+	err := s.view(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT ` + vertexColumns + ` FROM vertices v`)
+		if err != nil {
+			return err
+		}
+
+		return scanVertices(rows, func(v *model.Vertex) error {
+			graph.AddVertex(v)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return graph, nil
+}
+
+func (s *SQLiteStore) GetVertexByPosition(position int) (*model.Vertex, error) {
+	// SQLite treats a negative offset as 0.
+	if position < 0 {
+		return nil, fmt.Errorf("vertex at position %v does not exist", position)
+	}
+
+	var vertex *model.Vertex
+	err := s.view(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT `+vertexColumns+` FROM vertices v ORDER BY v.id LIMIT 1 OFFSET ?`, position)
+		if err != nil {
+			return err
+		}
+
+		return scanVertices(rows, func(v *model.Vertex) error {
+			vertex = v
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if vertex == nil {
+		return nil, fmt.Errorf("vertex at position %v does not exist", position)
+	}
+
+	return vertex, nil
+}
+
+func (s *SQLiteStore) Insert(g *model.DAG) error {
+	err := s.update(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM edges; DELETE FROM vertices`); err != nil {
+			return err
+		}
+
+		w, err := prepareWriter(tx)
+		if err != nil {
+			return err
+		}
+		defer w.close()
+
+		for id, v := range g.Vertices() {
+			if err := w.put(id, nil, v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.observers.Emit(model.GraphReplaced{Graph: g})
+
+	return nil
+}
+
+// Walk calls fn with the vertices in order of ID. The vertices are read one
+// at a time.
+func (s *SQLiteStore) Walk(fn func(v *model.Vertex) error) error {
+	return s.view(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT ` + vertexColumns + ` FROM vertices v ORDER BY v.id`)
+		if err != nil {
+			return err
+		}
+
+		return scanVertices(rows, fn)
+	})
+}
+
+// Apply validates the batch against the stored graph and applies it in a single transaction.
+func (s *SQLiteStore) Apply(batch *model.Batch) error {
+	var changes *model.Changes
+
+	err := s.update(func(tx *sql.Tx) error {
+		// The vertices read, to write only their changed edges.
+		old := make(map[string]*model.Vertex)
+		var err error
+		changes, err = batch.Stage(model.VertexGetterFunc(func(id string) (*model.Vertex, error) {
+			v, err := getByID(tx, id)
+			if err == nil {
+				old[id] = v
+			}
+			return v, err
+		}))
+		if err != nil {
+			return err
+		}
+
+		w, err := prepareWriter(tx)
+		if err != nil {
+			return err
+		}
+		defer w.close()
+
+		for id, v := range changes.Vertices {
+			if err := w.put(id, old[id], v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range changes.Events {
+		s.observers.Emit(e)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) GetVertex(id string) (*model.Vertex, error) {
+	var vertex *model.Vertex
+	err := s.view(func(tx *sql.Tx) error {
+		var err error
+		vertex, err = getByID(tx, id)
+		return err
+	})
+
+	return vertex, err
+}
+
+// View returns a getter of the vertices of a transaction, which is rolled
+// back by the release function.
+func (s *SQLiteStore) View() (model.VertexGetter, func(), error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	get := model.VertexGetterFunc(func(id string) (*model.Vertex, error) {
+		return getByID(tx, id)
+	})
+
+	return get, func() { tx.Rollback() }, nil
+}
+
+func (s *SQLiteStore) AddVertex(v *model.Vertex) error {
+	batch := model.NewBatch()
+	batch.AddVertex(v)
+
+	return store.OpError(s.Apply(batch))
+}
+
+func (s *SQLiteStore) UpdateVertex(v *model.Vertex) error {
+	batch := model.NewBatch()
+	batch.UpdateVertex(v)
+
+	return store.OpError(s.Apply(batch))
+}
+
+func (s *SQLiteStore) DeleteVertex(id string) error {
+	batch := model.NewBatch()
+	batch.DeleteVertex(id)
+
+	return store.OpError(s.Apply(batch))
+}
+
+func (s *SQLiteStore) AddEdge(parent, child string) error {
+	batch := model.NewBatch()
+	batch.AddEdge(parent, child)
+
+	return store.OpError(s.Apply(batch))
+}
+
+func (s *SQLiteStore) DeleteEdge(parent, child string) error {
+	batch := model.NewBatch()
+	batch.DeleteEdge(parent, child)
+
+	return store.OpError(s.Apply(batch))
+}
+
+func (s *SQLiteStore) Subscribe(o model.Observer) func() {
+	return s.observers.Subscribe(o)
+}
+
+func ancestorsQuery(algo store.Algo) string {
+	if algo == store.ALGO_DFS {
+		return ancestorsDFS
+	}
+
+	return ancestorsBFS
+}
+
+func (s *SQLiteStore) Reach(algo store.Algo, id string) (int, error) {
+	return s.count(ancestorsQuery(algo)+` SELECT COUNT(*) FROM ancestors`, id)
+}
+
+func (s *SQLiteStore) ConditionalReach(algo store.Algo, id string, flag bool) (int, error) {
+	return s.count(ancestorsQuery(algo)+`
+		SELECT COUNT(*) FROM ancestors a JOIN vertices v ON v.id = a.id WHERE v.flag = ?`, id, flag)
+}
+
+func (s *SQLiteStore) List(algo store.Algo, id string) ([]*model.Vertex, error) {
+	return s.list(ancestorsQuery(algo)+`
+		SELECT `+vertexColumns+` FROM ancestors a JOIN vertices v ON v.id = a.id`, id)
+}
+
+func (s *SQLiteStore) ConditionalList(algo store.Algo, id string, flag bool) ([]*model.Vertex, error) {
+	return s.list(ancestorsQuery(algo)+`
+		SELECT `+vertexColumns+` FROM ancestors a JOIN vertices v ON v.id = a.id WHERE v.flag = ?`, id, flag)
+}
+
+func (s *SQLiteStore) MustPassAncestors(id string) ([]*model.Vertex, error) {
+	var vertex *model.Vertex
+	var ancestors []*model.Vertex
+
+	err := s.view(func(tx *sql.Tx) error {
+		var err error
+		if vertex, err = getByID(tx, id); err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(ancestorsBFS+`
+			SELECT `+vertexColumns+` FROM ancestors a JOIN vertices v ON v.id = a.id`, id)
+		if err != nil {
+			return err
+		}
+
+		return scanVertices(rows, func(v *model.Vertex) error {
+			ancestors = append(ancestors, v)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return model.MustPassAncestors(vertex, ancestors)
+}
+
+func (s *SQLiteStore) Stats() (*model.Stats, error) {
+	graph, err := s.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	return graph.Stats()
+}
+
+// count returns the number of a query of the ancestors of the vertex. The
+// first argument is the ID of the vertex.
+func (s *SQLiteStore) count(query string, args ...interface{}) (int, error) {
+	var n int
+	err := s.view(func(tx *sql.Tx) error {
+		if err := exists(tx, args[0].(string)); err != nil {
+			return err
+		}
+
+		return tx.QueryRow(query, args...).Scan(&n)
+	})
+
+	return n, err
+}
+
+// list returns the vertices of a query of the ancestors of the vertex. The
+// first argument is the ID of the vertex.
+func (s *SQLiteStore) list(query string, args ...interface{}) ([]*model.Vertex, error) {
+	var list []*model.Vertex
+	err := s.view(func(tx *sql.Tx) error {
+		if err := exists(tx, args[0].(string)); err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(query, args...)
+		if err != nil {
+			return err
+		}
+
+		return scanVertices(rows, func(v *model.Vertex) error {
+			list = append(list, v)
+			return nil
+		})
+	})
+
+	return list, err
+}
+
+// view runs fn in a transaction, which is rolled back, so the queries of fn
+// read the same graph.
+func (s *SQLiteStore) view(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return fn(tx)
+}
+
+// update runs fn in a transaction, which is committed if fn succeeds.
+func (s *SQLiteStore) update(fn func(tx *sql.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func exists(tx *sql.Tx, id string) error {
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM vertices WHERE id = ?`, id).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", model.ErrVertexNotFound, id)
+	}
+
+	return nil
+}
+
+func getByID(tx *sql.Tx, id string) (*model.Vertex, error) {
+	rows, err := tx.Query(`SELECT `+vertexColumns+` FROM vertices v WHERE v.id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var vertex *model.Vertex
+	err = scanVertices(rows, func(v *model.Vertex) error {
+		vertex = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if vertex == nil {
+		return nil, fmt.Errorf("%w: %s", model.ErrVertexNotFound, id)
+	}
+
+	return vertex, nil
+}
+
+// scanVertices calls fn with the vertices of the rows of vertexColumns, and
+// closes the rows.
+func scanVertices(rows *sql.Rows, fn func(v *model.Vertex) error) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var flag bool
+		var rank, index int
+		var properties sql.NullString
+		var parents, children string
+
+		if err := rows.Scan(&id, &flag, &rank, &index, &properties, &parents, &children); err != nil {
+			return err
+		}
+
+		v := model.NewVertex(id, flag, rank)
+		v.Index = index
+
+		if err := unmarshalIDs(parents, v.Parents); err != nil {
+			return fmt.Errorf("parents of vertex %s: %w", id, err)
+		}
+		if err := unmarshalIDs(children, v.Children); err != nil {
+			return fmt.Errorf("children of vertex %s: %w", id, err)
+		}
+		if properties.Valid {
+			if err := json.Unmarshal([]byte(properties.String), &v.Properties); err != nil {
+				return fmt.Errorf("properties of vertex %s: %w", id, err)
+			}
+		}
+
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func unmarshalIDs(data string, ids map[string]struct{}) error {
+	var list []string
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		return err
+	}
+
+	for _, id := range list {
+		ids[id] = struct{}{}
+	}
+
+	return nil
+}
+
+// writer writes the vertices in a transaction with prepared statements.
+type writer struct {
+	putVertex, deleteVertex, putEdge, deleteEdge *sql.Stmt
+}
+
+func prepareWriter(tx *sql.Tx) (*writer, error) {
+	w := &writer{}
+
+	statements := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&w.putVertex, `INSERT OR REPLACE INTO vertices (id, flag, rank, idx, properties) VALUES (?, ?, ?, ?, ?)`},
+		{&w.deleteVertex, `DELETE FROM vertices WHERE id = ?`},
+		{&w.putEdge, `INSERT OR IGNORE INTO edges (parent, child) VALUES (?, ?)`},
+		{&w.deleteEdge, `DELETE FROM edges WHERE parent = ? AND child = ?`},
+	}
+
+	for _, s := range statements {
+		stmt, err := tx.Prepare(s.query)
+		if err != nil {
+			w.close()
+			return nil, err
+		}
+		*s.stmt = stmt
+	}
+
+	return w, nil
+}
+
+// put writes the vertex with the ID, inserts the edges of the vertex which old
+// does not have, and deletes the edges of old which the vertex does not have.
+// Both its parents and its children are written, an edge being inserted once.
+// old is nil for a new vertex, and the vertex is nil if it is deleted.
+func (w *writer) put(id string, old, v *model.Vertex) error {
+	if v == nil {
+		if _, err := w.deleteVertex.Exec(id); err != nil {
+			return err
+		}
+	} else {
+		var properties sql.NullString
+		if v.Properties != nil {
+			data, err := json.Marshal(v.Properties)
+			if err != nil {
+				return err
+			}
+			properties = sql.NullString{String: string(data), Valid: true}
+		}
+
+		if _, err := w.putVertex.Exec(v.ID, v.Flag, v.Rank, v.Index, properties); err != nil {
+			return err
+		}
+	}
+
+	return store.DiffEdgeKeys(old, v, func(key []byte) error {
+		return w.execEdge(w.putEdge, key)
+	}, func(key []byte) error {
+		return w.execEdge(w.deleteEdge, key)
+	})
+}
+
+// execEdge executes the statement with the parent and child of the edge key.
+func (w *writer) execEdge(stmt *sql.Stmt, key []byte) error {
+	parent, child, err := store.ParseEdgeKey(key)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(key, store.ParentsKeyPrefix) {
+		parent, child = child, parent
+	}
+
+	_, err = stmt.Exec(parent, child)
+
+	return err
+}
+
+func (w *writer) close() {
+	for _, stmt := range []*sql.Stmt{w.putVertex, w.deleteVertex, w.putEdge, w.deleteEdge} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/ahmadmuzakkir/dag/store"
+	"github.com/ahmadmuzakkir/dag/store/storetest"
+	_ "modernc.org/sqlite"
+)
+
+func openSQLiteDataStore(t *testing.T) *SQLiteStore {
+	path := filepath.Join(t.TempDir(), "graph.db")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(10000)&_pragma=journal_mode(wal)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ds, err := NewSQLiteStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ds
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.GraphStore {
+		return openSQLiteDataStore(t)
+	})
+}
+
+// The graph can be queried with plain SQL.
+func TestSQL(t *testing.T) {
+	ds := openSQLiteDataStore(t)
+
+	graph := storetest.KnownGraph()
+	if err := ds.Insert(graph); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ds.db.Query(`
+		SELECT v.id, json_extract(v.properties, '$.name')
+		FROM edges e JOIN vertices v ON v.id = e.parent
+		WHERE e.child = 'b with space'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var found []string
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			t.Fatal(err)
+		}
+		found = append(found, id+" "+name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0] != "a A" {
+		t.Fatalf("expected the parent a, found %v", found)
+	}
+
+	var flagged int
+	err = ds.db.QueryRow(ancestorsDFS+`
+		SELECT COUNT(*) FROM ancestors a JOIN vertices v ON v.id = a.id WHERE v.flag`, `dé/"quoted"`).Scan(&flagged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flagged != 2 {
+		t.Fatalf("expected 2 flagged ancestors, found %d", flagged)
+	}
+
+	for _, position := range []int{-1, graph.CountVertex()} {
+		if v, err := ds.GetVertexByPosition(position); err == nil {
+			t.Fatalf("expected no vertex at position %d, found %s", position, v.ID)
+		}
+	}
+
+	// A schema which already exists is kept.
+	if _, err := NewSQLiteStore(ds.db); err != nil {
+		t.Fatal(err)
+	}
+	list, err := ds.List(store.ALGO_BFS, `dé/"quoted"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(list))
+	for _, v := range list {
+		ids = append(ids, v.ID)
+	}
+	sort.Strings(ids)
+	if len(ids) != 3 || ids[0] != model.HexID(1, 3) || ids[1] != "a" || ids[2] != "b with space" {
+		t.Fatalf("unexpected ancestors %v", ids)
+	}
+}
+
+// The edges are written from both sides, and only the changed ones.
+func TestEdges(t *testing.T) {
+	ds := openSQLiteDataStore(t)
+
+	// The edges of the hub are only recorded among the parents of the
+	// children.
+	graph := model.NewDAG()
+	hub := model.NewVertex("hub", false, 0)
+	graph.AddVertex(hub)
+	for i := 0; i < 100; i++ {
+		v := model.NewVertex(fmt.Sprintf("child-%d", i), false, 1)
+		v.Parents["hub"] = struct{}{}
+		graph.AddVertex(v)
+	}
+	if err := ds.Insert(graph); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := ds.GetVertex("hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Children) != 100 {
+		t.Fatalf("expected 100 children, found %d", len(v.Children))
+	}
+
+	_, err = ds.db.Exec(`
+		CREATE TABLE edge_writes (n INTEGER);
+		CREATE TRIGGER edge_inserted AFTER INSERT ON edges BEGIN INSERT INTO edge_writes VALUES (1); END;
+		CREATE TRIGGER edge_deleted AFTER DELETE ON edges BEGIN INSERT INTO edge_writes VALUES (1); END;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ds.AddVertex(model.NewVertex("new", false, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.AddEdge("hub", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.DeleteEdge("hub", "child-0"); err != nil {
+		t.Fatal(err)
+	}
+
+	var writes int
+	if err := ds.db.QueryRow(`SELECT COUNT(*) FROM edge_writes`).Scan(&writes); err != nil {
+		t.Fatal(err)
+	}
+	if writes != 2 {
+		t.Fatalf("expected 2 edge writes, found %d", writes)
+	}
+
+	v, err = ds.GetVertex("hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.Children["new"]; !ok || len(v.Children) != 100 {
+		t.Fatalf("expected 100 children with new, found %d", len(v.Children))
+	}
+}
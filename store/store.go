@@ -1,6 +1,10 @@
 package store
 
-import "github.com/ahmadmuzakkir/dag/model"
+import (
+	"errors"
+
+	"github.com/ahmadmuzakkir/dag/model"
+)
 
 type GraphStore interface {
 	Get() (*model.DAG, error)
@@ -11,6 +15,29 @@ type GraphStore interface {
 	// Insert will clear existing graph first, before inserting the new graph
 	Insert(g *model.DAG) error
 
+	// Walk calls fn with every vertex, one at a time, without loading the
+	// whole graph. It stops at the first error of fn and returns it.
+	// fn must not modify the store.
+	Walk(fn func(v *model.Vertex) error) error
+
+	// Apply validates the batch against the stored graph and applies it in
+	// a single transaction. If the batch is invalid, nothing is applied.
+	Apply(b *model.Batch) error
+
+	// GetVertex returns the vertex with the ID, or an error wrapping
+	// model.ErrVertexNotFound.
+	GetVertex(id string) (*model.Vertex, error)
+
+	// AddVertex, UpdateVertex, DeleteVertex, AddEdge and DeleteEdge apply a
+	// single mutation in a transaction, like the operations of model.Batch.
+	// The adjacency of both vertices of an edge is updated, and an edge
+	// which creates a cycle is rejected.
+	AddVertex(v *model.Vertex) error
+	UpdateVertex(v *model.Vertex) error
+	DeleteVertex(id string) error
+	AddEdge(parent, child string) error
+	DeleteEdge(parent, child string) error
+
 	Reach(algo Algo, id string) (int, error)
 
 	ConditionalReach(algo Algo, id string, flag bool) (int, error)
@@ -18,6 +45,27 @@ type GraphStore interface {
 	List(algo Algo, id string) ([]*model.Vertex, error)
 
 	ConditionalList(algo Algo, id string, flag bool) ([]*model.Vertex, error)
+
+	// MustPassAncestors returns the ancestors of the vertex which every path
+	// to the vertex passes through, from the nearest one.
+	MustPassAncestors(id string) ([]*model.Vertex, error)
+
+	// Stats computes the statistics of the stored graph.
+	Stats() (*model.Stats, error)
+
+	// Subscribe registers an observer for the mutations of the store.
+	// The returned function unsubscribes it.
+	Subscribe(o model.Observer) func()
+}
+
+// Viewer is implemented by the stores which read the vertices of a read
+// transaction or snapshot, so that many lookups, e.g. validating the rows of
+// an import, share one transaction rather than opening one each.
+type Viewer interface {
+	// View returns a getter of the vertices of a consistent view of the
+	// store, and the function which releases it. The view must be released
+	// before the store is modified by the same goroutine.
+	View() (model.VertexGetter, func(), error)
 }
 
 type Algo int
@@ -26,3 +74,15 @@ const (
 	ALGO_BFS Algo = iota
 	ALGO_DFS
 )
+
+// OpError returns the error of the operation of a batch of a single
+// operation, rather than the *model.BatchError, for the single mutations of
+// the stores.
+func OpError(err error) error {
+	var batchErr *model.BatchError
+	if errors.As(err, &batchErr) {
+		return batchErr.Err
+	}
+
+	return err
+}
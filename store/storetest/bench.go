@@ -0,0 +1,160 @@
+package storetest
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/ahmadmuzakkir/dag/store"
+)
+
+// BenchGraphSize is the number of vertices of the graph of the query
+// benchmarks.
+const BenchGraphSize = 100000
+
+const benchTopologySize = 10000
+
+// InsertBenchGraph replaces the content of the store with a generated graph of
+// BenchGraphSize vertices, for BenchmarkQuery. It takes a while, so the
+// packages insert it once, into a store kept between the runs.
+func InsertBenchGraph(ds store.GraphStore) error {
+	return ds.Insert(model.GenerateGraph(BenchGraphSize))
+}
+
+// CheckBenchGraph checks the number of vertices and edges of the graph of
+// InsertBenchGraph.
+func CheckBenchGraph(t *testing.T, ds store.GraphStore) {
+	t.Helper()
+
+	graph, err := ds.Get()
+	if err != nil {
+		t.Fatalf("failed to get graph: %s", err)
+	}
+
+	if n := graph.CountVertex(); n != BenchGraphSize {
+		t.Fatalf("expected vertices count %d, found %d", BenchGraphSize, n)
+	}
+	if n := graph.CountEdge(); n != BenchGraphSize-1 {
+		t.Fatalf("expected edges count %d, found %d", BenchGraphSize-1, n)
+	}
+}
+
+// Query is a query of the ancestors of a vertex, for BenchmarkQuery.
+type Query func(ds store.GraphStore, algo store.Algo, id string) error
+
+// The queries of the benchmarks. The conditional ones select the flagged
+// ancestors.
+var (
+	Reach Query = func(ds store.GraphStore, algo store.Algo, id string) error {
+		_, err := ds.Reach(algo, id)
+		return err
+	}
+	ConditionalReach Query = func(ds store.GraphStore, algo store.Algo, id string) error {
+		_, err := ds.ConditionalReach(algo, id, true)
+		return err
+	}
+	List Query = func(ds store.GraphStore, algo store.Algo, id string) error {
+		_, err := ds.List(algo, id)
+		return err
+	}
+	ConditionalList Query = func(ds store.GraphStore, algo store.Algo, id string) error {
+		_, err := ds.ConditionalList(algo, id, true)
+		return err
+	}
+)
+
+// BenchmarkQuery benchmarks the query of a random vertex of the graph of
+// InsertBenchGraph, with BFS and DFS as sub-benchmarks.
+func BenchmarkQuery(b *testing.B, ds store.GraphStore, query Query) {
+	// Choose a random vertex
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	v, err := ds.GetVertexByPosition(r.Intn(BenchGraphSize))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+
+	for _, a := range algos {
+		b.Run(a.name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				if err := query(ds, a.algo, v.ID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkTopologies benchmarks the reach of the last vertex of a graph of
+// every topology, inserted in turn into the store, with BFS and DFS.
+func BenchmarkTopologies(b *testing.B, ds store.GraphStore) {
+	for _, topology := range model.Topologies() {
+		opts := model.DefaultGeneratorOptions(benchTopologySize)
+		opts.Seed = 1
+
+		graph, err := topology.Generate(opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if err := ds.Insert(graph); err != nil {
+			b.Fatal(err)
+		}
+
+		// The last vertex has the most ancestors in most topologies.
+		var v *model.Vertex
+		for _, u := range graph.Vertices() {
+			if u.Index == benchTopologySize {
+				v = u
+			}
+		}
+
+		for _, a := range algos {
+			b.Run(topology.Name+"/"+a.name, func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					if _, err := ds.Reach(a.algo, v.ID); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkAddEdge benchmarks adding and deleting an edge of a vertex with a
+// growing number of children, inserted in turn into the store. The stores
+// which keep every edge in a key of its own should not depend on the number
+// of children.
+func BenchmarkAddEdge(b *testing.B, ds store.GraphStore) {
+	for _, size := range []int{100, 1000, 10000} {
+		graph := model.NewDAG()
+		hub := model.NewVertex("hub", false, 0)
+		graph.AddVertex(hub)
+		for i := 0; i < size; i++ {
+			v := model.NewVertex(fmt.Sprintf("child-%d", i), false, 1)
+			graph.AddVertex(v)
+			if err := graph.AddEdge(hub, v); err != nil {
+				b.Fatal(err)
+			}
+		}
+		graph.AddVertex(model.NewVertex("new", false, 1))
+
+		if err := ds.Insert(graph); err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("children=%d", size), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				if err := ds.AddEdge("hub", "new"); err != nil {
+					b.Fatal(err)
+				}
+				if err := ds.DeleteEdge("hub", "new"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
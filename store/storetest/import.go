@@ -0,0 +1,179 @@
+package storetest
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/ahmadmuzakkir/dag/store"
+)
+
+func testGenerate(t *testing.T, ds store.GraphStore) {
+	opts := model.DefaultGeneratorOptions(2000)
+	opts.Seed = 1
+	opts.Parents = model.UniformParents(1, 3)
+
+	var progress []int
+	err := store.Generate(ds, opts, store.StreamOptions{
+		BatchSize: 300,
+		Progress: func(written, total int) {
+			progress = append(progress, written)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := model.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A batch has 300 mutations, every vertex being followed by the edges of
+	// its parents, and the progress counts the vertices of the applied
+	// batches.
+	byIndex := make(map[int]*model.Vertex)
+	for _, v := range expected.Vertices() {
+		byIndex[v.Index] = v
+	}
+	var expectedProgress []int
+	mutations, vertices := 0, 0
+	for index := 1; index <= opts.Size; index++ {
+		for m := 0; m <= len(byIndex[index].Parents); m++ {
+			if mutations != 0 && mutations%300 == 0 {
+				expectedProgress = append(expectedProgress, vertices)
+			}
+			mutations++
+			if m == 0 {
+				vertices++
+			}
+		}
+	}
+	expectedProgress = append(expectedProgress, opts.Size)
+
+	if !reflect.DeepEqual(progress, expectedProgress) {
+		t.Fatalf("expected the progress %v, found %v", expectedProgress, progress)
+	}
+
+	graph, err := ds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(graph.Vertices(), expected.Vertices()) {
+		t.Fatal("expected the streamed graph to be the generated graph")
+	}
+}
+
+func testNodeLink(t *testing.T, ds store.GraphStore) {
+	expected := model.GenerateGraph(500)
+
+	var buf bytes.Buffer
+	if err := expected.WriteNodeLink(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.ImportNodeLink(ds, &buf, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	if err := store.ExportNodeLink(ds, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// The export is deterministic.
+	var again bytes.Buffer
+	if err := store.ExportNodeLink(ds, &again); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Fatal("expected the same export twice")
+	}
+
+	graph, err := model.ReadNodeLink(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(graph.Vertices(), expected.Vertices()) {
+		t.Fatal("expected the exported graph to be the imported graph")
+	}
+}
+
+// The rows which are malformed, duplicated, dangling or which would create a
+// cycle are rejected, the others are imported and exported.
+func testCSV(t *testing.T, ds store.GraphStore) {
+	vertices := `id, flag ,rank, name
+a,true,0,A
+b,false,1,
+c,x,1,C
+a,false,0,
+d,false,2,"D, d"
+`
+	edges := `parent,child
+a,b
+b,d
+a,unknown
+d,a
+b,b
+b,d,x
+a,d
+`
+
+	report, err := store.ImportCSV(ds, strings.NewReader(vertices), strings.NewReader(edges), store.CSVOptions{
+		Header:    true,
+		BatchSize: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rejected []string
+	for _, row := range report.Rejected {
+		rejected = append(rejected, fmt.Sprintf("%s:%d", row.File, row.Line))
+	}
+	expectedRejected := []string{"vertices:4", "vertices:5", "edges:4", "edges:5", "edges:6", "edges:7"}
+	if !reflect.DeepEqual(rejected, expectedRejected) {
+		t.Fatalf("expected the rejected rows %v, found %v", expectedRejected, report.Rejected)
+	}
+	if report.Vertices != 3 || report.Edges != 3 {
+		t.Fatalf("expected 3 vertices and 3 edges, found %d and %d", report.Vertices, report.Edges)
+	}
+
+	var exportedVertices, exportedEdges bytes.Buffer
+	if err := store.ExportCSV(ds, &exportedVertices, &exportedEdges, store.CSVOptions{Comma: '\t'}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "id\tflag\trank\tindex\tname\na\ttrue\t0\t0\tA\nb\tfalse\t1\t0\t\nd\tfalse\t2\t0\tD, d\n"
+	if exportedVertices.String() != expected {
+		t.Fatalf("expected %q, found %q", expected, exportedVertices.String())
+	}
+
+	expected = "a\tb\na\td\nb\td\n"
+	if exportedEdges.String() != expected {
+		t.Fatalf("expected %q, found %q", expected, exportedEdges.String())
+	}
+
+	// An edge list, without a vertex file.
+	report, err = store.ImportCSV(ds, nil, strings.NewReader("# comment\na  b\n\nb\tc\nc a\n"), store.CSVOptions{Comma: ' '})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Vertices != 3 || report.Edges != 2 || len(report.Rejected) != 1 || report.Rejected[0].Line != 5 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	// A malformed header is a rejected row.
+	report, err = store.ImportCSV(ds, nil, strings.NewReader("pa\"rent,child\na,b\n"), store.CSVOptions{Header: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Vertices != 2 || report.Edges != 1 || len(report.Rejected) != 1 || report.Rejected[0].Line != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
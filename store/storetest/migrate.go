@@ -0,0 +1,125 @@
+package storetest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/ahmadmuzakkir/dag/store"
+)
+
+// MigrateStore is a store which keeps its vertices in records.
+type MigrateStore interface {
+	store.GraphStore
+	store.Migrator
+}
+
+// Records reads and writes the records of the vertices of a store as they
+// are, keyed by the IDs of the vertices, for RunMigrate.
+type Records interface {
+	// Put writes the records, next to the content of the store.
+	Put(records map[string][]byte) error
+
+	// Walk calls fn with every record.
+	Walk(fn func(id string, data []byte) error) error
+}
+
+// RunMigrate checks that ds reads and migrates the records written before
+// the edge keys and before the header, with a generated graph of size
+// vertices. The content of ds is replaced.
+func RunMigrate(t *testing.T, ds MigrateStore, records Records, size int) {
+	expected := model.GenerateGraph(size)
+	insert(t, ds, model.NewDAG())
+
+	// Write the records of schema version 0, and some of the codec.
+	legacy := make(map[string][]byte, expected.CountVertex())
+	for id, v := range expected.Vertices() {
+		data, err := store.EncodeRecord(store.VarintCodec, v)
+		if v.Index%2 == 0 {
+			data, err = store.JSONCodec.Marshal(v)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		legacy[id] = data
+	}
+	if err := records.Put(legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err := ds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(graph.Vertices(), expected.Vertices()) {
+		t.Fatal("expected the mixed records to be read")
+	}
+
+	// The vertices changed before the migration are written with edge keys,
+	// next to the records with their edges.
+	v, err := ds.GetVertexByPosition(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := model.NewBatch()
+	batch.AddVertex(model.NewVertex("new", false, 0))
+	batch.AddEdge(v.ID, "new")
+	if err := ds.Apply(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := expected.Apply(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err = ds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(graph.Vertices(), expected.Vertices()) {
+		t.Fatal("expected the records with edges and with edge keys to be read")
+	}
+
+	ds.SetCodec(store.VarintCodec)
+	n, err := ds.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != expected.CountVertex()-2 {
+		t.Fatalf("expected %d migrated records, found %d", expected.CountVertex()-2, n)
+	}
+
+	if n, err := ds.Migrate(); err != nil || n != 0 {
+		t.Fatalf("expected no migrated records, found %d, %v", n, err)
+	}
+
+	err = records.Walk(func(id string, data []byte) error {
+		if store.NeedsMigration(data, store.VarintCodec) {
+			return fmt.Errorf("record %s is not migrated", id)
+		}
+		v, err := store.DecodeRecord(data)
+		if err != nil {
+			return err
+		}
+		if store.HasEdges(v) {
+			return fmt.Errorf("record %s has edges", id)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds.SetCodec(store.ProtobufCodec)
+	if n, err := ds.Migrate(); err != nil || n != expected.CountVertex() {
+		t.Fatalf("expected %d migrated records, found %d, %v", expected.CountVertex(), n, err)
+	}
+
+	graph, err = ds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(graph.Vertices(), expected.Vertices()) {
+		t.Fatal("expected the migrated records to be read")
+	}
+}
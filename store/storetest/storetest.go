@@ -0,0 +1,660 @@
+// Package storetest is a conformance test suite for the implementations of
+// store.GraphStore. The results of the stores are checked against model.DAG.
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.GraphStore {
+//			ds := ... // open the store, and close it with t.Cleanup
+//			return ds
+//		})
+//	}
+//
+// RunMigrate checks the migration of the records of a store.Migrator, and
+// BenchmarkQuery, BenchmarkTopologies and BenchmarkAddEdge benchmark the
+// queries and mutations of a store.
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ahmadmuzakkir/dag/model"
+	"github.com/ahmadmuzakkir/dag/store"
+)
+
+// Open returns a store for a test. The store may have any content, the tests
+// replace it with Insert. It must be closed by the cleanup functions of t.
+type Open func(t *testing.T) store.GraphStore
+
+// Run runs the conformance tests as subtests of t, each with a store of open.
+func Run(t *testing.T, open Open) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, ds store.GraphStore)
+	}{
+		{"RoundTrip", testRoundTrip},
+		{"Traversals", testTraversals},
+		{"Errors", testErrors},
+		{"Mutations", testMutations},
+		{"Concurrency", testConcurrency},
+		{"Generate", testGenerate},
+		{"NodeLink", testNodeLink},
+		{"CSV", testCSV},
+		{"View", testView},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, open(t))
+		})
+	}
+}
+
+var algos = []struct {
+	name string
+	algo store.Algo
+}{
+	{"BFS", store.ALGO_BFS},
+	{"DFS", store.ALGO_DFS},
+}
+
+// KnownGraph returns a small graph with every kind of vertex field:
+//
+//	a -> b -> d
+//	a -> c -> d
+//	e
+func KnownGraph() *model.DAG {
+	graph := model.NewDAG()
+
+	a := model.NewVertex("a", true, 0)
+	a.Index = 1
+	a.Properties = map[string]string{"name": "A", "empty": ""}
+	b := model.NewVertex("b with space", false, 1)
+	b.Index = 2
+	c := model.NewVertex(model.HexID(1, 3), true, 1)
+	c.Index = 3
+	d := model.NewVertex("dé/\"quoted\"", false, 2)
+	d.Index = 4
+	d.Properties = map[string]string{"unicode": "ü\n\x00"}
+	e := model.NewVertex("e", true, -2)
+
+	for _, v := range []*model.Vertex{a, b, c, d, e} {
+		graph.AddVertex(v)
+	}
+	for _, edge := range [][2]*model.Vertex{{a, b}, {a, c}, {b, d}, {c, d}} {
+		if err := graph.AddEdge(edge[0], edge[1]); err != nil {
+			panic(err)
+		}
+	}
+
+	return graph
+}
+
+// insert replaces the content of the store with a copy of the graph.
+func insert(t *testing.T, ds store.GraphStore, graph *model.DAG) {
+	t.Helper()
+
+	if err := ds.Insert(graph.Snapshot()); err != nil {
+		t.Fatalf("insert: %s", err)
+	}
+}
+
+// checkGraph checks that the store has the vertices of the graph.
+func checkGraph(t *testing.T, ds store.GraphStore, expected *model.DAG) {
+	t.Helper()
+
+	graph, err := ds.Get()
+	if err != nil {
+		t.Fatalf("get: %s", err)
+	}
+
+	checkVertices(t, "get", graph.Vertices(), expected.Vertices())
+}
+
+func checkVertices(t *testing.T, what string, found, expected map[string]*model.Vertex) {
+	t.Helper()
+
+	for id, v := range expected {
+		if !reflect.DeepEqual(found[id], v) {
+			t.Fatalf("%s: expected vertex %+v, found %+v", what, v, found[id])
+		}
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("%s: expected %d vertices, found %d", what, len(expected), len(found))
+	}
+}
+
+func vertexMap(list []*model.Vertex) map[string]*model.Vertex {
+	m := make(map[string]*model.Vertex, len(list))
+	for _, v := range list {
+		m[v.ID] = v
+	}
+
+	return m
+}
+
+func sortedIDs(vertices map[string]*model.Vertex) []string {
+	ids := make([]string, 0, len(vertices))
+	for id := range vertices {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func testRoundTrip(t *testing.T, ds store.GraphStore) {
+	expected := KnownGraph()
+	insert(t, ds, expected)
+	checkGraph(t, ds, expected)
+
+	for id, v := range expected.Vertices() {
+		found, err := ds.GetVertex(id)
+		if err != nil {
+			t.Fatalf("get vertex %s: %s", id, err)
+		}
+		if !reflect.DeepEqual(found, v) {
+			t.Fatalf("get vertex: expected %+v, found %+v", v, found)
+		}
+	}
+
+	walked := make(map[string]*model.Vertex)
+	err := ds.Walk(func(v *model.Vertex) error {
+		if _, ok := walked[v.ID]; ok {
+			return fmt.Errorf("vertex %s is walked twice", v.ID)
+		}
+		walked[v.ID] = v
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk: %s", err)
+	}
+	checkVertices(t, "walk", walked, expected.Vertices())
+
+	stop := errors.New("stop")
+	n := 0
+	err = ds.Walk(func(v *model.Vertex) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Fatalf("walk: expected to stop at the first error, found %d calls and %v", n, err)
+	}
+
+	positions := make(map[string]*model.Vertex)
+	for i := 0; i < expected.CountVertex(); i++ {
+		v, err := ds.GetVertexByPosition(i)
+		if err != nil {
+			t.Fatalf("get vertex at position %d: %s", i, err)
+		}
+		if v == nil {
+			t.Fatalf("get vertex at position %d: no vertex", i)
+		}
+		positions[v.ID] = v
+	}
+	checkVertices(t, "get vertex by position", positions, expected.Vertices())
+
+	// Insert replaces the graph.
+	replacement := model.NewDAG()
+	replacement.AddVertex(model.NewVertex("z", false, 0))
+	insert(t, ds, replacement)
+	checkGraph(t, ds, replacement)
+
+	stats, err := ds.Stats()
+	if err != nil {
+		t.Fatalf("stats: %s", err)
+	}
+	if stats.Vertices != 1 || stats.Edges != 0 {
+		t.Fatalf("stats: expected 1 vertex and no edge, found %+v", stats)
+	}
+}
+
+func testTraversals(t *testing.T, ds store.GraphStore) {
+	graphs := []struct {
+		name  string
+		graph *model.DAG
+	}{
+		{"Known", KnownGraph()},
+	}
+
+	for _, topology := range model.Topologies() {
+		opts := model.DefaultGeneratorOptions(200)
+		opts.Seed = 1
+
+		graph, err := topology.Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		graphs = append(graphs, struct {
+			name  string
+			graph *model.DAG
+		}{topology.Name, graph})
+	}
+
+	for _, g := range graphs {
+		t.Run(g.name, func(t *testing.T) {
+			insert(t, ds, g.graph)
+			checkTraversals(t, ds, g.graph)
+		})
+	}
+}
+
+// checkTraversals checks the queries of the store against the graph, for a
+// sample of the vertices.
+func checkTraversals(t *testing.T, ds store.GraphStore, graph *model.DAG) {
+	t.Helper()
+
+	ids := sortedIDs(graph.Vertices())
+	step := len(ids)/20 + 1
+
+	stats, err := ds.Stats()
+	if err != nil {
+		t.Fatalf("stats: %s", err)
+	}
+	expectedStats, err := graph.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stats, expectedStats) {
+		t.Fatalf("stats: expected %+v, found %+v", expectedStats, stats)
+	}
+
+	for i := 0; i < len(ids); i += step {
+		id := ids[i]
+
+		for _, a := range algos {
+			ancestors := graph.AncestorsBFS
+			if a.algo == store.ALGO_DFS {
+				ancestors = graph.AncestorsDFS
+			}
+
+			filters := []struct {
+				name   string
+				filter func(v *model.Vertex) bool
+				reach  func() (int, error)
+				list   func() ([]*model.Vertex, error)
+			}{
+				{
+					name:  "all",
+					reach: func() (int, error) { return ds.Reach(a.algo, id) },
+					list:  func() ([]*model.Vertex, error) { return ds.List(a.algo, id) },
+				},
+			}
+			for _, flag := range []bool{false, true} {
+				flag := flag
+				filters = append(filters, struct {
+					name   string
+					filter func(v *model.Vertex) bool
+					reach  func() (int, error)
+					list   func() ([]*model.Vertex, error)
+				}{
+					name:   fmt.Sprintf("flag %v", flag),
+					filter: func(v *model.Vertex) bool { return v.Flag == flag },
+					reach:  func() (int, error) { return ds.ConditionalReach(a.algo, id, flag) },
+					list:   func() ([]*model.Vertex, error) { return ds.ConditionalList(a.algo, id, flag) },
+				})
+			}
+
+			for _, f := range filters {
+				expected := vertexMap(ancestors(id, f.filter))
+
+				n, err := f.reach()
+				if err != nil {
+					t.Fatalf("%s reach of %s, %s: %s", a.name, id, f.name, err)
+				}
+				if n != len(expected) {
+					t.Fatalf("%s reach of %s, %s: expected %d, found %d", a.name, id, f.name, len(expected), n)
+				}
+
+				list, err := f.list()
+				if err != nil {
+					t.Fatalf("%s list of %s, %s: %s", a.name, id, f.name, err)
+				}
+				if len(list) != len(expected) {
+					t.Fatalf("%s list of %s, %s: expected %d vertices, found %d", a.name, id, f.name, len(expected), len(list))
+				}
+				checkVertices(t, fmt.Sprintf("%s list of %s, %s", a.name, id, f.name), vertexMap(list), expected)
+			}
+		}
+
+		expected, err := graph.MustPassAncestors(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found, err := ds.MustPassAncestors(id)
+		if err != nil {
+			t.Fatalf("must-pass ancestors of %s: %s", id, err)
+		}
+		if len(found) != len(expected) {
+			t.Fatalf("must-pass ancestors of %s: expected %d vertices, found %d", id, len(expected), len(found))
+		}
+		for j := range expected {
+			if found[j].ID != expected[j].ID {
+				t.Fatalf("must-pass ancestors of %s: expected %s at %d, found %s", id, expected[j].ID, j, found[j].ID)
+			}
+		}
+	}
+}
+
+func testErrors(t *testing.T, ds store.GraphStore) {
+	expected := KnownGraph()
+	insert(t, ds, expected)
+
+	notFound := func(what string, err error) {
+		t.Helper()
+
+		if !errors.Is(err, model.ErrVertexNotFound) {
+			t.Fatalf("%s: expected model.ErrVertexNotFound, found %v", what, err)
+		}
+	}
+
+	for _, a := range algos {
+		_, err := ds.Reach(a.algo, "missing")
+		notFound(a.name+" reach", err)
+		_, err = ds.ConditionalReach(a.algo, "missing", true)
+		notFound(a.name+" conditional reach", err)
+		_, err = ds.List(a.algo, "missing")
+		notFound(a.name+" list", err)
+		_, err = ds.ConditionalList(a.algo, "missing", false)
+		notFound(a.name+" conditional list", err)
+	}
+
+	_, err := ds.MustPassAncestors("missing")
+	notFound("must-pass ancestors", err)
+	_, err = ds.GetVertex("missing")
+	notFound("get vertex", err)
+
+	notFound("update vertex", ds.UpdateVertex(model.NewVertex("missing", false, 0)))
+	notFound("delete vertex", ds.DeleteVertex("missing"))
+	notFound("add edge", ds.AddEdge("a", "missing"))
+	notFound("delete edge", ds.DeleteEdge("missing", "a"))
+
+	invalid := []struct {
+		name string
+		err  error
+		msg  string
+	}{
+		{"duplicate vertex", ds.AddVertex(model.NewVertex("a", false, 0)), "vertex a already exists"},
+		{"duplicate edge", ds.AddEdge("a", "b with space"), "already exists"},
+		{"self-loop", ds.AddEdge("a", "a"), "is a cycle"},
+		{"cycle", ds.AddEdge("dé/\"quoted\"", "a"), "creates a cycle"},
+		{"missing edge", ds.DeleteEdge("a", "e"), ""},
+	}
+	for _, test := range invalid {
+		if test.err == nil {
+			t.Fatalf("%s: expected an error", test.name)
+		}
+		if !strings.Contains(test.err.Error(), test.msg) {
+			t.Fatalf("%s: expected an error with %q, found %v", test.name, test.msg, test.err)
+		}
+		var batchErr *model.BatchError
+		if errors.As(test.err, &batchErr) {
+			t.Fatalf("%s: expected the error of the operation, found %v", test.name, test.err)
+		}
+	}
+
+	// A batch is applied entirely or not at all.
+	batch := model.NewBatch()
+	batch.AddVertex(model.NewVertex("f", false, 0))
+	batch.AddEdge("e", "f")
+	batch.AddEdge("f", "a")
+	batch.AddEdge("dé/\"quoted\"", "e")
+	err = ds.Apply(batch)
+
+	var batchErr *model.BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 3 {
+		t.Fatalf("apply: expected a *model.BatchError at operation 3, found %v", err)
+	}
+
+	checkGraph(t, ds, expected)
+}
+
+// The view of a store.Viewer reads the stored vertices, and the store can be
+// modified once it is released.
+func testView(t *testing.T, ds store.GraphStore) {
+	viewer, ok := ds.(store.Viewer)
+	if !ok {
+		t.Skip("the store is not a store.Viewer")
+	}
+
+	expected := KnownGraph()
+	insert(t, ds, expected)
+
+	get, release, err := viewer.View()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := make(map[string]*model.Vertex)
+	for id, v := range expected.Vertices() {
+		if found[id], err = get.GetVertex(id); err != nil {
+			t.Fatal(err)
+		}
+
+		parents, ok := get.(model.ParentsGetter)
+		if !ok {
+			continue
+		}
+		u, err := parents.GetVertexParents(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(u.Parents, v.Parents) || len(u.Children) != 0 {
+			t.Fatalf("expected the vertex %s with parents %v and without children, found %+v", id, v.Parents, u)
+		}
+	}
+	checkVertices(t, "view", found, expected.Vertices())
+
+	if _, err := get.GetVertex("missing"); !errors.Is(err, model.ErrVertexNotFound) {
+		t.Fatalf("expected model.ErrVertexNotFound, found %v", err)
+	}
+
+	release()
+
+	if err := ds.AddEdge("a", "e"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testMutations(t *testing.T, ds store.GraphStore) {
+	expected := KnownGraph()
+	insert(t, ds, expected)
+
+	var mu sync.Mutex
+	var events, expectedEvents []model.EventType
+	defer ds.Subscribe(model.ObserverFunc(func(e model.Event) {
+		mu.Lock()
+		events = append(events, e.Type())
+		mu.Unlock()
+	}))()
+	defer expected.Subscribe(model.ObserverFunc(func(e model.Event) {
+		expectedEvents = append(expectedEvents, e.Type())
+	}))()
+
+	updated := model.NewVertex("b with space", true, 5)
+	updated.Index = 9
+	updated.Properties = map[string]string{"name": "B"}
+	// The edges of an updated vertex are ignored.
+	updated.Children["e"] = struct{}{}
+
+	mutations := []struct {
+		name  string
+		apply func(b *model.Batch)
+		store func() error
+	}{
+		{
+			name:  "add vertex",
+			apply: func(b *model.Batch) { b.AddVertex(model.NewVertex("f", false, 3)) },
+			store: func() error { return ds.AddVertex(model.NewVertex("f", false, 3)) },
+		},
+		{
+			name:  "add edge",
+			apply: func(b *model.Batch) { b.AddEdge("dé/\"quoted\"", "f") },
+			store: func() error { return ds.AddEdge("dé/\"quoted\"", "f") },
+		},
+		{
+			name:  "add edge from an isolated vertex",
+			apply: func(b *model.Batch) { b.AddEdge("e", "a") },
+			store: func() error { return ds.AddEdge("e", "a") },
+		},
+		{
+			name:  "update vertex",
+			apply: func(b *model.Batch) { b.UpdateVertex(updated) },
+			store: func() error { return ds.UpdateVertex(updated) },
+		},
+		{
+			name:  "delete edge",
+			apply: func(b *model.Batch) { b.DeleteEdge("a", model.HexID(1, 3)) },
+			store: func() error { return ds.DeleteEdge("a", model.HexID(1, 3)) },
+		},
+		{
+			name:  "delete vertex",
+			apply: func(b *model.Batch) { b.DeleteVertex(model.HexID(1, 3)) },
+			store: func() error { return ds.DeleteVertex(model.HexID(1, 3)) },
+		},
+		{
+			name: "batch",
+			apply: func(b *model.Batch) {
+				b.AddVertex(model.NewVertex("g", true, 0))
+				b.AddEdge("g", "e")
+				b.DeleteVertex("dé/\"quoted\"")
+			},
+			store: func() error {
+				b := model.NewBatch()
+				b.AddVertex(model.NewVertex("g", true, 0))
+				b.AddEdge("g", "e")
+				b.DeleteVertex("dé/\"quoted\"")
+				return ds.Apply(b)
+			},
+		},
+	}
+
+	for _, m := range mutations {
+		if err := m.store(); err != nil {
+			t.Fatalf("%s: %s", m.name, err)
+		}
+
+		batch := model.NewBatch()
+		m.apply(batch)
+		if err := expected.Apply(batch); err != nil {
+			t.Fatalf("%s: %s", m.name, err)
+		}
+
+		checkGraph(t, ds, expected)
+	}
+
+	checkTraversals(t, ds, expected)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(events, expectedEvents) {
+		t.Fatalf("expected the events %v, found %v", expectedEvents, events)
+	}
+}
+
+func testConcurrency(t *testing.T, ds store.GraphStore) {
+	graph := model.GenerateGraph(300)
+	insert(t, ds, graph)
+
+	root, err := ds.GetVertexByPosition(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reach, err := ds.Reach(store.ALGO_BFS, root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The DFS counts the start vertex too, like model.DAG.AncestorsDFS, so
+	// the readers compare it with the DFS count before the writes.
+	reachDFS, err := ds.Reach(store.ALGO_DFS, root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, readers, vertices = 2, 4, 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers+readers)
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < vertices; i++ {
+				id := fmt.Sprintf("concurrent-%d-%d", w, i)
+
+				batch := model.NewBatch()
+				batch.AddVertex(model.NewVertex(id, i%2 == 0, 0))
+				batch.AddEdge(root.ID, id)
+				if err := ds.Apply(batch); err != nil {
+					errs <- fmt.Errorf("apply: %w", err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < vertices; i++ {
+				n, err := ds.Reach(store.ALGO_DFS, root.ID)
+				if err != nil {
+					errs <- fmt.Errorf("reach: %w", err)
+					return
+				}
+				if n != reachDFS {
+					errs <- fmt.Errorf("reach: expected %d, found %d", reachDFS, n)
+					return
+				}
+
+				v, err := ds.GetVertex(root.ID)
+				if err != nil {
+					errs <- fmt.Errorf("get vertex: %w", err)
+					return
+				}
+				// The new children are added with the edges, in a transaction.
+				for c := range v.Children {
+					if _, err := ds.GetVertex(c); err != nil {
+						errs <- fmt.Errorf("get child: %w", err)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// The ancestors of a new child are the root and its ancestors.
+	for w := 0; w < writers; w++ {
+		id := fmt.Sprintf("concurrent-%d-%d", w, vertices-1)
+		n, err := ds.Reach(store.ALGO_BFS, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != reach+1 {
+			t.Fatalf("expected reach %d, found %d", reach+1, n)
+		}
+	}
+
+	found, err := ds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.CountVertex() != graph.CountVertex()+writers*vertices {
+		t.Fatalf("expected %d vertices, found %d", graph.CountVertex()+writers*vertices, found.CountVertex())
+	}
+}